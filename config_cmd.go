@@ -15,6 +15,8 @@ import (
 
 const defaultConfig = `# style name or JSON path (default "auto")
 style: "auto"
+# TUI colors: default, high-contrast, monochrome, or a JSON theme
+theme: default
 # mouse support (TUI-mode only)
 mouse: false
 # use pager to display markdown
@@ -23,6 +25,16 @@ pager: false
 width: 80
 # show all files, including hidden and ignored.
 all: false
+# front matter display: hide, table, or raw
+frontmatter: hide
+# image display: auto, kitty, iterm2, sixel, halfblock, or none
+images: auto
+# clickable hyperlinks: auto, always, or never
+hyperlinks: auto
+# show the outputs of notebook cells
+notebookOutputs: true
+# whether CSV data has a header row: auto, always, or never
+csvHeader: auto
 `
 
 var configCmd = &cobra.Command{
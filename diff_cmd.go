@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glow/v2/ui"
+	"github.com/charmbracelet/glow/v2/utils"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// diffGutterWidth is the width of the change markers in front of each line.
+const diffGutterWidth = 2
+
+var diffCmd = &cobra.Command{
+	Use:     "diff OLD NEW",
+	Short:   "Show a rendered comparison of two documents",
+	Long:    paragraph(fmt.Sprintf("\n%s two markdown documents and show which paragraphs and sections were added, removed or changed. Either side can be any source glow reads, or a git revision of a file as git:REV:PATH.", keyword("Render"))),
+	Example: paragraph("glow diff old.md new.md\nglow diff git:HEAD~1:README.md README.md\nglow diff --tui git:main:docs/guide.md docs/guide.md"),
+	Args:    cobra.ExactArgs(2),
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		// Let the flags of this command take precedence over the config.
+		for _, name := range []string{"pager", "tui", "style", "width"} {
+			_ = viper.BindPFlag(name, cmd.Flags().Lookup(name))
+		}
+		return validateOptions(cmd)
+	},
+	RunE: executeDiff,
+}
+
+// diffSource is one side of a diff.
+type diffSource struct {
+	content string
+	URL     string
+}
+
+func executeDiff(cmd *cobra.Command, args []string) error {
+	from, err := readDiffSource(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := readDiffSource(args[1])
+	if err != nil {
+		return err
+	}
+
+	if tui || cmd.Flags().Changed("tui") {
+		cfg, err := tuiConfig()
+		if err != nil {
+			return err
+		}
+		cfg.Diff = &ui.Diff{
+			Title: args[0] + " → " + args[1],
+			Render: func(width int) (string, []int, error) {
+				return renderDiff(from, to, width)
+			},
+		}
+		if _, err := ui.NewProgram(cfg, "").Run(); err != nil {
+			return fmt.Errorf("unable to run tui program: %w", err)
+		}
+		return nil
+	}
+
+	out, _, err := renderDiff(from, to, int(width)) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if pager || cmd.Flags().Changed("pager") {
+		return runPager(out)
+	}
+	if _, err := fmt.Fprint(cmd.OutOrStdout(), out); err != nil {
+		return fmt.Errorf("unable to write to writer: %w", err)
+	}
+	return nil
+}
+
+// readDiffSource reads a side of a diff. Besides everything sourceFromArg
+// supports, git:REV:PATH reads PATH as of the given git revision.
+func readDiffSource(arg string) (*diffSource, error) {
+	if spec, ok := strings.CutPrefix(arg, "git:"); ok {
+		rev, path, ok := strings.Cut(spec, ":")
+		if !ok || rev == "" || path == "" {
+			return nil, fmt.Errorf("invalid git source %s: must be git:REV:PATH", arg)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get absolute path: %w", err)
+		}
+		c := exec.Command("git", "-C", filepath.Dir(abs), "show", rev+":./"+filepath.Base(abs)) //nolint:gosec
+		c.Stderr = os.Stderr
+		b, err := c.Output()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s from git: %w", arg, err)
+		}
+		return &diffSource{string(b), abs}, nil
+	}
+
+	src, err := sourceFromArg(arg)
+	if err != nil {
+		return nil, err
+	}
+	defer src.reader.Close() //nolint:errcheck
+	b, err := io.ReadAll(src.reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read from reader: %w", err)
+	}
+	return &diffSource{string(b), src.URL}, nil
+}
+
+// renderDiff renders both documents at the given width and marks the blocks
+// that differ. It returns the output and the lines at which changes start.
+func renderDiff(from, to *diffSource, width int) (string, []int, error) {
+	var baseURL string
+	if u, err := url.ParseRequestURI(to.URL); err == nil {
+		u.Path = filepath.Dir(u.Path)
+		baseURL = u.String() + "/"
+	}
+
+	wrap := max(0, width-diffGutterWidth)
+	options := []glamour.TermRendererOption{
+		glamour.WithColorProfile(lipgloss.ColorProfile()),
+		utils.GlamourStyle(style, styleOverrides, false),
+		glamour.WithWordWrap(wrap),
+		glamour.WithBaseURL(baseURL),
+		glamour.WithPreservedNewLines(),
+	}
+	r, err := glamour.NewTermRenderer(options...)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create renderer: %w", err)
+	}
+
+	blocks := utils.DiffBlocks(
+		utils.SplitBlocks(from.markdown(wrap)),
+		utils.SplitBlocks(to.markdown(wrap)),
+	)
+	return utils.RenderDiff(blocks, func(md string) (string, error) {
+		return renderMarkdown(r, options, md, false, to.URL, baseURL, wrap)
+	})
+}
+
+// markdown returns the preprocessed markdown of the document.
+func (s *diffSource) markdown(width int) string {
+	b := []byte(s.content)
+	if md, err := toMarkdown(s.URL, b, width); err == nil {
+		b = md
+	}
+	b = utils.RenderFrontmatter(b, frontmatter)
+	return preprocessMarkdown(string(b), s.URL, width)
+}
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/muesli/termenv"
+	"github.com/spf13/viper"
 )
 
 func TestRenderMermaidFlag(t *testing.T) {
@@ -106,3 +114,195 @@ func TestGlowFlags(t *testing.T) {
 		}
 	}
 }
+
+func TestFrontmatterValidation(t *testing.T) {
+	t.Cleanup(func() {
+		_ = rootCmd.Flags().Set("frontmatter", "hide")
+	})
+
+	for _, v := range []string{"hide", "table", "raw"} {
+		if err := rootCmd.Flags().Set("frontmatter", v); err != nil {
+			t.Fatalf("failed to set flag: %v", err)
+		}
+		if err := validateOptions(rootCmd); err != nil {
+			t.Errorf("unexpected error for --frontmatter=%s: %v", v, err)
+		}
+	}
+
+	if err := rootCmd.Flags().Set("frontmatter", "invalid"); err != nil {
+		t.Fatalf("failed to set flag: %v", err)
+	}
+	err := validateOptions(rootCmd)
+	if err == nil || !strings.Contains(err.Error(), "invalid --frontmatter value") {
+		t.Errorf("expected invalid --frontmatter error, got %v", err)
+	}
+}
+
+func TestImagesValidation(t *testing.T) {
+	t.Cleanup(func() {
+		_ = rootCmd.Flags().Set("images", "auto")
+	})
+
+	for _, v := range []string{"auto", "kitty", "iterm2", "sixel", "halfblock", "none"} {
+		if err := rootCmd.Flags().Set("images", v); err != nil {
+			t.Fatalf("failed to set flag: %v", err)
+		}
+		if err := validateOptions(rootCmd); err != nil {
+			t.Errorf("unexpected error for --images=%s: %v", v, err)
+		}
+	}
+
+	if err := rootCmd.Flags().Set("images", "invalid"); err != nil {
+		t.Fatalf("failed to set flag: %v", err)
+	}
+	err := validateOptions(rootCmd)
+	if err == nil || !strings.Contains(err.Error(), "invalid --images value") {
+		t.Errorf("expected invalid --images error, got %v", err)
+	}
+}
+
+func TestHyperlinksValidation(t *testing.T) {
+	t.Cleanup(func() {
+		_ = rootCmd.Flags().Set("hyperlinks", "auto")
+	})
+
+	for _, v := range []string{"auto", "always", "never"} {
+		if err := rootCmd.Flags().Set("hyperlinks", v); err != nil {
+			t.Fatalf("failed to set flag: %v", err)
+		}
+		if err := validateOptions(rootCmd); err != nil {
+			t.Errorf("unexpected error for --hyperlinks=%s: %v", v, err)
+		}
+	}
+
+	if err := rootCmd.Flags().Set("hyperlinks", "sometimes"); err != nil {
+		t.Fatalf("failed to set flag: %v", err)
+	}
+	err := validateOptions(rootCmd)
+	if err == nil || !strings.Contains(err.Error(), "invalid --hyperlinks value") {
+		t.Errorf("expected invalid --hyperlinks error, got %v", err)
+	}
+}
+
+func TestColorProfile(t *testing.T) {
+	tt := []struct {
+		name       string
+		mode       string
+		profile    string
+		env        map[string]string
+		isTerminal bool
+		expected   termenv.Profile
+	}{
+		{name: "pipe", mode: "auto", profile: "ansi256", expected: termenv.Ascii},
+		{name: "terminal", mode: "auto", profile: "ansi256", isTerminal: true, expected: termenv.ANSI256},
+		{name: "NO_COLOR", mode: "auto", profile: "ansi256", isTerminal: true, env: map[string]string{"NO_COLOR": "1"}, expected: termenv.Ascii},
+		{name: "CLICOLOR_FORCE", mode: "auto", profile: "ansi", env: map[string]string{"CLICOLOR_FORCE": "1"}, expected: termenv.ANSI},
+		{name: "CLICOLOR_FORCE=0", mode: "auto", profile: "ansi", env: map[string]string{"CLICOLOR_FORCE": "0"}, expected: termenv.Ascii},
+		{name: "always", mode: "always", profile: "truecolor", env: map[string]string{"NO_COLOR": "1"}, expected: termenv.TrueColor},
+		{name: "always detected", mode: "always", env: map[string]string{"TERM": "dumb"}, expected: termenv.ANSI256},
+		{name: "never", mode: "never", profile: "truecolor", isTerminal: true, expected: termenv.Ascii},
+	}
+
+	for _, v := range tt {
+		t.Run(v.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", "")
+			t.Setenv("CLICOLOR_FORCE", "")
+			for k, val := range v.env {
+				t.Setenv(k, val)
+			}
+			p, err := colorProfile(v.mode, v.profile, v.isTerminal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p != v.expected {
+				t.Errorf("colorProfile() = %v, want %v", p, v.expected)
+			}
+		})
+	}
+
+	if _, err := colorProfile("auto", "16", true); err == nil || !strings.Contains(err.Error(), "invalid --color-profile value") {
+		t.Errorf("expected invalid --color-profile error, got %v", err)
+	}
+}
+
+func TestStyleCommands_BrokenStyle(t *testing.T) {
+	// The style commands run with a broken style in the config, to find what
+	// is wrong with it.
+	path := filepath.Join(t.TempDir(), "broken.json")
+	if err := os.WriteFile(path, []byte(`{"h1": {"prefix": 5}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("style", path)
+	t.Cleanup(func() { viper.Set("style", styles.AutoStyle) })
+	if err := validateOptions(rootCmd); err == nil {
+		t.Fatal("expected an error for the broken style")
+	}
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(io.Discard)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootCmd.SetArgs(nil)
+	})
+	rootCmd.SetArgs([]string{"style", "list"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Errorf("style list: %v", err)
+	}
+	out.Reset()
+	rootCmd.SetArgs([]string{"style", "validate", path})
+	if err := rootCmd.Execute(); err == nil || err.Error() != "found 1 problem in "+path {
+		t.Errorf("style validate error = %v, want found 1 problem", err)
+	}
+	if want := path + ":1:19: h1.prefix: expected a string, got 5\n"; out.String() != want {
+		t.Errorf("style validate printed %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunBuiltinPager_NotTerminal(t *testing.T) {
+	// Without a terminal, the content is written out like less does.
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+	if err := runBuiltinPager(f, "# Hello\n"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "# Hello\n" {
+		t.Errorf("runBuiltinPager() wrote %q, want the content", b)
+	}
+}
+
+func TestSourceFromArg_Readme(t *testing.T) {
+	// Markdown is preferred over other markups in a directory, and the
+	// directory over its subdirectories.
+	dir := t.TempDir()
+	for _, name := range []string{"README.adoc", "README.md", "a/README.md", "b/readme.org"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("# "+name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for arg, want := range map[string]string{
+		dir:                     "README.md",
+		filepath.Join(dir, "b"): filepath.Join("b", "readme.org"),
+	} {
+		src, err := sourceFromArg(arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src.reader.Close() //nolint:errcheck
+		if got, _ := filepath.Rel(dir, src.URL); got != want {
+			t.Errorf("sourceFromArg(%q) = %s, want %s", arg, got, want)
+		}
+	}
+}
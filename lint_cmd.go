@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/glow/v2/ui"
+	"github.com/charmbracelet/glow/v2/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultLintWidth is the width diagrams are linted at: the width documents
+// are rendered at when it isn't a terminal's.
+const defaultLintWidth = 80
+
+var (
+	lintWidth uint
+
+	lintDiagramsCmd = &cobra.Command{
+		Use:     "lint-diagrams [FILE|DIR]...",
+		Short:   "Check the mermaid diagrams of documents",
+		Long:    paragraph(fmt.Sprintf("\n%s every mermaid diagram of the documents, or of every document in the directories, and print the errors of those that fail at their line and column. Exits with a nonzero status if any diagram fails, for CI.", keyword("Render"))),
+		Example: paragraph("glow lint-diagrams README.md\nglow lint-diagrams --width 120 docs"),
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			// Let the flags of this command take precedence over the config.
+			_ = viper.BindPFlag("all", cmd.Flags().Lookup("all"))
+			return validateOptions(cmd)
+		},
+		RunE: executeLintDiagrams,
+	}
+)
+
+func executeLintDiagrams(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	var problems int
+	for _, arg := range args {
+		n, err := lintDiagrams(cmd.OutOrStdout(), arg)
+		if err != nil {
+			return err
+		}
+		problems += n
+	}
+
+	switch problems {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("found 1 diagram error")
+	default:
+		return fmt.Errorf("found %d diagram errors", problems)
+	}
+}
+
+// lintDiagrams prints the diagram errors of a document, or of the documents
+// in a directory, found like the file browser finds them, and returns how
+// many there are.
+func lintDiagrams(w io.Writer, arg string) (int, error) {
+	st, err := os.Stat(arg)
+	if err != nil {
+		return 0, fmt.Errorf("unable to stat file: %w", err)
+	}
+	if !st.IsDir() {
+		return lintDiagramsFile(w, arg)
+	}
+
+	cfg, err := tuiConfig()
+	if err != nil {
+		return 0, err
+	}
+	ch, err := ui.FindLocalFiles(cfg, arg)
+	if err != nil {
+		return 0, fmt.Errorf("unable to find documents: %w", err)
+	}
+	// Print paths like the one given.
+	cwd, _ := os.Getwd()
+	var problems int
+	for res := range ch {
+		path := res.Path
+		if rel, err := filepath.Rel(cwd, res.Path); err == nil && !filepath.IsAbs(arg) {
+			path = rel
+		}
+		n, err := lintDiagramsFile(w, path)
+		if err != nil {
+			return problems, err
+		}
+		problems += n
+	}
+	return problems, nil
+}
+
+func lintDiagramsFile(w io.Writer, path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	// Diagrams are rendered at the width of the document, as some only fail
+	// to fit within it.
+	diagnostics := utils.LintMermaid(context.Background(), string(b), "unicode", int(lintWidth)) //nolint:gosec
+	for _, d := range diagnostics {
+		if _, err := fmt.Fprintf(w, "%s:%s\n", path, d); err != nil {
+			return 0, fmt.Errorf("unable to write to writer: %w", err)
+		}
+	}
+	return len(diagnostics), nil
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
@@ -11,8 +12,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/caarlos0/env/v11"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/glamour/styles"
@@ -21,6 +26,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	gap "github.com/muesli/go-app-paths"
+	"github.com/muesli/termenv"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/term"
@@ -32,17 +38,32 @@ var (
 	// CommitSHA as provided by goreleaser.
 	CommitSHA = ""
 
-	readmeNames      = []string{"README.md", "README", "Readme.md", "Readme", "readme.md", "readme"}
+	readmeNames      = []string{"README.md", "README", "Readme.md", "Readme", "readme.md", "readme", "README.rst", "README.adoc", "README.asciidoc", "README.org"}
 	configFile       string
 	pager            bool
 	tui              bool
 	style            string
+	color            string
+	theme            *ui.Theme
+	styleOverrides   map[string]any
 	width            uint
 	showAllFiles     bool
 	showLineNumbers  bool
 	preserveNewLines bool
 	mouse            bool
 	renderMermaid    string
+	renderDot        string
+	frontmatter      string
+	renderMath       bool
+	images           string
+	hyperlinks       string
+	languages        map[string]string
+	notebookOutputs  bool
+	renderers        utils.BlockRenderers
+	csvDelimiter     string
+	csvHeader        string
+	csvMaxRows       int
+	tableOptions     utils.TableOptions
 
 	rootCmd = &cobra.Command{
 		Use:   "glow [SOURCE|DIR]",
@@ -110,23 +131,15 @@ func sourceFromArg(arg string) (*source, error) {
 	st, err := os.Stat(arg)
 	if err == nil && st.IsDir() { //nolint:nestif
 		var src *source
-		_ = filepath.Walk(arg, func(path string, _ os.FileInfo, err error) error {
+		_ = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
-			for _, v := range readmeNames {
-				if strings.EqualFold(filepath.Base(path), v) {
-					r, err := os.Open(path)
-					if err != nil {
-						continue
-					}
-
-					u, _ := filepath.Abs(path)
-					src = &source{r, u}
-
-					// abort filepath.Walk
-					return errors.New("source found")
-				}
+			if !d.IsDir() {
+				return nil
+			}
+			if src = readmeIn(path); src != nil {
+				return filepath.SkipAll
 			}
 			return nil
 		})
@@ -149,21 +162,145 @@ func sourceFromArg(arg string) (*source, error) {
 	return &source{r, u}, nil
 }
 
-// validateStyle checks if the style is a default style, if not, checks that
-// the custom style exists.
-func validateStyle(style string) error {
-	if style != "auto" && styles.DefaultStyles[style] == nil {
-		style = utils.ExpandPath(style)
-		if _, err := os.Stat(style); errors.Is(err, fs.ErrNotExist) {
-			return fmt.Errorf("specified style does not exist: %s", style)
-		} else if err != nil {
-			return fmt.Errorf("unable to stat file: %w", err)
+// readmeIn returns the README of a directory, preferring the names that come
+// first in readmeNames, like markdown over other markups.
+func readmeIn(dir string) *source {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, v := range readmeNames {
+		for _, e := range entries {
+			if e.IsDir() || !strings.EqualFold(e.Name(), v) {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			r, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			u, _ := filepath.Abs(path)
+			return &source{r, u}
 		}
 	}
 	return nil
 }
 
+// colorProfile returns the color profile to render with. In auto mode there
+// are colors when writing to a terminal and NO_COLOR isn't set, or when
+// CLICOLOR_FORCE is set. The profile is detected unless one is named.
+func colorProfile(mode, name string, isTerminal bool) (termenv.Profile, error) {
+	var profile termenv.Profile
+	switch name {
+	case "":
+		profile = termenv.NewOutput(os.Stdout, termenv.WithTTY(true)).ColorProfile()
+		if !isTerminal && profile == termenv.Ascii {
+			// Colors are forced somewhere the terminal is unknown, like CI.
+			profile = termenv.ANSI256
+		}
+	case "ascii":
+		profile = termenv.Ascii
+	case "ansi":
+		profile = termenv.ANSI
+	case "ansi256":
+		profile = termenv.ANSI256
+	case "truecolor":
+		profile = termenv.TrueColor
+	default:
+		return profile, fmt.Errorf("invalid --color-profile value: %s (must be ascii, ansi, ansi256, or truecolor)", name)
+	}
+
+	if mode == "auto" {
+		force := os.Getenv("CLICOLOR_FORCE")
+		switch {
+		case os.Getenv("NO_COLOR") != "":
+			mode = "never"
+		case force != "" && force != "0", isTerminal:
+			mode = "always"
+		default:
+			mode = "never"
+		}
+	}
+	if mode == "never" {
+		return termenv.Ascii, nil
+	}
+	return profile, nil
+}
+
+// configSubdir returns the path of a directory next to the config file.
+func configSubdir(name string) string {
+	config := cmp.Or(configFile, viper.ConfigFileUsed())
+	if config == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(config), name)
+}
+
+// loadTheme returns the theme of the TUI: a built-in theme, or a JSON theme
+// file, which can be named without its extension when it's in the themes
+// directory.
+func loadTheme(name string) (*ui.Theme, error) {
+	if dir := configSubdir("themes"); dir != "" && !slices.Contains(ui.ThemeNames(), name) && !strings.ContainsAny(name, `/\`) {
+		path := filepath.Join(dir, name+".json")
+		if _, err := os.Stat(path); err == nil {
+			name = path
+		}
+	}
+	t, err := ui.LoadTheme(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+	return &t, nil
+}
+
+// blockRenderers returns the external renderers of fenced code blocks set in
+// the config, by language.
+func blockRenderers() (utils.BlockRenderers, error) {
+	var config map[string]struct {
+		Command string
+		Timeout time.Duration
+	}
+	if err := viper.UnmarshalKey("renderers", &config); err != nil {
+		return nil, fmt.Errorf("invalid renderers in config: %w", err)
+	}
+	r := utils.BlockRenderers{}
+	for lang, c := range config {
+		if strings.TrimSpace(c.Command) == "" {
+			return nil, fmt.Errorf("invalid renderer for %s in config: no command", lang)
+		}
+		r[strings.ToLower(lang)] = utils.CommandRenderer{Command: c.Command, Timeout: c.Timeout}
+	}
+	return r, nil
+}
+
+// csvOptions returns the options of tables of CSV and TSV data.
+func csvOptions() (utils.TableOptions, error) {
+	opts := utils.TableOptions{
+		Header:  viper.GetString("csvHeader"),
+		MaxRows: viper.GetInt("csvMaxRows"),
+	}
+	switch d := viper.GetString("csvDelimiter"); {
+	case d == "":
+	case d == "tab" || d == `\t`:
+		opts.Delimiter = '\t'
+	case utf8.RuneCountInString(d) == 1:
+		opts.Delimiter, _ = utf8.DecodeRuneInString(d)
+	default:
+		return opts, fmt.Errorf("invalid --csv-delimiter value: %s (must be a single character or tab)", d)
+	}
+	switch opts.Header {
+	case utils.TableHeaderAuto, utils.TableHeaderAlways, utils.TableHeaderNever:
+	default:
+		return opts, fmt.Errorf("invalid --csv-header value: %s (must be auto, always, or never)", opts.Header)
+	}
+	if opts.MaxRows < 0 {
+		return opts, fmt.Errorf("invalid --csv-max-rows value: %d", opts.MaxRows)
+	}
+	return opts, nil
+}
+
 func validateOptions(cmd *cobra.Command) error {
+	var err error
 	// grab config values from Viper
 	width = viper.GetUint("width")
 	mouse = viper.GetBool("mouse")
@@ -176,22 +313,97 @@ func validateOptions(cmd *cobra.Command) error {
 	if renderMermaid != "raw" && renderMermaid != "ascii" && renderMermaid != "unicode" {
 		return fmt.Errorf("invalid --render-mermaid value: %s (must be raw, ascii, or unicode)", renderMermaid)
 	}
+	renderDot = viper.GetString("renderDot")
+	if renderDot != "raw" && renderDot != "ascii" && renderDot != "unicode" {
+		return fmt.Errorf("invalid --render-dot value: %s (must be raw, ascii, or unicode)", renderDot)
+	}
+	utils.DiagramCache = diagramCache(viper.GetBool("noCache"))
+	renderMath = viper.GetBool("renderMath")
+	notebookOutputs = viper.GetBool("notebookOutputs")
+	frontmatter = viper.GetString("frontmatter")
+	if frontmatter != "hide" && frontmatter != "table" && frontmatter != "raw" {
+		return fmt.Errorf("invalid --frontmatter value: %s (must be hide, table, or raw)", frontmatter)
+	}
+
+	images = viper.GetString("images")
+	switch images {
+	case "auto", utils.ImagesNone, utils.ImagesHalfblock, utils.ImagesKitty, utils.ImagesITerm2, utils.ImagesSixel:
+	default:
+		return fmt.Errorf("invalid --images value: %s (must be auto, kitty, iterm2, sixel, halfblock, or none)", images)
+	}
+
+	hyperlinks = viper.GetString("hyperlinks")
+	if hyperlinks != "auto" && hyperlinks != "always" && hyperlinks != "never" {
+		return fmt.Errorf("invalid --hyperlinks value: %s (must be auto, always, or never)", hyperlinks)
+	}
+
+	if tableOptions, err = csvOptions(); err != nil {
+		return err
+	}
+
+	languages = viper.GetStringMapString("languages")
+	for ext, lang := range languages {
+		if lexers.Get(lang) == nil {
+			return fmt.Errorf("invalid language for %s in config: %s", ext, lang)
+		}
+	}
+
+	if renderers, err = blockRenderers(); err != nil {
+		return err
+	}
 
 	if pager && tui {
 		return errors.New("cannot use both pager and tui")
 	}
 
 	// validate the glamour style
-	style = viper.GetString("style")
-	if err := validateStyle(style); err != nil {
+	if style, err = findStyle(viper.GetString("style")); err != nil {
+		return err
+	}
+	styleOverrides = viper.GetStringMap("style_overrides")
+	if theme, err = loadTheme(viper.GetString("theme")); err != nil {
+		return err
+	}
+	if _, err := utils.LoadStyle(style, styleOverrides); err != nil {
 		return err
 	}
 
 	isTerminal := term.IsTerminal(int(os.Stdout.Fd()))
-	// We want to use a special no-TTY style, when stdout is not a terminal
-	// and there was no specific style passed by arg
-	if !isTerminal && !cmd.Flags().Changed("style") {
+	color = viper.GetString("color")
+	if color != "auto" && color != "always" && color != "never" {
+		return fmt.Errorf("invalid --color value: %s (must be auto, always, or never)", color)
+	}
+	profile, err := colorProfile(color, viper.GetString("colorProfile"), isTerminal)
+	if err != nil {
+		return err
+	}
+	lipgloss.SetColorProfile(profile)
+
+	// We want to use a special no-TTY style, when colors are off and there
+	// was no specific style passed by arg
+	if profile == termenv.Ascii && !cmd.Flags().Changed("style") {
 		style = "notty"
+	} else if style == styles.AutoStyle && !isTerminal {
+		// Colors are forced into a pipe, where glamour would pick notty.
+		style = styles.DarkStyle
+		if !lipgloss.HasDarkBackground() {
+			style = styles.LightStyle
+		}
+	}
+
+	// Likewise, only display images when writing to a color terminal,
+	// unless a protocol was chosen explicitly.
+	if images == "auto" {
+		images = utils.ImagesNone
+		if isTerminal && lipgloss.ColorProfile() != termenv.Ascii {
+			images = utils.DetectImageProtocol()
+		}
+	}
+	if hyperlinks == "auto" {
+		hyperlinks = "never"
+		if isTerminal && utils.HyperlinksSupported() {
+			hyperlinks = "always"
+		}
 	}
 
 	// Detect terminal width
@@ -281,8 +493,6 @@ func executeCLI(cmd *cobra.Command, src *source, w io.Writer) error {
 		return fmt.Errorf("unable to read from reader: %w", err)
 	}
 
-	b = utils.RemoveFrontmatter(b)
-
 	// render
 	var baseURL string
 	u, err := url.ParseRequestURI(src.URL)
@@ -291,52 +501,44 @@ func executeCLI(cmd *cobra.Command, src *source, w io.Writer) error {
 		baseURL = u.String() + "/"
 	}
 
-	isCode := !utils.IsMarkdownFile(src.URL)
+	if b, err = toMarkdown(src.URL, b, int(width)); err != nil { //nolint:gosec
+		return err
+	}
+
+	isCode := utils.IsCodeFile(src.URL, b, languages)
+	if !isCode {
+		b = utils.RenderFrontmatter(b, frontmatter)
+	}
 
 	// initialize glamour
-	r, err := glamour.NewTermRenderer(
+	options := []glamour.TermRendererOption{
 		glamour.WithColorProfile(lipgloss.ColorProfile()),
-		utils.GlamourStyle(style, isCode),
+		utils.GlamourStyle(style, styleOverrides, isCode),
 		glamour.WithWordWrap(int(width)), //nolint:gosec
 		glamour.WithBaseURL(baseURL),
 		glamour.WithPreservedNewLines(),
-	)
+	}
+	r, err := glamour.NewTermRenderer(options...)
 	if err != nil {
 		return fmt.Errorf("unable to create renderer: %w", err)
 	}
 
 	content := string(b)
-	ext := filepath.Ext(src.URL)
 	if isCode {
-		content = utils.WrapCodeBlock(string(b), ext)
+		content = utils.WrapCodeBlock(content, utils.DetectLanguage(src.URL, content, languages))
+	} else {
+		content = preprocessMarkdown(content, src.URL, int(width)) //nolint:gosec
 	}
 
-	// Preprocess mermaid blocks if rendering a markdown file
-	if !isCode {
-		content = utils.RenderMermaidBlocks(content, renderMermaid, int(width))
-	}
-
-	out, err := r.Render(content)
+	out, err := renderMarkdown(r, options, content, isCode, src.URL, baseURL, int(width)) //nolint:gosec
 	if err != nil {
-		return fmt.Errorf("unable to render markdown: %w", err)
+		return err
 	}
 
 	// display
 	switch {
 	case pager || cmd.Flags().Changed("pager"):
-		pagerCmd := os.Getenv("PAGER")
-		if pagerCmd == "" {
-			pagerCmd = "less -r"
-		}
-
-		pa := strings.Split(pagerCmd, " ")
-		c := exec.Command(pa[0], pa[1:]...) //nolint:gosec
-		c.Stdin = strings.NewReader(out)
-		c.Stdout = os.Stdout
-		if err := c.Run(); err != nil {
-			return fmt.Errorf("unable to run command: %w", err)
-		}
-		return nil
+		return runPager(out)
 	case tui || cmd.Flags().Changed("tui"):
 		path := ""
 		if !isURL(src.URL) {
@@ -351,32 +553,169 @@ func executeCLI(cmd *cobra.Command, src *source, w io.Writer) error {
 	}
 }
 
+// diagramCache returns the cache of rendered diagrams in the user's cache
+// directory, or nil when caching is disabled or there's no cache directory.
+func diagramCache(disabled bool) *utils.DiskCache {
+	if disabled {
+		return nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		log.Debug("not caching diagrams", "error", err)
+		return nil
+	}
+	return &utils.DiskCache{Dir: filepath.Join(dir, "glow", "diagrams")}
+}
+
+// runPager displays out with the pager set in $GLOW_PAGER or $PAGER.
+func runPager(out string) error {
+	pagerCmd := cmp.Or(os.Getenv("GLOW_PAGER"), os.Getenv("PAGER"), "less -r")
+	pa, err := utils.ShellWords(pagerCmd)
+	if err != nil {
+		return fmt.Errorf("invalid pager command %q: %w", pagerCmd, err)
+	}
+
+	// Fall back to the built-in pager when there's no pager to run, like in
+	// the Docker image.
+	if len(pa) == 0 {
+		return runBuiltinPager(os.Stdout, out)
+	}
+	path, err := exec.LookPath(pa[0])
+	if err != nil {
+		log.Debug("pager not found, using the built-in one", "pager", pa[0], "error", err)
+		return runBuiltinPager(os.Stdout, out)
+	}
+
+	c := exec.Command(path, pa[1:]...) //nolint:gosec
+	c.Stdin = strings.NewReader(out)
+	c.Stdout = os.Stdout
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("unable to run command: %w", err)
+	}
+	return nil
+}
+
+// runBuiltinPager shows out in the built-in pager, or, like less, writes it
+// to w when w isn't a terminal, as there may be no terminal to page in.
+func runBuiltinPager(w *os.File, out string) error {
+	if !term.IsTerminal(int(w.Fd())) {
+		if _, err := io.WriteString(w, out); err != nil {
+			return fmt.Errorf("unable to write to writer: %w", err)
+		}
+		return nil
+	}
+	return ui.RunPager(out, theme)
+}
+
+// toMarkdown converts notebooks, reStructuredText, AsciiDoc and Org documents
+// and CSV and TSV data to markdown. Other content is returned as is.
+func toMarkdown(srcURL string, b []byte, width int) ([]byte, error) {
+	switch {
+	case utils.IsNotebook(srcURL):
+		md, err := utils.NotebookToMarkdown(b, notebookOutputs, images != utils.ImagesNone)
+		return []byte(md), err
+	case utils.IsMarkupFile(srcURL):
+		return []byte(utils.MarkupToMarkdown(srcURL, string(b))), nil
+	case utils.IsTableFile(srcURL):
+		opts := tableOptions
+		opts.Width = width
+		md, err := utils.TableToMarkdown(srcURL, string(b), opts)
+		return []byte(md), err
+	}
+	return b, nil
+}
+
+// preprocessMarkdown expands include directives and renders math and diagram
+// blocks of a markdown document.
+func preprocessMarkdown(content, srcURL string, width int) string {
+	// Expand include directives of local documents. Remote documents must
+	// not be able to pull in local files.
+	if !isURL(srcURL) {
+		content, _ = utils.ExpandIncludes(content, srcURL)
+	}
+	content = utils.DetectFenceLanguages(content)
+	opts := tableOptions
+	opts.Width = width
+	content = utils.RenderTableBlocks(content, opts)
+	if renderMath {
+		content = utils.RenderMathBlocks(content)
+	}
+	content = utils.RenderBlocks(content, renderers, width)
+	content = utils.RenderMermaidBlocks(content, renderMermaid, width)
+	return utils.RenderDotBlocks(content, renderDot, width)
+}
+
+// renderMarkdown renders preprocessed content with r, along with the
+// callouts, images and hyperlinks glamour doesn't render itself.
+func renderMarkdown(r *glamour.TermRenderer, options []glamour.TermRendererOption, content string, isCode bool, srcURL, baseURL string, width int) (string, error) {
+	md, links, callouts, imgs := content, utils.Links(nil), utils.Callouts(nil), utils.Images{}
+	if !isCode && hyperlinks == "always" {
+		md, links = utils.MarkLinks(md, imageBase(srcURL, baseURL), baseURL)
+	}
+	if !isCode {
+		md, callouts = utils.ExtractCallouts(md)
+		md, imgs = utils.ExtractImages(md, imageBase(srcURL, baseURL), images)
+	}
+	out, err := r.Render(md)
+	if err != nil {
+		return "", fmt.Errorf("unable to render markdown: %w", err)
+	}
+	out = callouts.Render(out, style, styleOverrides, width, options...)
+	out = imgs.Render(out, width)
+	return links.Render(out), nil
+}
+
 func runTUI(path string, content string) error {
+	cfg, err := tuiConfig()
+	if err != nil {
+		return err
+	}
+	cfg.Path = path
+
+	// Run Bubble Tea program
+	if _, err := ui.NewProgram(cfg, content).Run(); err != nil {
+		return fmt.Errorf("unable to run tui program: %w", err)
+	}
+
+	return nil
+}
+
+// tuiConfig returns the TUI configuration from the environment and options.
+func tuiConfig() (ui.Config, error) {
 	// Read environment to get debugging stuff
 	cfg, err := env.ParseAs[ui.Config]()
 	if err != nil {
-		return fmt.Errorf("error parsing config: %v", err)
+		return cfg, fmt.Errorf("error parsing config: %v", err)
 	}
 
 	// use style set in env, or auto if unset
-	if err := validateStyle(cfg.GlamourStyle); err != nil {
+	if cfg.GlamourStyle, err = findStyle(cfg.GlamourStyle); err != nil {
 		cfg.GlamourStyle = style
 	}
 
-	cfg.Path = path
+	cfg.StyleOverrides = styleOverrides
+	cfg.Theme = theme
 	cfg.ShowAllFiles = showAllFiles
 	cfg.ShowLineNumbers = showLineNumbers
 	cfg.GlamourMaxWidth = width
 	cfg.EnableMouse = mouse
 	cfg.PreserveNewLines = preserveNewLines
 	cfg.RenderMermaid = renderMermaid
-
-	// Run Bubble Tea program
-	if _, err := ui.NewProgram(cfg, content).Run(); err != nil {
-		return fmt.Errorf("unable to run tui program: %w", err)
-	}
-
-	return nil
+	cfg.RenderDot = renderDot
+	cfg.Renderers = renderers
+	cfg.Frontmatter = frontmatter
+	cfg.RenderMath = renderMath
+	cfg.NotebookOutputs = notebookOutputs
+	cfg.Images = images
+	if images == utils.ImagesITerm2 || images == utils.ImagesSixel {
+		// These images are drawn at the cursor, relative to the lines above,
+		// which the pager scrolls away. Half blocks scroll like text.
+		cfg.Images = utils.ImagesHalfblock
+	}
+	cfg.Hyperlinks = hyperlinks == "always"
+	cfg.Languages = languages
+	cfg.TableOptions = tableOptions
+	return cfg, nil
 }
 
 func main() {
@@ -406,6 +745,9 @@ func init() {
 
 	// "Glow Classic" cli arguments
 	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", fmt.Sprintf("config file (default %s)", viper.GetViper().ConfigFileUsed()))
+	rootCmd.PersistentFlags().StringVar(&color, "color", "auto", "colored output: auto (default), always, or never")
+	rootCmd.PersistentFlags().String("color-profile", "", "colors to use: ascii, ansi, ansi256, or truecolor (default: detect)")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "don't cache rendered diagrams on disk")
 	rootCmd.Flags().BoolVarP(&pager, "pager", "p", false, "display with pager")
 	rootCmd.Flags().BoolVarP(&tui, "tui", "t", false, "display with tui")
 	rootCmd.Flags().StringVarP(&style, "style", "s", styles.AutoStyle, "style name or JSON path")
@@ -416,8 +758,20 @@ func init() {
 	rootCmd.Flags().BoolVarP(&mouse, "mouse", "m", false, "enable mouse wheel (TUI-mode only)")
 	_ = rootCmd.Flags().MarkHidden("mouse")
 	rootCmd.Flags().StringVar(&renderMermaid, "render-mermaid", "unicode", "render mermaid diagrams: raw, ascii, or unicode (default)")
+	rootCmd.Flags().StringVar(&renderDot, "render-dot", "unicode", "render Graphviz DOT graphs: raw, ascii, or unicode (default)")
+	rootCmd.Flags().BoolVar(&renderMath, "render-math", true, "render TeX math as Unicode")
+	rootCmd.Flags().BoolVar(&notebookOutputs, "notebook-outputs", true, "show the outputs of notebook cells")
+	rootCmd.Flags().StringVar(&csvDelimiter, "csv-delimiter", "", "delimiter of CSV data (default: detect)")
+	rootCmd.Flags().StringVar(&csvHeader, "csv-header", "auto", "whether CSV data has a header row: auto (default), always, or never")
+	rootCmd.Flags().IntVar(&csvMaxRows, "csv-max-rows", 0, "rows of CSV data to show (set to 0 for all)")
+	rootCmd.Flags().StringVar(&frontmatter, "frontmatter", "hide", "front matter display: hide (default), table, or raw")
+	rootCmd.Flags().StringVar(&hyperlinks, "hyperlinks", "auto", "clickable hyperlinks: auto (default), always, or never")
+	rootCmd.Flags().StringVar(&images, "images", "auto", "image display: auto (default), kitty, iterm2, sixel, halfblock, or none")
 
 	// Config bindings
+	_ = viper.BindPFlag("color", rootCmd.PersistentFlags().Lookup("color"))
+	_ = viper.BindPFlag("colorProfile", rootCmd.PersistentFlags().Lookup("color-profile"))
+	_ = viper.BindPFlag("noCache", rootCmd.PersistentFlags().Lookup("no-cache"))
 	_ = viper.BindPFlag("pager", rootCmd.Flags().Lookup("pager"))
 	_ = viper.BindPFlag("tui", rootCmd.Flags().Lookup("tui"))
 	_ = viper.BindPFlag("style", rootCmd.Flags().Lookup("style"))
@@ -428,13 +782,43 @@ func init() {
 	_ = viper.BindPFlag("showLineNumbers", rootCmd.Flags().Lookup("line-numbers"))
 	_ = viper.BindPFlag("all", rootCmd.Flags().Lookup("all"))
 	_ = viper.BindPFlag("renderMermaid", rootCmd.Flags().Lookup("render-mermaid"))
+	_ = viper.BindPFlag("renderDot", rootCmd.Flags().Lookup("render-dot"))
+	_ = viper.BindPFlag("renderMath", rootCmd.Flags().Lookup("render-math"))
+	_ = viper.BindPFlag("notebookOutputs", rootCmd.Flags().Lookup("notebook-outputs"))
+	_ = viper.BindPFlag("csvDelimiter", rootCmd.Flags().Lookup("csv-delimiter"))
+	_ = viper.BindPFlag("csvHeader", rootCmd.Flags().Lookup("csv-header"))
+	_ = viper.BindPFlag("csvMaxRows", rootCmd.Flags().Lookup("csv-max-rows"))
+	_ = viper.BindPFlag("frontmatter", rootCmd.Flags().Lookup("frontmatter"))
+	_ = viper.BindPFlag("images", rootCmd.Flags().Lookup("images"))
+	_ = viper.BindPFlag("hyperlinks", rootCmd.Flags().Lookup("hyperlinks"))
 
 	viper.SetDefault("style", styles.AutoStyle)
+	viper.SetDefault("theme", ui.DefaultTheme)
+	viper.SetDefault("color", "auto")
 	viper.SetDefault("width", 0)
 	viper.SetDefault("all", true)
 	viper.SetDefault("renderMermaid", "unicode")
-
-	rootCmd.AddCommand(configCmd, manCmd)
+	viper.SetDefault("renderDot", "unicode")
+	viper.SetDefault("frontmatter", "hide")
+	viper.SetDefault("renderMath", true)
+	viper.SetDefault("notebookOutputs", true)
+	viper.SetDefault("csvHeader", utils.TableHeaderAuto)
+	viper.SetDefault("images", "auto")
+	viper.SetDefault("hyperlinks", "auto")
+
+	diffCmd.Flags().BoolVarP(&pager, "pager", "p", false, "display with pager")
+	diffCmd.Flags().BoolVarP(&tui, "tui", "t", false, "display with tui")
+	diffCmd.Flags().StringVarP(&style, "style", "s", styles.AutoStyle, "style name or JSON path")
+	diffCmd.Flags().UintVarP(&width, "width", "w", 0, "word-wrap at width (set to 0 to disable)")
+	statsCmd.Flags().BoolVarP(&showAllFiles, "all", "a", false, "include system files and directories")
+	statsCmd.Flags().StringVarP(&statsFormat, "format", "f", "table", "output format: table or json")
+	statsCmd.Flags().StringVar(&statsSort, "sort", "modified", "sort documents by: modified (stalest first), name, or words")
+	lintDiagramsCmd.Flags().BoolVarP(&showAllFiles, "all", "a", false, "include system files and directories")
+	lintDiagramsCmd.Flags().UintVarP(&lintWidth, "width", "w", defaultLintWidth, "render diagrams at width (set to 0 to disable)")
+	stylePreviewCmd.Flags().BoolVarP(&pager, "pager", "p", false, "display with pager")
+	stylePreviewCmd.Flags().UintVarP(&width, "width", "w", 0, "word-wrap at width (set to 0 to disable)")
+	styleCmd.AddCommand(styleListCmd, stylePreviewCmd, styleExportCmd, styleValidateCmd)
+	rootCmd.AddCommand(configCmd, manCmd, diffCmd, statsCmd, styleCmd, lintDiagramsCmd)
 }
 
 func tryLoadConfigFromDefaultPlaces() {
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/glow/v2/ui"
+	"github.com/charmbracelet/glow/v2/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	statsFormat string
+	statsSort   string
+
+	statsCmd = &cobra.Command{
+		Use:     "stats [FILE|DIR]",
+		Short:   "Show statistics of documents",
+		Long:    paragraph(fmt.Sprintf("\n%s the words, headings, code blocks, mermaid diagrams and links of a document, or of every document in a directory, along with their reading time and when they were last modified. Directories are listed stalest first.", keyword("Count"))),
+		Example: paragraph("glow stats README.md\nglow stats docs\nglow stats --format json --sort words docs"),
+		Args:    cobra.MaximumNArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			// Let the flags of this command take precedence over the config.
+			_ = viper.BindPFlag("all", cmd.Flags().Lookup("all"))
+			if statsFormat != "table" && statsFormat != "json" {
+				return fmt.Errorf("invalid --format value: %s (must be table or json)", statsFormat)
+			}
+			if statsSort != "modified" && statsSort != "name" && statsSort != "words" {
+				return fmt.Errorf("invalid --sort value: %s (must be modified, name, or words)", statsSort)
+			}
+			return validateOptions(cmd)
+		},
+		RunE: executeStats,
+	}
+)
+
+// documentStats are the statistics of a document.
+type documentStats struct {
+	Path string `json:"path,omitempty"`
+	utils.DocumentStats
+	ReadingMinutes int       `json:"reading_minutes"`
+	Modified       time.Time `json:"modified,omitzero"`
+}
+
+func newDocumentStats(path string, s utils.DocumentStats, modified time.Time) documentStats {
+	return documentStats{
+		Path:           path,
+		DocumentStats:  s,
+		ReadingMinutes: s.ReadingMinutes(),
+		Modified:       modified,
+	}
+}
+
+func executeStats(cmd *cobra.Command, args []string) error {
+	arg := "."
+	if len(args) > 0 {
+		arg = args[0]
+	}
+
+	var docs []documentStats
+	if st, err := os.Stat(arg); err == nil && st.IsDir() {
+		if docs, err = directoryStats(arg); err != nil {
+			return err
+		}
+	} else {
+		doc, err := sourceStats(arg)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+
+	slices.SortStableFunc(docs, func(a, b documentStats) int {
+		switch statsSort {
+		case "words":
+			return cmp.Compare(b.Words, a.Words)
+		case "name":
+			return strings.Compare(a.Path, b.Path)
+		}
+		// Stalest first.
+		return cmp.Or(a.Modified.Compare(b.Modified), strings.Compare(a.Path, b.Path))
+	})
+
+	var total utils.DocumentStats
+	for _, d := range docs {
+		total = total.Add(d.DocumentStats)
+	}
+
+	if statsFormat == "json" {
+		return writeStatsJSON(cmd.OutOrStdout(), docs, total)
+	}
+	return writeStatsTable(cmd.OutOrStdout(), docs, total)
+}
+
+// directoryStats returns the statistics of the documents in dir, found like
+// the file browser finds them.
+func directoryStats(dir string) ([]documentStats, error) {
+	cfg, err := tuiConfig()
+	if err != nil {
+		return nil, err
+	}
+	ch, err := ui.FindLocalFiles(cfg, dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find documents: %w", err)
+	}
+
+	var docs []documentStats
+	for res := range ch {
+		b, err := os.ReadFile(res.Path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", res.Path, err)
+		}
+		path := res.Path
+		if rel, err := filepath.Rel(dir, res.Path); err == nil {
+			path = rel
+		}
+		docs = append(docs, newDocumentStats(path, markdownStats(res.Path, b), res.Info.ModTime()))
+	}
+	return docs, nil
+}
+
+// sourceStats returns the statistics of a document read from a file, URL or
+// stdin.
+func sourceStats(arg string) (documentStats, error) {
+	src, err := sourceFromArg(arg)
+	if err != nil {
+		return documentStats{}, err
+	}
+	defer src.reader.Close() //nolint:errcheck
+	b, err := io.ReadAll(src.reader)
+	if err != nil {
+		return documentStats{}, fmt.Errorf("unable to read from reader: %w", err)
+	}
+
+	var modified time.Time
+	if st, err := os.Stat(src.URL); err == nil {
+		modified = st.ModTime()
+	}
+	return newDocumentStats(cmp.Or(arg, src.URL), markdownStats(src.URL, b), modified), nil
+}
+
+// markdownStats returns the statistics of the document, after converting it
+// to markdown.
+func markdownStats(path string, b []byte) utils.DocumentStats {
+	if md, err := toMarkdown(path, b, 0); err == nil {
+		b = md
+	}
+	if utils.IsCodeFile(path, b, languages) {
+		return utils.Stats(utils.WrapCodeBlock(string(b), ""))
+	}
+	return utils.Stats(string(utils.RenderFrontmatter(b, "hide")))
+}
+
+func writeStatsJSON(w io.Writer, docs []documentStats, total utils.DocumentStats) error {
+	if docs == nil {
+		docs = []documentStats{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(struct {
+		Documents []documentStats `json:"documents"`
+		Total     documentStats   `json:"total"`
+	}{
+		Documents: docs,
+		Total:     newDocumentStats("", total, time.Time{}),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write to writer: %w", err)
+	}
+	return nil
+}
+
+func writeStatsTable(w io.Writer, docs []documentStats, total utils.DocumentStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	row := func(modified, document string, s utils.DocumentStats) {
+		fmt.Fprintf(tw, "%s\t%d\t%d min\t%d\t%d\t%d\t%d\t  %s\n", //nolint:errcheck
+			modified, s.Words, s.ReadingMinutes(), s.Headings, s.CodeBlocks, s.Mermaid, s.Links, document)
+	}
+
+	fmt.Fprintln(tw, "MODIFIED\tWORDS\tREADING\tHEADINGS\tCODE\tMERMAID\tLINKS\t  DOCUMENT") //nolint:errcheck
+	for _, d := range docs {
+		modified := "-"
+		if !d.Modified.IsZero() {
+			modified = d.Modified.Format(time.DateOnly)
+		}
+		row(modified, d.Path, d.DocumentStats)
+	}
+	if len(docs) > 1 {
+		row("", fmt.Sprintf("total (%d documents)", len(docs)), total)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("unable to write to writer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/glow/v2/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// stylePreview is the document styles are previewed with. It has every
+// element a style can change.
+const stylePreview = "# Heading 1\n\n" +
+	"## Heading 2\n\n" +
+	"### Heading 3\n\n" +
+	"#### Heading 4\n\n" +
+	"##### Heading 5\n\n" +
+	"###### Heading 6\n\n" +
+	"A paragraph with *emphasis*, **strong** text, ~~strikethrough~~, `inline code`, " +
+	"a [link](https://github.com/charmbracelet/glow) and an autolink: https://charm.sh.\n\n" +
+	"![An image](https://github.com/charmbracelet/glow/raw/master/example.png)\n\n" +
+	"> A block quote, which can span\n> several lines.\n\n" +
+	"> [!NOTE]\n> A callout.\n\n" +
+	"- An item\n- Another item\n  - A nested item\n\n" +
+	"1. An enumeration\n2. Another one\n\n" +
+	"- [x] A done task\n- [ ] A task to do\n\n" +
+	"```go\n// A code block.\nfunc main() {\n\tfmt.Println(\"Hello, world!\", 42)\n}\n```\n\n" +
+	"| Table | Header |\n| ----- | -----: |\n| A     |      1 |\n| B     |      2 |\n\n" +
+	"Term\n: A definition of the term.\n\n" +
+	"<kbd>HTML</kbd> inline, and a block:\n\n<div>HTML</div>\n\n" +
+	"---\n\n" +
+	"The end, after a horizontal rule.\n"
+
+var (
+	styleCmd = &cobra.Command{
+		Use:     "style",
+		Short:   "List, preview, export and validate styles",
+		Long:    paragraph(fmt.Sprintf("\n%s the built-in styles and the JSON styles in the styles directory of the config, which can be chosen by name with --style.", keyword("Manage"))),
+		Example: paragraph("glow style list\nglow style preview dracula\nglow style export dark > ~/.config/glow/styles/mine.json\nglow style validate mine.json"),
+		Args:    cobra.NoArgs,
+		// The style in the config isn't loaded, as it may be the broken one
+		// to validate.
+		PersistentPreRunE: func(*cobra.Command, []string) error { return nil },
+	}
+
+	styleListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the styles",
+		Args:  cobra.NoArgs,
+		RunE:  executeStyleList,
+	}
+
+	stylePreviewCmd = &cobra.Command{
+		Use:   "preview STYLE",
+		Short: "Render a document that shows every element of a style",
+		Args:  cobra.ExactArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Let the flags of this command take precedence over the config.
+			for _, name := range []string{"pager", "width"} {
+				_ = viper.BindPFlag(name, cmd.Flags().Lookup(name))
+			}
+			// Load the previewed style rather than the one in the config.
+			viper.Set("style", args[0])
+			return validateOptions(cmd)
+		},
+		RunE: executeStylePreview,
+	}
+
+	styleExportCmd = &cobra.Command{
+		Use:   "export STYLE",
+		Short: "Print the JSON of a style, as a starting point for your own",
+		Args:  cobra.ExactArgs(1),
+		RunE:  executeStyleExport,
+	}
+
+	styleValidateCmd = &cobra.Command{
+		Use:   "validate FILE",
+		Short: "Check a JSON style for unknown keys and invalid values",
+		Args:  cobra.ExactArgs(1),
+		RunE:  executeStyleValidate,
+	}
+)
+
+// stylesDir returns the directory JSON styles can be chosen by name from.
+func stylesDir() string {
+	return configSubdir("styles")
+}
+
+// findStyle returns the style to render with: a built-in style, or the path
+// of a JSON style, which can be named without its extension when it's in the
+// styles directory.
+func findStyle(style string) (string, error) {
+	if style == styles.AutoStyle || styles.DefaultStyles[style] != nil {
+		return style, nil
+	}
+	if dir := stylesDir(); dir != "" && style != "" && !strings.ContainsAny(style, `/\`) {
+		path := filepath.Join(dir, style+".json")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	path := utils.ExpandPath(style)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("specified style does not exist: %s", path)
+	} else if err != nil {
+		return "", fmt.Errorf("unable to stat file: %w", err)
+	}
+	return style, nil
+}
+
+// customStyles returns the paths of the JSON styles in the styles directory,
+// by name.
+func customStyles() (map[string]string, error) {
+	dir := stylesDir()
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read styles directory: %w", err)
+	}
+	custom := map[string]string{}
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			custom[name] = filepath.Join(dir, e.Name())
+		}
+	}
+	return custom, nil
+}
+
+func executeStyleList(cmd *cobra.Command, _ []string) error {
+	custom, err := customStyles()
+	if err != nil {
+		return err
+	}
+
+	current := viper.GetString("style")
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	row := func(name, source string) {
+		marker := " "
+		if name == current {
+			marker = "*"
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\n", marker, name, source) //nolint:errcheck
+	}
+
+	row(styles.AutoStyle, "built-in, dark or light depending on the terminal")
+	for _, name := range slices.Sorted(maps.Keys(styles.DefaultStyles)) {
+		row(name, "built-in")
+	}
+	for _, name := range slices.Sorted(maps.Keys(custom)) {
+		source := custom[name]
+		if styles.DefaultStyles[name] != nil || name == styles.AutoStyle {
+			source += " (hidden by the built-in style)"
+		}
+		row(name, source)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("unable to write to writer: %w", err)
+	}
+	return nil
+}
+
+func executeStylePreview(cmd *cobra.Command, args []string) error {
+	var err error
+	if style, err = findStyle(args[0]); err != nil {
+		return err
+	}
+	// Don't fetch the example image.
+	images = utils.ImagesNone
+	src := &source{reader: io.NopCloser(strings.NewReader(stylePreview)), URL: "preview.md"}
+	return executeCLI(cmd, src, cmd.OutOrStdout())
+}
+
+func executeStyleExport(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if name == styles.AutoStyle {
+		return fmt.Errorf("%s picks the %s or %s style: export one of them instead", name, styles.DarkStyle, styles.LightStyle)
+	}
+
+	var b []byte
+	if s := styles.DefaultStyles[name]; s != nil {
+		var err error
+		if b, err = json.MarshalIndent(s, "", "  "); err != nil {
+			return fmt.Errorf("unable to encode style: %w", err)
+		}
+		b = append(b, '\n')
+	} else {
+		path, err := findStyle(name)
+		if err != nil {
+			return err
+		}
+		if b, err = os.ReadFile(utils.ExpandPath(path)); err != nil {
+			return fmt.Errorf("unable to read style: %w", err)
+		}
+	}
+
+	if _, err := cmd.OutOrStdout().Write(b); err != nil {
+		return fmt.Errorf("unable to write to writer: %w", err)
+	}
+	return nil
+}
+
+func executeStyleValidate(cmd *cobra.Command, args []string) error {
+	path, err := findStyle(args[0])
+	if err != nil {
+		return err
+	}
+	if styles.DefaultStyles[path] != nil || path == styles.AutoStyle {
+		return fmt.Errorf("%s is a built-in style: validate a JSON file", path)
+	}
+	b, err := os.ReadFile(utils.ExpandPath(path))
+	if err != nil {
+		return fmt.Errorf("unable to read style: %w", err)
+	}
+
+	issues, err := utils.ValidateStyle(b)
+	for _, issue := range issues {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s:%s\n", path, issue) //nolint:errcheck
+	}
+	if err != nil {
+		return fmt.Errorf("invalid JSON in %s:%w", path, err)
+	}
+	switch len(issues) {
+	case 0:
+	case 1:
+		return fmt.Errorf("found 1 problem in %s", path)
+	default:
+		return fmt.Errorf("found %d problems in %s", len(issues), path)
+	}
+	// The issues found explain why a style doesn't load better than the
+	// error of loading it.
+	if _, err := utils.LoadStyle(path, nil); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "%s is a valid style\n", path) //nolint:errcheck
+	return nil
+}
@@ -1,5 +1,7 @@
 package ui
 
+import "github.com/charmbracelet/glow/v2/utils"
+
 // Config contains TUI-specific configuration.
 type Config struct {
 	ShowAllFiles     bool
@@ -8,14 +10,37 @@ type Config struct {
 	HomeDir          string `env:"HOME"`
 	GlamourMaxWidth  uint
 	GlamourStyle     string `env:"GLAMOUR_STYLE"`
+	StyleOverrides   map[string]any
+	Theme            *Theme // colors of the TUI, or nil for the default
 	EnableMouse      bool
 	PreserveNewLines bool
 	RenderMermaid    string
+	RenderDot        string
+	Frontmatter      string
+	RenderMath       bool
+	NotebookOutputs  bool
+	Images           string
+	Hyperlinks       bool
+	Languages        map[string]string
+	Renderers        utils.BlockRenderers
+	TableOptions     utils.TableOptions
 
 	// Working directory or file path
 	Path string
 
+	// Comparison of two documents to show instead of Path
+	Diff *Diff
+
 	// For debugging the UI
 	HighPerformancePager bool `env:"GLOW_HIGH_PERFORMANCE_PAGER" envDefault:"true"`
 	GlamourEnabled       bool `env:"GLOW_ENABLE_GLAMOUR"         envDefault:"true"`
 }
+
+// Diff is a rendered comparison of two documents.
+type Diff struct {
+	Title string
+
+	// Render renders the comparison at the given width. It returns the
+	// output and the lines at which changes start.
+	Render func(width int) (string, []int, error)
+}
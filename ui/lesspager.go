@@ -0,0 +1,225 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// RunPager shows rendered output in a pager with the keys of less, for when
+// the external pager can't be found. Unlike the TUI it doesn't use the
+// alternate screen, so the last page read stays in the terminal.
+func RunPager(content string, theme *Theme) error {
+	if theme != nil {
+		useTheme(*theme)
+	}
+	p := tea.NewProgram(newLessModel(content), tea.WithInputTTY())
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("unable to run pager: %w", err)
+	}
+	return nil
+}
+
+// lessModel is the model of the built-in pager.
+type lessModel struct {
+	viewport viewport.Model
+	input    textinput.Model
+	content  string
+
+	// lines is the content without escape sequences, for searching.
+	lines []string
+
+	ready     bool
+	searching bool
+	// backward is whether the last search was started with ?.
+	backward bool
+	pattern  string
+	message  string
+}
+
+func newLessModel(content string) lessModel {
+	content = strings.TrimSuffix(content, "\n")
+	input := textinput.New()
+	input.Prompt = "/"
+
+	return lessModel{
+		input:   input,
+		content: content,
+		lines:   strings.Split(ansi.Strip(content), "\n"),
+	}
+}
+
+func (m lessModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m lessModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave a line for the status bar.
+		height := max(1, msg.Height-statusBarHeight)
+		if !m.ready {
+			m.viewport = viewport.New(msg.Width, height)
+			m.viewport.SetHorizontalStep(msg.Width / 2)
+			m.viewport.SetContent(m.content)
+			m.ready = true
+		} else {
+			m.viewport.Width = msg.Width
+			m.viewport.Height = height
+			m.viewport.SetYOffset(m.viewport.YOffset)
+		}
+		m.input.Width = msg.Width - 2
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.searching {
+			return m.updateSearch(msg)
+		}
+		if !m.ready {
+			if msg.String() == "ctrl+c" || msg.String() == "q" {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		m.message = ""
+
+		switch msg.String() {
+		case "q", "Q", "ctrl+c", "Z":
+			return m, tea.Quit
+		case "j", "e", "down", keyEnter, "ctrl+n", "ctrl+e", "ctrl+j":
+			m.viewport.ScrollDown(1)
+		case "k", "y", "up", "ctrl+p", "ctrl+y", "ctrl+k":
+			m.viewport.ScrollUp(1)
+		case "f", " ", "pgdown", "ctrl+f", "ctrl+v", "z":
+			m.viewport.PageDown()
+		case "b", "pgup", "ctrl+b", "alt+v", "w":
+			m.viewport.PageUp()
+		case "d", "ctrl+d":
+			m.viewport.HalfPageDown()
+		case "u", "ctrl+u":
+			m.viewport.HalfPageUp()
+		case "g", "<", "home":
+			m.viewport.GotoTop()
+		case "G", ">", "end":
+			m.viewport.GotoBottom()
+		case "left":
+			m.viewport.ScrollLeft(m.viewport.Width / 2)
+		case "right":
+			m.viewport.ScrollRight(m.viewport.Width / 2)
+		case "/", "?":
+			m.searching = true
+			m.backward = msg.String() == "?"
+			m.input.Prompt = msg.String()
+			m.input.Reset()
+			return m, m.input.Focus()
+		case "n":
+			m.search(m.backward)
+		case "N":
+			m.search(!m.backward)
+		}
+		return m, nil
+
+	case tea.MouseMsg:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// updateSearch handles the keys typed while entering a pattern.
+func (m lessModel) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case keyEsc, "ctrl+c", "ctrl+g":
+		m.searching = false
+		m.input.Blur()
+		return m, nil
+	case keyEnter:
+		m.searching = false
+		m.input.Blur()
+		// An empty pattern repeats the last search, like in less.
+		if v := m.input.Value(); v != "" {
+			m.pattern = v
+		}
+		m.search(m.backward)
+		return m, nil
+	case "backspace":
+		if m.input.Value() == "" {
+			m.searching = false
+			m.input.Blur()
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// search scrolls to the next line matching the pattern, after the top line of
+// the page or, backward, before it. The pattern ignores case unless it has
+// upper case letters, like with less -i.
+func (m *lessModel) search(backward bool) {
+	if m.pattern == "" {
+		m.message = "No previous search pattern"
+		return
+	}
+	if line := m.find(m.pattern, m.viewport.YOffset, backward); line >= 0 {
+		m.viewport.SetYOffset(line)
+		return
+	}
+	m.message = "Pattern not found"
+}
+
+// find returns the first line matching pattern after from, or before it when
+// searching backward, or -1.
+func (m lessModel) find(pattern string, from int, backward bool) int {
+	matches := strings.Contains
+	if !strings.ContainsFunc(pattern, unicode.IsUpper) {
+		pattern = strings.ToLower(pattern)
+		matches = func(s, substr string) bool {
+			return strings.Contains(strings.ToLower(s), substr)
+		}
+	}
+
+	step := 1
+	if backward {
+		step = -1
+	}
+	for i := from + step; i >= 0 && i < len(m.lines); i += step {
+		if matches(m.lines[i], pattern) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m lessModel) View() string {
+	if !m.ready {
+		return ""
+	}
+	return m.viewport.View() + "\n" + m.statusBarView()
+}
+
+// statusBarView shows the search prompt, a message, or the lines on screen.
+func (m lessModel) statusBarView() string {
+	switch {
+	case m.searching:
+		return m.input.View()
+	case m.message != "":
+		return statusBarMessageStyle(" " + m.message + " ")
+	}
+
+	total := m.viewport.TotalLineCount()
+	first := min(total, m.viewport.YOffset+1)
+	last := min(total, m.viewport.YOffset+m.viewport.Height)
+	status := fmt.Sprintf(" lines %d-%d/%d ", first, last, total)
+	if m.viewport.AtBottom() {
+		status += "(END) "
+	}
+	return statusBarNoteStyle(status) + statusBarHelpStyle(" q quit  / search ")
+}
@@ -1,9 +1,13 @@
 package ui
 
 import (
+	"cmp"
+	"context"
 	"fmt"
+	"maps"
 	"math"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,6 +18,7 @@ import (
 	"github.com/charmbracelet/glow/v2/utils"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
+	xansi "github.com/charmbracelet/x/ansi"
 	"github.com/fsnotify/fsnotify"
 	runewidth "github.com/mattn/go-runewidth"
 	"github.com/muesli/reflow/ansi"
@@ -29,57 +34,31 @@ const (
 var (
 	pagerHelpHeight int
 
-	mintGreen = lipgloss.AdaptiveColor{Light: "#89F0CB", Dark: "#89F0CB"}
-	darkGreen = lipgloss.AdaptiveColor{Light: "#1C8760", Dark: "#1C8760"}
-
-	lineNumberFg = lipgloss.AdaptiveColor{Light: "#656565", Dark: "#7D7D7D"}
-
-	statusBarNoteFg = lipgloss.AdaptiveColor{Light: "#656565", Dark: "#7D7D7D"}
-	statusBarBg     = lipgloss.AdaptiveColor{Light: "#E6E6E6", Dark: "#242424"}
-
-	statusBarScrollPosStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.AdaptiveColor{Light: "#949494", Dark: "#5A5A5A"}).
-				Background(statusBarBg).
-				Render
-
-	statusBarNoteStyle = lipgloss.NewStyle().
-				Foreground(statusBarNoteFg).
-				Background(statusBarBg).
-				Render
-
-	statusBarHelpStyle = lipgloss.NewStyle().
-				Foreground(statusBarNoteFg).
-				Background(lipgloss.AdaptiveColor{Light: "#DCDCDC", Dark: "#323232"}).
-				Render
-
-	statusBarMessageStyle = lipgloss.NewStyle().
-				Foreground(mintGreen).
-				Background(darkGreen).
-				Render
-
-	statusBarMessageScrollPosStyle = lipgloss.NewStyle().
-					Foreground(mintGreen).
-					Background(darkGreen).
-					Render
-
-	statusBarMessageHelpStyle = lipgloss.NewStyle().
-					Foreground(lipgloss.Color("#B6FFE4")).
-					Background(green).
-					Render
-
-	helpViewStyle = lipgloss.NewStyle().
-			Foreground(statusBarNoteFg).
-			Background(lipgloss.AdaptiveColor{Light: "#f2f2f2", Dark: "#1B1B1B"}).
-			Render
-
-	lineNumberStyle = lipgloss.NewStyle().
-			Foreground(lineNumberFg).
-			Render
+	// Set by useTheme.
+	statusBarNoteStyle             func(...string) string
+	statusBarScrollPosStyle        func(...string) string
+	statusBarHelpStyle             func(...string) string
+	statusBarMessageStyle          func(...string) string
+	statusBarMessageScrollPosStyle func(...string) string
+	statusBarMessageHelpStyle      func(...string) string
+	statusBarErrorStyle            func(...string) string
+	helpViewStyle                  func(...string) string
+	lineNumberStyle                func(...string) string
+	scrollMarkerStyle              func(...string) string
 )
 
 type (
-	contentRenderedMsg string
-	reloadMsg          struct{}
+	contentRenderedMsg struct {
+		content  string
+		includes []string // absolute paths of included files
+		changes  []int    // lines at which the changes of a diff start
+
+		lineNumbers    bool
+		readingMinutes int
+		diagramErrors  int
+		diagrams       []utils.MermaidDiagram
+	}
+	reloadMsg struct{}
 )
 
 type pagerState int
@@ -92,6 +71,21 @@ const (
 type pagerModel struct {
 	common   *commonModel
 	viewport viewport.Model
+
+	// Lines and width of the widest line of the document, and how far it's
+	// scrolled to the right. The viewport scrolls vertically, while the pager
+	// cuts lines itself, to keep line numbers in place and mark what's cut.
+	lines        []string
+	contentWidth int
+	xOffset      int
+
+	// Whether the document is shown with line numbers, like code.
+	lineNumbers bool
+
+	// Estimated reading time of the document in minutes, shown in the
+	// status bar
+	readingMinutes int
+
 	state    pagerState
 	showHelp bool
 
@@ -107,6 +101,37 @@ type pagerModel struct {
 	preprocessedMarkdown string
 	preprocessedIsCode   bool
 
+	// Diagrams of the document that failed to render.
+	diagramErrors int
+
+	// How mermaid diagrams are shown: raw, ascii or unicode, and the sources
+	// of the diagrams toggled between their source and rendering. The map is
+	// replaced rather than changed, as it's read while rendering.
+	mermaidMode    string
+	toggledDiagram map[string]bool
+
+	// The document before its mermaid diagrams were rendered, to find them.
+	diagramSource string
+
+	// The mermaid diagrams of the document and where they are, and the
+	// diagram to keep at the same row of the pager after rendering again.
+	diagrams      []utils.MermaidDiagram
+	diagramAnchor *diagramAnchor
+
+	// Files included by the current document. We watch these alongside the
+	// document itself.
+	includes []string
+
+	// How front matter is currently displayed: "hide", "table" or "raw".
+	frontmatter string
+
+	// Lines at which the changes of a diff start.
+	changes []int
+
+	// Cancels the rendering in progress, when the document is unloaded or
+	// rendered again.
+	cancelRender context.CancelFunc
+
 	watcher *fsnotify.Watcher
 }
 
@@ -115,18 +140,22 @@ func newPagerModel(common *commonModel) pagerModel {
 	vp := viewport.New(0, 0)
 	vp.YPosition = 0
 	vp.HighPerformanceRendering = config.HighPerformancePager
+	vp.KeyMap.Left.SetEnabled(false)
+	vp.KeyMap.Right.SetEnabled(false)
 
 	m := pagerModel{
-		common:   common,
-		state:    pagerStateBrowse,
-		viewport: vp,
+		common:      common,
+		state:       pagerStateBrowse,
+		viewport:    vp,
+		frontmatter: common.cfg.Frontmatter,
+		mermaidMode: common.cfg.RenderMermaid,
 	}
 	m.initWatcher()
 	return m
 }
 
 func (m *pagerModel) setSize(w, h int) {
-	m.viewport.Width = w
+	m.viewport.Width = max(0, w-m.gutterWidth())
 	m.viewport.Height = h - statusBarHeight
 
 	if m.showHelp {
@@ -139,6 +168,105 @@ func (m *pagerModel) setSize(w, h int) {
 
 func (m *pagerModel) setContent(s string) {
 	m.viewport.SetContent(s)
+	m.lines = strings.Split(s, "\n")
+	m.contentWidth = lipgloss.Width(s)
+	m.scrollRight(0)
+}
+
+func (m pagerModel) gutterWidth() int {
+	if m.lineNumbers {
+		return lineNumberWidth
+	}
+	return 0
+}
+
+// scrolledRight returns whether the document is wider than the pager, like a
+// table, and is scrolled to the right.
+func (m pagerModel) scrolledRight() bool {
+	return m.xOffset > 0
+}
+
+// scrollRight scrolls the document n columns to the right, or to the left
+// when n is negative, no further than its widest line.
+func (m *pagerModel) scrollRight(n int) {
+	m.xOffset = max(0, min(m.xOffset+n, m.contentWidth-m.viewport.Width))
+}
+
+// horizontalStep is how far the document is scrolled to the side at once.
+func (m pagerModel) horizontalStep() int {
+	return max(1, m.viewport.Width/4)
+}
+
+// visibleLines returns the lines of the document on screen, cut to the width
+// of the pager at the horizontal offset, with line numbers, and with markers
+// at the edges of lines with more to the left or right.
+func (m pagerModel) visibleLines() []string {
+	w := m.viewport.Width
+	lines := make([]string, m.viewport.Height)
+	for i := range lines {
+		n := m.viewport.YOffset + i
+		if n >= len(m.lines) {
+			break
+		}
+		var b strings.Builder
+		if m.lineNumbers {
+			b.WriteString(lineNumberStyle(fmt.Sprintf("%*d", lineNumberWidth, n+1)))
+		}
+		if w > 0 {
+			b.WriteString(cutLine(m.lines[n], m.xOffset, w))
+		}
+		lines[i] = b.String()
+	}
+	return lines
+}
+
+// cutLine returns w columns of a line from column x, replacing the first and
+// last column with markers when the line goes on past them.
+func cutLine(line string, x, w int) string {
+	width := xansi.StringWidth(line)
+	// Wide characters cut in half at an edge go on past it too: they're left
+	// out of what Truncate keeps, and in what TruncateLeft keeps.
+	before := xansi.Truncate(line, x, "")
+	left := x > 0 && (strings.TrimSpace(xansi.Strip(before)) != "" || xansi.StringWidth(before) < min(x, width))
+	right := width > x+w && strings.TrimSpace(xansi.Strip(xansi.TruncateLeft(line, x+w, ""))) != ""
+
+	start, end := x, x+w
+	if left {
+		start++
+	}
+	if right {
+		end--
+	}
+	s := cut(line, start, end)
+	if left {
+		s = scrollMarkerStyle("‹") + s
+	}
+	if right {
+		s += strings.Repeat(" ", max(0, end-start-xansi.StringWidth(s))) + scrollMarkerStyle("›")
+	}
+	return s
+}
+
+// cut returns the columns of s from start to end, keeping its escape
+// sequences. Wide characters cut in half are left out, with a space in place
+// of their half at the start, to keep columns aligned.
+func cut(s string, start, end int) string {
+	t := xansi.TruncateLeft(s, start, "")
+	if xansi.StringWidth(t) > max(0, xansi.StringWidth(s)-start) {
+		t = " " + xansi.TruncateLeft(s, start+1, "")
+	}
+	return xansi.Truncate(t, end-start, "")
+}
+
+// sync repaints the pager in high performance mode, where the viewport only
+// draws what it's sent.
+func (m pagerModel) sync() tea.Cmd {
+	top := max(0, m.viewport.YPosition)
+	bottom := max(top, top+m.viewport.Height)
+	if top > 0 && bottom > top {
+		bottom--
+	}
+	return tea.SyncScrollArea(m.visibleLines(), top, bottom) //nolint:staticcheck
 }
 
 func (m *pagerModel) toggleHelp() {
@@ -149,6 +277,120 @@ func (m *pagerModel) toggleHelp() {
 	}
 }
 
+// toggleMetadata switches between showing the front matter as a metadata
+// table and the configured display mode.
+func (m *pagerModel) toggleMetadata() {
+	switch {
+	case m.frontmatter != "table":
+		m.frontmatter = "table"
+	case m.common.cfg.Frontmatter != "table":
+		m.frontmatter = m.common.cfg.Frontmatter
+	default:
+		m.frontmatter = "hide"
+	}
+	m.preprocessedMarkdown = "" // Clear cache
+}
+
+// diagramAnchor is a diagram, by index, and the row of the pager it starts
+// at.
+type diagramAnchor struct {
+	index, row int
+}
+
+// mermaidModes are the ways to show mermaid diagrams, in the order they're
+// cycled through.
+var mermaidModes = []string{"raw", "ascii", "unicode"}
+
+// cycleMermaidMode switches all the mermaid diagrams of the document to the
+// next way of showing them, and returns a status message.
+func (m *pagerModel) cycleMermaidMode() string {
+	m.anchorDiagram()
+	i := slices.Index(mermaidModes, m.mermaidMode)
+	m.mermaidMode = mermaidModes[(i+1)%len(mermaidModes)]
+	m.toggledDiagram = nil
+	m.preprocessedMarkdown = "" // Clear cache
+
+	if m.mermaidMode == "raw" {
+		return "Showing the source of diagrams"
+	}
+	return "Showing diagrams as " + m.mermaidMode
+}
+
+// toggleDiagram switches the diagram in view between its source and its
+// rendering, and returns a status message, or "" if there's no diagram in
+// view.
+func (m *pagerModel) toggleDiagram() string {
+	i := m.anchorDiagram()
+	if i < 0 {
+		return ""
+	}
+	source := m.diagrams[i].Source
+	toggled := maps.Clone(m.toggledDiagram)
+	if toggled == nil {
+		toggled = map[string]bool{}
+	}
+	if toggled[source] {
+		delete(toggled, source)
+	} else {
+		toggled[source] = true
+	}
+	m.toggledDiagram = toggled
+	m.preprocessedMarkdown = "" // Clear cache
+
+	if _, keep := m.mermaidRender(); keep(source) {
+		return "Showing the source of the diagram"
+	}
+	return "Showing the rendered diagram"
+}
+
+// mermaidRender returns the mode to render mermaid diagrams in, and whether
+// a diagram is kept as source: the toggled ones, or, when diagrams are shown
+// as source, the ones that aren't, which are rendered like at startup.
+func (m pagerModel) mermaidRender() (string, func(source string) bool) {
+	toggled := m.toggledDiagram
+	if m.mermaidMode != "raw" {
+		return m.mermaidMode, func(source string) bool { return toggled[source] }
+	}
+	mode := m.common.cfg.RenderMermaid
+	if mode == "raw" {
+		mode = "unicode"
+	}
+	return mode, func(source string) bool { return !toggled[source] }
+}
+
+// anchorDiagram remembers where the diagram in view is, to keep it there
+// after rendering again, and returns its index, or -1. The diagram in view is
+// the first one starting in the pager, or else the last one starting above.
+func (m *pagerModel) anchorDiagram() int {
+	index := -1
+	top, bottom := m.viewport.YOffset, m.viewport.YOffset+m.viewport.Height
+	for i, d := range m.diagrams {
+		if d.Line < 0 || d.Line >= bottom {
+			continue
+		}
+		index = i
+		if d.Line >= top {
+			break
+		}
+	}
+	if index < 0 {
+		m.diagramAnchor = nil
+		return -1
+	}
+	m.diagramAnchor = &diagramAnchor{index: index, row: m.diagrams[index].Line - top}
+	return index
+}
+
+// restoreDiagramAnchor scrolls the anchored diagram back to its row.
+func (m *pagerModel) restoreDiagramAnchor() {
+	a := m.diagramAnchor
+	m.diagramAnchor = nil
+	if a == nil || a.index >= len(m.diagrams) || m.diagrams[a.index].Line < 0 {
+		return
+	}
+	m.viewport.SetYOffset(max(0, m.diagrams[a.index].Line-a.row))
+}
+
 type pagerStatusMessage struct {
 	message string
 	isError bool
@@ -178,10 +420,15 @@ func (m *pagerModel) unload() {
 		m.statusMessageTimer.Stop()
 	}
 	m.state = pagerStateBrowse
-	m.viewport.SetContent("")
+	m.setContent("")
 	m.viewport.YOffset = 0
 	m.preprocessedMarkdown = "" // Clear cache
+	m.toggledDiagram = nil
+	m.diagrams = nil
+	m.diagramAnchor = nil
+	m.stopRender()
 	m.unwatchFile()
+	m.includes = nil
 }
 
 func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
@@ -201,24 +448,34 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 		case "home", "g":
 			m.viewport.GotoTop()
 			if m.viewport.HighPerformanceRendering {
-				cmds = append(cmds, viewport.Sync(m.viewport))
+				cmds = append(cmds, m.sync())
 			}
 		case "end", "G":
 			m.viewport.GotoBottom()
 			if m.viewport.HighPerformanceRendering {
-				cmds = append(cmds, viewport.Sync(m.viewport))
+				cmds = append(cmds, m.sync())
+			}
+
+		case "left", "h", "right", "l":
+			step := m.horizontalStep()
+			if k := msg.String(); k == "left" || k == "h" {
+				step = -step
+			}
+			m.scrollRight(step)
+			if m.viewport.HighPerformanceRendering {
+				cmds = append(cmds, m.sync())
 			}
 
 		case "d":
 			m.viewport.HalfViewDown()
 			if m.viewport.HighPerformanceRendering {
-				cmds = append(cmds, viewport.Sync(m.viewport))
+				cmds = append(cmds, m.sync())
 			}
 
 		case "u":
 			m.viewport.HalfViewUp()
 			if m.viewport.HighPerformanceRendering {
-				cmds = append(cmds, viewport.Sync(m.viewport))
+				cmds = append(cmds, m.sync())
 			}
 
 		case "e":
@@ -243,20 +500,81 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 		case "r":
 			return m, loadLocalMarkdown(&m.currentDocument)
 
+		case "m":
+			m.toggleMetadata()
+			cmd := m.render(m.currentDocument.Body)
+			return m, cmd
+
+		case "v", "V":
+			if m.common.cfg.Diff != nil || !m.common.cfg.GlamourEnabled {
+				break
+			}
+			var status string
+			if msg.String() == "v" {
+				status = m.cycleMermaidMode()
+			} else if status = m.toggleDiagram(); status == "" {
+				cmds = append(cmds, m.showStatusMessage(pagerStatusMessage{"No diagram in view", false}))
+				break
+			}
+			return m, tea.Batch(
+				m.render(m.currentDocument.Body),
+				m.showStatusMessage(pagerStatusMessage{status, false}),
+			)
+
+		case "n", "N":
+			if m.common.cfg.Diff == nil {
+				break
+			}
+			if !m.gotoChange(msg.String() == "n") {
+				cmds = append(cmds, m.showStatusMessage(pagerStatusMessage{"No more changes", false}))
+			}
+			if m.viewport.HighPerformanceRendering {
+				cmds = append(cmds, m.sync())
+			}
+
 		case "?":
 			m.toggleHelp()
 			if m.viewport.HighPerformanceRendering {
-				cmds = append(cmds, viewport.Sync(m.viewport))
+				cmds = append(cmds, m.sync())
 			}
 		}
 
+	// The wheel scrolls to the side when it's tilted or shift is held.
+	case tea.MouseMsg:
+		if msg.Action != tea.MouseActionPress {
+			break
+		}
+		var step int
+		switch {
+		case msg.Button == tea.MouseButtonWheelLeft, msg.Shift && msg.Button == tea.MouseButtonWheelUp:
+			step = -m.horizontalStep()
+		case msg.Button == tea.MouseButtonWheelRight, msg.Shift && msg.Button == tea.MouseButtonWheelDown:
+			step = m.horizontalStep()
+		}
+		if step == 0 {
+			break
+		}
+		m.scrollRight(step)
+		if m.viewport.HighPerformanceRendering {
+			return m, m.sync()
+		}
+		return m, nil
+
 	// Glow has rendered the content
 	case contentRenderedMsg:
 		log.Info("content rendered", "state", m.state)
 
-		m.setContent(string(msg))
+		m.lineNumbers = msg.lineNumbers
+		m.setSize(m.common.width, m.common.height)
+		m.setContent(msg.content)
+		m.includes = msg.includes
+		m.changes = msg.changes
+		m.readingMinutes = msg.readingMinutes
+		m.diagramErrors = msg.diagramErrors
+		m.diagrams = msg.diagrams
+		m.restoreDiagramAnchor()
 		if m.viewport.HighPerformanceRendering {
-			cmds = append(cmds, viewport.Sync(m.viewport))
+			cmds = append(cmds, m.sync())
 		}
 		cmds = append(cmds, m.watchFile)
 
@@ -273,21 +591,52 @@ func (m pagerModel) update(msg tea.Msg) (pagerModel, tea.Cmd) {
 	// We've received terminal dimensions, either for the first time or
 	// after a resize
 	case tea.WindowSizeMsg:
-		return m, renderWithGlamour(&m, m.currentDocument.Body)
+		cmd := m.render(m.currentDocument.Body)
+		return m, cmd
 
 	case statusMessageTimeoutMsg:
 		m.state = pagerStateBrowse
 	}
 
+	yOffset := m.viewport.YOffset
 	m.viewport, cmd = m.viewport.Update(msg)
+	if m.viewport.HighPerformanceRendering && m.viewport.YOffset != yOffset {
+		// The viewport would draw the lines without cutting them.
+		cmd = m.sync()
+	}
 	cmds = append(cmds, cmd)
 
 	return m, tea.Batch(cmds...)
 }
 
+// gotoChange scrolls to the next or previous change of a diff. It returns
+// false if there is none.
+func (m *pagerModel) gotoChange(next bool) bool {
+	offset := m.viewport.YOffset
+	if next {
+		i := slices.IndexFunc(m.changes, func(line int) bool { return line > offset })
+		if i < 0 || m.viewport.AtBottom() {
+			return false
+		}
+		m.viewport.SetYOffset(m.changes[i])
+		return true
+	}
+	for _, line := range slices.Backward(m.changes) {
+		if line < offset {
+			m.viewport.SetYOffset(line)
+			return true
+		}
+	}
+	return false
+}
+
 func (m pagerModel) View() string {
 	var b strings.Builder
-	fmt.Fprint(&b, m.viewport.View()+"\n")
+	if m.viewport.HighPerformanceRendering {
+		fmt.Fprint(&b, m.viewport.View()+"\n")
+	} else {
+		fmt.Fprint(&b, strings.Join(m.visibleLines(), "\n")+"\n")
+	}
 
 	// Footer
 	m.statusBarView(&b)
@@ -328,6 +677,22 @@ func (m pagerModel) statusBarView(b *strings.Builder) {
 		helpNote = statusBarHelpStyle(" ? Help ")
 	}
 
+	// Reading time
+	var readingTime string
+	if m.readingMinutes > 0 && !showStatusMessage {
+		readingTime = statusBarNoteStyle(fmt.Sprintf(" %d min read ", m.readingMinutes))
+	}
+
+	// Diagram errors
+	var diagramErrors string
+	if m.diagramErrors > 0 && !showStatusMessage {
+		diagramErrors = fmt.Sprintf(" %d diagram errors ", m.diagramErrors)
+		if m.diagramErrors == 1 {
+			diagramErrors = " 1 diagram error "
+		}
+		diagramErrors = statusBarErrorStyle(diagramErrors)
+	}
+
 	// Note
 	var note string
 	if showStatusMessage {
@@ -338,6 +703,8 @@ func (m pagerModel) statusBarView(b *strings.Builder) {
 	note = truncate.StringWithTail(" "+note+" ", uint(max(0, //nolint:gosec
 		m.common.width-
 			ansi.PrintableRuneWidth(logo)-
+			ansi.PrintableRuneWidth(diagramErrors)-
+			ansi.PrintableRuneWidth(readingTime)-
 			ansi.PrintableRuneWidth(scrollPercent)-
 			ansi.PrintableRuneWidth(helpNote),
 	)), ellipsis)
@@ -352,6 +719,8 @@ func (m pagerModel) statusBarView(b *strings.Builder) {
 		m.common.width-
 			ansi.PrintableRuneWidth(logo)-
 			ansi.PrintableRuneWidth(note)-
+			ansi.PrintableRuneWidth(diagramErrors)-
+			ansi.PrintableRuneWidth(readingTime)-
 			ansi.PrintableRuneWidth(scrollPercent)-
 			ansi.PrintableRuneWidth(helpNote),
 	)
@@ -362,36 +731,58 @@ func (m pagerModel) statusBarView(b *strings.Builder) {
 		emptySpace = statusBarNoteStyle(emptySpace)
 	}
 
-	fmt.Fprintf(b, "%s%s%s%s%s",
+	fmt.Fprintf(b, "%s%s%s%s%s%s%s",
 		logo,
 		note,
 		emptySpace,
+		diagramErrors,
+		readingTime,
 		scrollPercent,
 		helpNote,
 	)
 }
 
 func (m pagerModel) helpView() (s string) {
+	col0 := []string{
+		"k/↑      up",
+		"j/↓      down",
+		"b/pgup   page up",
+		"f/pgdn   page down",
+		"u        ½ page up",
+		"d        ½ page down",
+		"h/←      scroll left",
+		"l/→      scroll right",
+	}
+
 	col1 := []string{
 		"g/home  go to top",
 		"G/end   go to bottom",
 		"c       copy contents",
 		"e       edit this document",
 		"r       reload this document",
+		"m       toggle metadata",
+		"v       cycle diagram views",
+		"V       toggle diagram in view",
 		"esc     back to files",
 		"q       quit",
 	}
+	if m.common.cfg.Diff != nil {
+		col1 = append(col1[:2], "n/N     next/previous change", "c       copy contents", "q       quit")
+	}
 
 	s += "\n"
-	s += "k/↑      up                  " + col1[0] + "\n"
-	s += "j/↓      down                " + col1[1] + "\n"
-	s += "b/pgup   page up             " + col1[2] + "\n"
-	s += "f/pgdn   page down           " + col1[3] + "\n"
-	s += "u        ½ page up           " + col1[4] + "\n"
-	s += "d        ½ page down         "
-
-	if len(col1) > 5 {
-		s += col1[5]
+	for i := range max(len(col0), len(col1)) {
+		var left, right string
+		if i < len(col0) {
+			left = col0[i]
+		}
+		if i < len(col1) {
+			right = col1[i]
+		}
+		s += fmt.Sprintf("%-29s%s", left, right)
+		if i+1 < max(len(col0), len(col1)) {
+			s += "\n"
+		}
 	}
 
 	s = indent(s, 2)
@@ -411,35 +802,78 @@ func (m pagerModel) helpView() (s string) {
 	return helpViewStyle(s)
 }
 
+// render renders md, after stopping the rendering in progress, whose output
+// is outdated.
+func (m *pagerModel) render(md string) tea.Cmd {
+	m.stopRender()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelRender = cancel
+	return renderWithGlamour(ctx, m, md)
+}
+
+func (m *pagerModel) stopRender() {
+	if m.cancelRender != nil {
+		m.cancelRender()
+		m.cancelRender = nil
+	}
+}
+
 // COMMANDS
 
-func renderWithGlamour(m *pagerModel, md string) tea.Cmd {
+func renderWithGlamour(ctx context.Context, m *pagerModel, md string) tea.Cmd {
 	return func() tea.Msg {
-		s, err := glamourRender(m, md)
+		if m.common.cfg.Diff != nil {
+			return renderDiff(m)
+		}
+		s, err := glamourRender(ctx, m, md)
+		if ctx.Err() != nil {
+			// Rendered again or unloaded in the meantime.
+			return nil
+		}
 		if err != nil {
 			log.Error("error rendering with Glamour", "error", err)
 			return errMsg{err}
 		}
-		return contentRenderedMsg(s)
+		msg := contentRenderedMsg{content: s, lineNumbers: m.lineNumbers, includes: m.includes, diagramErrors: m.diagramErrors, diagrams: m.diagrams}
+		if !m.preprocessedIsCode {
+			msg.readingMinutes = utils.Stats(m.preprocessedMarkdown).ReadingMinutes()
+		}
+		return msg
 	}
 }
 
-// This is where the magic happens.
-func glamourRender(m *pagerModel, markdown string) (string, error) {
-	trunc := lipgloss.NewStyle().MaxWidth(m.viewport.Width - lineNumberWidth).Render
+// renderDiff renders the comparison of two documents.
+func renderDiff(m *pagerModel) tea.Msg {
+	width := max(0, min(int(m.common.cfg.GlamourMaxWidth), m.viewport.Width)) //nolint:gosec
+	s, changes, err := m.common.cfg.Diff.Render(width)
+	if err != nil {
+		log.Error("error rendering diff", "error", err)
+		return errMsg{err}
+	}
+	return contentRenderedMsg{content: s, changes: changes}
+}
 
+// This is where the magic happens.
+func glamourRender(ctx context.Context, m *pagerModel, markdown string) (string, error) {
+	m.lineNumbers = false
 	if !config.GlamourEnabled {
 		return markdown, nil
 	}
 
-	isCode := !utils.IsMarkdownFile(m.currentDocument.Note)
-	width := max(0, min(int(m.common.cfg.GlamourMaxWidth), m.viewport.Width)) //nolint:gosec
-	if isCode {
+	isCode := utils.IsCodeFile(m.currentDocument.Note, []byte(markdown), m.common.cfg.Languages)
+	m.lineNumbers = isCode || m.common.cfg.ShowLineNumbers
+	// The width of the pager besides line numbers, which the viewport only
+	// gets once the document is rendered.
+	viewWidth := max(0, m.common.width-m.gutterWidth())
+	width := max(0, min(int(m.common.cfg.GlamourMaxWidth), viewWidth)) //nolint:gosec
+	if isCode || utils.IsTableFile(m.currentDocument.Note) {
+		// Wide tables are scrolled horizontally rather than wrapped.
 		width = 0
 	}
 
 	options := []glamour.TermRendererOption{
-		utils.GlamourStyle(m.common.cfg.GlamourStyle, isCode),
+		glamour.WithColorProfile(lipgloss.ColorProfile()),
+		utils.GlamourStyle(m.common.cfg.GlamourStyle, m.common.cfg.StyleOverrides, isCode),
 		glamour.WithWordWrap(width),
 	}
 
@@ -457,42 +891,80 @@ func glamourRender(m *pagerModel, markdown string) (string, error) {
 	} else {
 		// Preprocess and cache
 		if isCode {
-			markdown = utils.WrapCodeBlock(markdown, filepath.Ext(m.currentDocument.Note))
+			markdown = utils.WrapCodeBlock(markdown, utils.DetectLanguage(m.currentDocument.Note, markdown, m.common.cfg.Languages))
 		} else {
-			markdown = utils.RenderMermaidBlocks(markdown, m.common.cfg.RenderMermaid, width)
+			if utils.IsNotebook(m.currentDocument.Note) {
+				nb, err := utils.NotebookToMarkdown([]byte(markdown), m.common.cfg.NotebookOutputs, m.common.cfg.Images != utils.ImagesNone)
+				if err != nil {
+					return "", err
+				}
+				markdown = nb
+			}
+			markdown = utils.MarkupToMarkdown(m.currentDocument.Note, markdown)
+			if utils.IsTableFile(m.currentDocument.Note) {
+				table, err := utils.TableToMarkdown(m.currentDocument.Note, markdown, m.common.cfg.TableOptions)
+				if err != nil {
+					return "", err
+				}
+				markdown = table
+			}
+			markdown = string(utils.RenderFrontmatter([]byte(markdown), m.frontmatter))
+			markdown, m.includes = utils.ExpandIncludes(markdown, m.currentDocument.localPath)
+			markdown = utils.DetectFenceLanguages(markdown)
+			opts := m.common.cfg.TableOptions
+			opts.Width = width
+			markdown = utils.RenderTableBlocks(markdown, opts)
+			if m.common.cfg.RenderMath {
+				markdown = utils.RenderMathBlocks(markdown)
+			}
+			markdown = utils.RenderBlocksContext(ctx, markdown, m.common.cfg.Renderers, width)
+			m.diagramSource = markdown
+			mode, keep := m.mermaidRender()
+			markdown, m.diagramErrors = utils.RenderMermaidBlocksExcept(ctx, markdown, mode, width, keep)
+			markdown = utils.RenderDotBlocksContext(ctx, markdown, m.common.cfg.RenderDot, width)
+			if ctx.Err() != nil {
+				return "", ctx.Err() //nolint:wrapcheck
+			}
 		}
 		m.preprocessedMarkdown = markdown
 		m.preprocessedIsCode = isCode
 	}
 
+	var (
+		links    utils.Links
+		callouts utils.Callouts
+		images   utils.Images
+	)
+	if !isCode {
+		base := ""
+		if m.currentDocument.localPath != "" {
+			base = filepath.Dir(m.currentDocument.localPath)
+		}
+		if m.common.cfg.Hyperlinks {
+			markdown, links = utils.MarkLinks(markdown, base, "")
+		}
+		markdown, callouts = utils.ExtractCallouts(markdown)
+		markdown, images = utils.ExtractImages(markdown, base, m.common.cfg.Images)
+	}
+
 	out, err := r.Render(markdown)
 	if err != nil {
 		return "", fmt.Errorf("error rendering markdown: %w", err)
 	}
+	out = callouts.Render(out, m.common.cfg.GlamourStyle, m.common.cfg.StyleOverrides, width, options...)
+	out = images.Render(out, cmp.Or(width, viewWidth))
+	// Hyperlinks are closed on every line, and lines cut by the pager keep
+	// the escape sequences past the cut, so they stay balanced.
+	out = links.Render(out)
+	if !isCode {
+		mode, keep := m.mermaidRender()
+		m.diagrams = utils.LocateMermaid(m.diagramSource, out, mode, width, keep)
+	}
 
 	if isCode {
 		out = strings.TrimSpace(out)
 	}
-
-	// trim lines
-	lines := strings.Split(out, "\n")
-
-	var content strings.Builder
-	for i, s := range lines {
-		if isCode || m.common.cfg.ShowLineNumbers {
-			content.WriteString(lineNumberStyle(fmt.Sprintf("%"+fmt.Sprint(lineNumberWidth)+"d", i+1)))
-			content.WriteString(trunc(s))
-		} else {
-			content.WriteString(s)
-		}
-
-		// don't add an artificial newline after the last split
-		if i+1 < len(lines) {
-			content.WriteRune('\n')
-		}
-	}
-
-	return content.String(), nil
+	return out, nil
 }
 
 func (m *pagerModel) initWatcher() {
@@ -504,19 +976,21 @@ func (m *pagerModel) initWatcher() {
 }
 
 func (m *pagerModel) watchFile() tea.Msg {
-	dir := m.localDir()
+	dirs := m.watchedDirs()
 
-	if err := m.watcher.Add(dir); err != nil {
-		log.Error("error adding dir to fsnotify watcher", "error", err)
-		return nil
+	for _, dir := range dirs {
+		if err := m.watcher.Add(dir); err != nil {
+			log.Error("error adding dir to fsnotify watcher", "error", err)
+			return nil
+		}
 	}
 
-	log.Info("fsnotify watching dir", "dir", dir)
+	log.Info("fsnotify watching dirs", "dirs", dirs)
 
 	for {
 		select {
 		case event, ok := <-m.watcher.Events:
-			if !ok || event.Name != m.currentDocument.localPath {
+			if !ok || !m.isWatchedFile(event.Name) {
 				continue
 			}
 
@@ -530,20 +1004,39 @@ func (m *pagerModel) watchFile() tea.Msg {
 			if !ok {
 				continue
 			}
-			log.Debug("fsnotify error", "dir", dir, "error", err)
+			log.Debug("fsnotify error", "dirs", dirs, "error", err)
 		}
 	}
 }
 
 func (m *pagerModel) unwatchFile() {
-	dir := m.localDir()
+	for _, dir := range m.watchedDirs() {
+		err := m.watcher.Remove(dir)
+		if err == nil {
+			log.Debug("fsnotify dir unwatched", "dir", dir)
+		} else {
+			log.Error("fsnotify fail to unwatch dir", "dir", dir, "error", err)
+		}
+	}
+}
 
-	err := m.watcher.Remove(dir)
-	if err == nil {
-		log.Debug("fsnotify dir unwatched", "dir", dir)
-	} else {
-		log.Error("fsnotify fail to unwatch dir", "dir", dir, "error", err)
+// watchedDirs returns the directories of the current document and the files
+// it includes.
+func (m *pagerModel) watchedDirs() []string {
+	dirs := []string{m.localDir()}
+	for _, path := range m.includes {
+		dir := filepath.Dir(path)
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
 	}
+	return dirs
+}
+
+// isWatchedFile returns whether a change to the given file should reload
+// the current document.
+func (m *pagerModel) isWatchedFile(name string) bool {
+	return name == m.currentDocument.localPath || slices.Contains(m.includes, name)
 }
 
 func (m *pagerModel) localDir() string {
@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+)
+
+const testLink = "\x1b]8;;https://example.com\x07link text\x1b]8;;\x07 after"
+
+func TestCut(t *testing.T) {
+	tests := []struct {
+		name       string
+		s          string
+		start, end int
+		want       string
+	}{
+		{"plain", "hello world", 2, 7, "llo w"},
+		{"past the end", "hello", 3, 10, "lo"},
+		{"color before the cut", "\x1b[31mred\x1b[0m plain", 1, 6, "\x1b[31med\x1b[0m pl"},
+		{"attributes before the cut", "\x1b[1;31mbold red\x1b[0m", 5, 8, "\x1b[1;31mred\x1b[0m"},
+		{"reset after the cut", "\x1b[31mred\x1b[0m plain", 4, 9, "\x1b[31m\x1b[0mplain"},
+		{"wide at the start", "a世界b", 2, 5, " 界"},
+		{"wide at the end", "a世界b", 0, 2, "a"},
+		{"wide whole", "a世界b", 1, 4, "世"},
+		{"link start cut", testLink, 5, 9, "\x1b]8;;https://example.com\x07text\x1b]8;;\x07"},
+		{"link cut at both ends", testLink, 2, 7, "\x1b]8;;https://example.com\x07nk te\x1b]8;;\x07"},
+		{"link end cut", testLink, 0, 4, "\x1b]8;;https://example.com\x07link\x1b]8;;\x07"},
+		{"after the link", testLink, 10, 15, "\x1b]8;;https://example.com\x07\x1b]8;;\x07after"},
+	}
+	for _, tt := range tests {
+		if got := cut(tt.s, tt.start, tt.end); got != tt.want {
+			t.Errorf("%s: cut(%q, %d, %d) = %q, want %q", tt.name, tt.s, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestCutLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		x, w int
+		want string // with ‹ and › for the markers
+	}{
+		{"fits", "short", 0, 10, "short"},
+		{"right", "abcdefghij", 0, 5, "abcd›"},
+		{"left", "abcdefghij", 5, 5, "‹ghij"},
+		{"both", "abcdefghij", 2, 4, "‹de›"},
+		{"blank past the right", "abc      ", 0, 5, "abc  "},
+		{"blank past the left", "     abc", 3, 5, "  abc"},
+		{"scrolled past the end", "ab", 5, 3, "‹"},
+		{"wide past the right", "ab世", 0, 3, "ab›"},
+		{"wide before the right", "ab世界", 0, 4, "ab ›"},
+		{"wide past the left", "世界abc", 1, 4, "‹界›"},
+		{"color", "\x1b[31mabcdefghij\x1b[0m", 2, 4, "‹\x1b[31mde\x1b[0m›"},
+		{"link", testLink, 2, 6, "‹\x1b]8;;https://example.com\x07k te\x1b]8;;\x07›"},
+	}
+	markers := strings.NewReplacer("‹", scrollMarkerStyle("‹"), "›", scrollMarkerStyle("›"))
+	for _, tt := range tests {
+		want := markers.Replace(tt.want)
+		if got := cutLine(tt.line, tt.x, tt.w); got != want {
+			t.Errorf("%s: cutLine(%q, %d, %d) = %q, want %q", tt.name, tt.line, tt.x, tt.w, got, want)
+		}
+	}
+}
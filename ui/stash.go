@@ -30,22 +30,13 @@ const (
 var stashingStatusMessage = statusMessage{normalStatusMessage, "Stashing..."}
 
 var (
-	dividerDot = darkGrayFg.SetString(" • ")
-	dividerBar = darkGrayFg.SetString(" │ ")
-
-	logoStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#ECFD65")).
-			Background(fuchsia).
-			Bold(true)
-
-	stashSpinnerStyle = lipgloss.NewStyle().
-				Foreground(gray)
-	stashInputPromptStyle = lipgloss.NewStyle().
-				Foreground(yellowGreen).
-				MarginRight(1)
-	stashInputCursorStyle = lipgloss.NewStyle().
-				Foreground(fuchsia).
-				MarginRight(1)
+	// Set by useTheme.
+	dividerDot            lipgloss.Style
+	dividerBar            lipgloss.Style
+	logoStyle             lipgloss.Style
+	stashSpinnerStyle     lipgloss.Style
+	stashInputPromptStyle lipgloss.Style
+	stashInputCursorStyle lipgloss.Style
 )
 
 // MSG
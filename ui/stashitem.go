@@ -47,7 +47,7 @@ func stashItemView(b *strings.Builder, m stashModel, index int, md *markdown) {
 			gutter = dullFuchsiaFg(verticalLine)
 			if m.currentSection().key == filterSection &&
 				m.filterState == filterApplied || singleFilteredItem {
-				s := lipgloss.NewStyle().Foreground(fuchsia)
+				s := fuchsiaStyle
 				title = styleFilteredText(title, m.filterInput.Value(), s, s.Underline(true))
 			} else {
 				title = fuchsiaFg(title)
@@ -74,7 +74,7 @@ func stashItemView(b *strings.Builder, m stashModel, index int, md *markdown) {
 		} else {
 			icon = greenFg(icon)
 
-			s := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#1a1a1a", Dark: "#dddddd"})
+			s := itemTitleStyle
 			title = styleFilteredText(title, m.filterInput.Value(), s, s.Underline(true))
 			date = grayFg(date)
 			editedBy = midGrayFg(editedBy)
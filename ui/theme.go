@@ -0,0 +1,340 @@
+package ui
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/glow/v2/utils"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Names of the built-in themes.
+const (
+	DefaultTheme      = "default"
+	HighContrastTheme = "high-contrast"
+	MonochromeTheme   = "monochrome"
+)
+
+// Theme holds the colors and styles of the TUI. Styles refer to colors by
+// name, or set colors directly.
+type Theme struct {
+	Colors map[string]ThemeColor `json:"colors,omitempty"`
+	Styles map[string]ThemeStyle `json:"styles,omitempty"`
+}
+
+// ThemeColor is a color of a theme, with variants for light and dark
+// backgrounds. In theme files it's either a single color or an object with
+// "light" and "dark" keys.
+type ThemeColor struct {
+	Light string `json:"light"`
+	Dark  string `json:"dark"`
+}
+
+// UnmarshalJSON reads a single color, or one for each background.
+func (c *ThemeColor) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		c.Light, c.Dark = s, s
+		return nil
+	}
+	type color ThemeColor
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*color)(c)) //nolint:wrapcheck
+}
+
+// ThemeStyle is a style of the TUI.
+type ThemeStyle struct {
+	Foreground string `json:"foreground,omitempty"` // color name or color
+	Background string `json:"background,omitempty"` // color name or color
+	Bold       bool   `json:"bold,omitempty"`
+	Faint      bool   `json:"faint,omitempty"`
+	Italic     bool   `json:"italic,omitempty"`
+	Underline  bool   `json:"underline,omitempty"`
+	Reverse    bool   `json:"reverse,omitempty"`
+}
+
+func fg(color string) ThemeStyle { return ThemeStyle{Foreground: color} }
+
+var defaultTheme = Theme{
+	Colors: map[string]ThemeColor{
+		"normal_dim":            {Light: "#A49FA5", Dark: "#777777"},
+		"gray":                  {Light: "#909090", Dark: "#626262"},
+		"mid_gray":              {Light: "#B2B2B2", Dark: "#4A4A4A"},
+		"dark_gray":             {Light: "#DDDADA", Dark: "#3C3C3C"},
+		"bright_gray":           {Light: "#847A85", Dark: "#979797"},
+		"dim_bright_gray":       {Light: "#C2B8C2", Dark: "#4D4D4D"},
+		"cream":                 {Light: "#FFFDF5", Dark: "#FFFDF5"},
+		"yellow":                {Light: "#ECFD65", Dark: "#ECFD65"},
+		"yellow_green":          {Light: "#04B575", Dark: "#ECFD65"},
+		"fuchsia":               {Light: "#EE6FF8", Dark: "#EE6FF8"},
+		"dim_fuchsia":           {Light: "#F1A8FF", Dark: "#99519E"},
+		"dull_fuchsia":          {Light: "#F793FF", Dark: "#AD58B4"},
+		"dim_dull_fuchsia":      {Light: "#F6C9FF", Dark: "#7B4380"},
+		"green":                 {Light: "#04B575", Dark: "#04B575"},
+		"red":                   {Light: "#FF4672", Dark: "#ED567A"},
+		"semi_dim_green":        {Light: "#35D79C", Dark: "#036B46"},
+		"dim_green":             {Light: "#72D2B0", Dark: "#0B5137"},
+		"mint_green":            {Light: "#89F0CB", Dark: "#89F0CB"},
+		"light_mint_green":      {Light: "#B6FFE4", Dark: "#B6FFE4"},
+		"dark_green":            {Light: "#1C8760", Dark: "#1C8760"},
+		"selected_tab":          {Light: "#333333", Dark: "#979797"},
+		"subtle":                {Light: "#9B9B9B", Dark: "#5C5C5C"},
+		"item_title":            {Light: "#1a1a1a", Dark: "#dddddd"},
+		"line_number":           {Light: "#656565", Dark: "#7D7D7D"},
+		"status_bar":            {Light: "#E6E6E6", Dark: "#242424"},
+		"status_bar_note":       {Light: "#656565", Dark: "#7D7D7D"},
+		"status_bar_scroll_pos": {Light: "#949494", Dark: "#5A5A5A"},
+		"status_bar_help":       {Light: "#DCDCDC", Dark: "#323232"},
+		"help_view":             {Light: "#f2f2f2", Dark: "#1B1B1B"},
+	},
+	Styles: map[string]ThemeStyle{
+		"dim_normal":       fg("normal_dim"),
+		"gray":             fg("gray"),
+		"mid_gray":         fg("mid_gray"),
+		"dark_gray":        fg("dark_gray"),
+		"bright_gray":      fg("bright_gray"),
+		"dim_bright_gray":  fg("dim_bright_gray"),
+		"green":            fg("green"),
+		"semi_dim_green":   fg("semi_dim_green"),
+		"dim_green":        fg("dim_green"),
+		"fuchsia":          fg("fuchsia"),
+		"dim_fuchsia":      fg("dim_fuchsia"),
+		"dull_fuchsia":     fg("dull_fuchsia"),
+		"dim_dull_fuchsia": fg("dim_dull_fuchsia"),
+		"red":              fg("red"),
+
+		"logo":         {Foreground: "yellow", Background: "fuchsia", Bold: true},
+		"tab":          fg("gray"),
+		"selected_tab": fg("selected_tab"),
+		"error_title":  {Foreground: "cream", Background: "red"},
+		"subtle":       fg("subtle"),
+		"pagination":   fg("subtle"),
+		"spinner":      fg("gray"),
+		"input_prompt": fg("yellow_green"),
+		"input_cursor": fg("fuchsia"),
+		"item_title":   fg("item_title"),
+
+		"status_bar_note":               {Foreground: "status_bar_note", Background: "status_bar"},
+		"status_bar_scroll_pos":         {Foreground: "status_bar_scroll_pos", Background: "status_bar"},
+		"status_bar_help":               {Foreground: "status_bar_note", Background: "status_bar_help"},
+		"status_bar_message":            {Foreground: "mint_green", Background: "dark_green"},
+		"status_bar_message_scroll_pos": {Foreground: "mint_green", Background: "dark_green"},
+		"status_bar_message_help":       {Foreground: "light_mint_green", Background: "green"},
+		"status_bar_error":              {Foreground: "cream", Background: "red"},
+		"help_view":                     {Foreground: "status_bar_note", Background: "help_view"},
+		"line_number":                   fg("line_number"),
+		"scroll_marker":                 fg("fuchsia"),
+	},
+}
+
+// Built-in themes besides the default one, as changes to it.
+var themes = map[string]Theme{
+	DefaultTheme: {},
+	HighContrastTheme: {
+		Colors: map[string]ThemeColor{
+			"normal_dim":            {Light: "#303030", Dark: "#D0D0D0"},
+			"gray":                  {Light: "#303030", Dark: "#D0D0D0"},
+			"mid_gray":              {Light: "#303030", Dark: "#C6C6C6"},
+			"dark_gray":             {Light: "#444444", Dark: "#B2B2B2"},
+			"bright_gray":           {Light: "#000000", Dark: "#FFFFFF"},
+			"dim_bright_gray":       {Light: "#303030", Dark: "#D0D0D0"},
+			"cream":                 {Light: "#FFFFFF", Dark: "#FFFFFF"},
+			"yellow":                {Light: "#FFFFFF", Dark: "#FFFFFF"},
+			"yellow_green":          {Light: "#005F00", Dark: "#FFFF00"},
+			"fuchsia":               {Light: "#870087", Dark: "#FF87FF"},
+			"dim_fuchsia":           {Light: "#5F005F", Dark: "#FFAFFF"},
+			"dull_fuchsia":          {Light: "#870087", Dark: "#FF87FF"},
+			"dim_dull_fuchsia":      {Light: "#5F005F", Dark: "#FFAFFF"},
+			"green":                 {Light: "#005F00", Dark: "#5FFF87"},
+			"red":                   {Light: "#AF0000", Dark: "#AF0000"},
+			"semi_dim_green":        {Light: "#005F00", Dark: "#87FFAF"},
+			"dim_green":             {Light: "#005F00", Dark: "#87FFAF"},
+			"mint_green":            {Light: "#FFFFFF", Dark: "#FFFFFF"},
+			"light_mint_green":      {Light: "#FFFFFF", Dark: "#FFFFFF"},
+			"dark_green":            {Light: "#005F00", Dark: "#005F00"},
+			"selected_tab":          {Light: "#000000", Dark: "#FFFFFF"},
+			"subtle":                {Light: "#303030", Dark: "#D0D0D0"},
+			"item_title":            {Light: "#000000", Dark: "#FFFFFF"},
+			"line_number":           {Light: "#303030", Dark: "#D0D0D0"},
+			"status_bar":            {Light: "#D0D0D0", Dark: "#303030"},
+			"status_bar_note":       {Light: "#000000", Dark: "#FFFFFF"},
+			"status_bar_scroll_pos": {Light: "#000000", Dark: "#FFFFFF"},
+			"status_bar_help":       {Light: "#BCBCBC", Dark: "#444444"},
+			"help_view":             {Light: "#FFFFFF", Dark: "#000000"},
+		},
+		Styles: map[string]ThemeStyle{
+			"logo":                    {Foreground: "#FFFFFF", Background: "#870087", Bold: true},
+			"selected_tab":            {Foreground: "selected_tab", Bold: true},
+			"error_title":             {Foreground: "cream", Background: "red", Bold: true},
+			"status_bar_message_help": {Foreground: "light_mint_green", Background: "dark_green", Bold: true},
+			"status_bar_error":        {Foreground: "cream", Background: "red", Bold: true},
+		},
+	},
+	MonochromeTheme: {
+		Styles: map[string]ThemeStyle{
+			"dim_normal":       {Faint: true},
+			"gray":             {Faint: true},
+			"mid_gray":         {Faint: true},
+			"dark_gray":        {Faint: true},
+			"bright_gray":      {},
+			"dim_bright_gray":  {Faint: true},
+			"green":            {},
+			"semi_dim_green":   {Faint: true},
+			"dim_green":        {Faint: true},
+			"fuchsia":          {Bold: true},
+			"dim_fuchsia":      {},
+			"dull_fuchsia":     {Bold: true},
+			"dim_dull_fuchsia": {Faint: true},
+			"red":              {Bold: true},
+
+			"logo":         {Bold: true, Reverse: true},
+			"tab":          {Faint: true},
+			"selected_tab": {Bold: true},
+			"error_title":  {Bold: true, Reverse: true},
+			"subtle":       {Faint: true},
+			"pagination":   {Faint: true},
+			"spinner":      {},
+			"input_prompt": {Bold: true},
+			"input_cursor": {},
+			"item_title":   {},
+
+			"status_bar_note":               {Reverse: true},
+			"status_bar_scroll_pos":         {Reverse: true},
+			"status_bar_help":               {Reverse: true, Bold: true},
+			"status_bar_message":            {Reverse: true, Bold: true},
+			"status_bar_message_scroll_pos": {Reverse: true, Bold: true},
+			"status_bar_message_help":       {Reverse: true, Bold: true},
+			"status_bar_error":              {Reverse: true, Bold: true},
+			"help_view":                     {},
+			"line_number":                   {Faint: true},
+			"scroll_marker":                 {Bold: true},
+		},
+	},
+}
+
+// ThemeNames returns the names of the built-in themes.
+func ThemeNames() []string {
+	return slices.Sorted(maps.Keys(themes))
+}
+
+// LoadTheme returns a built-in theme, or reads a JSON theme file. Theme files
+// change the theme they name with "extends", or the default theme: styles
+// replace the styles of the same name, and colors the colors.
+func LoadTheme(name string) (Theme, error) {
+	return loadTheme(name, map[string]bool{})
+}
+
+func loadTheme(name string, seen map[string]bool) (Theme, error) {
+	if changes, ok := themes[name]; ok {
+		return defaultTheme.with(changes), nil
+	}
+
+	path, err := filepath.Abs(utils.ExpandPath(name))
+	if err != nil {
+		return Theme{}, fmt.Errorf("unable to get absolute path: %w", err)
+	}
+	if seen[path] {
+		return Theme{}, fmt.Errorf("theme %s extends itself", name)
+	}
+	seen[path] = true
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Theme{}, fmt.Errorf("theme does not exist: %s (must be %s, or a JSON file)", name, strings.Join(ThemeNames(), ", "))
+	} else if err != nil {
+		return Theme{}, fmt.Errorf("unable to read theme: %w", err)
+	}
+
+	var file struct {
+		Extends string `json:"extends"`
+		Theme
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return Theme{}, fmt.Errorf("invalid theme %s: %w", name, err)
+	}
+	if err := file.validate(); err != nil {
+		return Theme{}, fmt.Errorf("invalid theme %s: %w", name, err)
+	}
+
+	parent := cmp.Or(file.Extends, DefaultTheme)
+	if _, ok := themes[parent]; !ok && !filepath.IsAbs(utils.ExpandPath(parent)) {
+		// Relative to the theme that extends it.
+		parent = filepath.Join(filepath.Dir(utils.ExpandPath(name)), parent)
+	}
+	base, err := loadTheme(parent, seen)
+	if err != nil {
+		return Theme{}, err
+	}
+	return base.with(file.Theme), nil
+}
+
+// validate checks that a theme only has colors and styles of the default
+// theme, and that its colors are valid.
+func (t Theme) validate() error {
+	for _, name := range slices.Sorted(maps.Keys(t.Colors)) {
+		if _, ok := defaultTheme.Colors[name]; !ok {
+			return fmt.Errorf("unknown color %q", name)
+		}
+		c := t.Colors[name]
+		for _, v := range []string{c.Light, c.Dark} {
+			if v != "" && !utils.IsValidColor(v) {
+				return fmt.Errorf("color %s: invalid color %q (must be #rgb, #rrggbb, or 0-255)", name, v)
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(t.Styles)) {
+		if _, ok := defaultTheme.Styles[name]; !ok {
+			return fmt.Errorf("unknown style %q", name)
+		}
+		s := t.Styles[name]
+		for _, v := range []string{s.Foreground, s.Background} {
+			if _, ok := defaultTheme.Colors[v]; v != "" && !ok && !utils.IsValidColor(v) {
+				return fmt.Errorf("style %s: unknown color %q", name, v)
+			}
+		}
+	}
+	return nil
+}
+
+// with returns the theme with the colors and styles of changes.
+func (t Theme) with(changes Theme) Theme {
+	r := Theme{Colors: maps.Clone(t.Colors), Styles: maps.Clone(t.Styles)}
+	maps.Copy(r.Colors, changes.Colors)
+	maps.Copy(r.Styles, changes.Styles)
+	return r
+}
+
+// color returns a color by name, or the color itself.
+func (t Theme) color(name string) lipgloss.TerminalColor {
+	if c, ok := t.Colors[name]; ok {
+		return lipgloss.AdaptiveColor{Light: c.Light, Dark: c.Dark}
+	}
+	if name == "" {
+		return lipgloss.NoColor{}
+	}
+	return lipgloss.Color(name)
+}
+
+// style returns a style by name.
+func (t Theme) style(name string) lipgloss.Style {
+	s := t.Styles[name]
+	return lipgloss.NewStyle().
+		Foreground(t.color(s.Foreground)).
+		Background(t.color(s.Background)).
+		Bold(s.Bold).
+		Faint(s.Faint).
+		Italic(s.Italic).
+		Underline(s.Underline).
+		Reverse(s.Reverse)
+}
@@ -0,0 +1,118 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTheme(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTheme(t *testing.T) {
+	dir := t.TempDir()
+	writeTheme(t, dir, "base.json", `{
+  "extends": "high-contrast",
+  "colors": { "red": "#ff0000", "green": { "light": "1", "dark": "2" } },
+  "styles": { "logo": { "foreground": "cream", "background": "red" } }
+}`)
+	path := writeTheme(t, dir, "theme.json", `{
+  "extends": "base.json",
+  "colors": { "red": "#aa0000" },
+  "styles": { "tab": { "foreground": "#123456", "italic": true } }
+}`)
+
+	theme, err := LoadTheme(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	highContrast := themes[HighContrastTheme]
+	for name, want := range map[string]ThemeColor{
+		"red":         {"#aa0000", "#aa0000"},
+		"green":       {"1", "2"},
+		"cream":       highContrast.Colors["cream"],
+		"line_number": highContrast.Colors["line_number"],
+		"fuchsia":     highContrast.Colors["fuchsia"],
+	} {
+		if got := theme.Colors[name]; got != want {
+			t.Errorf("color %s = %v, want %v", name, got, want)
+		}
+	}
+	for name, want := range map[string]ThemeStyle{
+		"logo":         {Foreground: "cream", Background: "red"},
+		"tab":          {Foreground: "#123456", Italic: true},
+		"selected_tab": highContrast.Styles["selected_tab"],
+		"help_view":    defaultTheme.Styles["help_view"],
+	} {
+		if got := theme.Styles[name]; got != want {
+			t.Errorf("style %s = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestLoadTheme_Default(t *testing.T) {
+	// Themes that don't extend another change the default one.
+	path := writeTheme(t, t.TempDir(), "theme.json", `{"colors": {"red": "9"}}`)
+	theme, err := LoadTheme(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := theme.Colors["red"]; got != (ThemeColor{"9", "9"}) {
+		t.Errorf("color red = %v, want 9", got)
+	}
+	if len(theme.Colors) != len(defaultTheme.Colors) || len(theme.Styles) != len(defaultTheme.Styles) {
+		t.Error("the theme should have every color and style of the default theme")
+	}
+	if got := theme.Styles["logo"]; got != defaultTheme.Styles["logo"] {
+		t.Errorf("style logo = %+v, want the default", got)
+	}
+
+	for _, name := range ThemeNames() {
+		theme, err := LoadTheme(name)
+		if err != nil {
+			t.Errorf("LoadTheme(%q): %v", name, err)
+			continue
+		}
+		if len(theme.Styles) != len(defaultTheme.Styles) {
+			t.Errorf("built-in theme %s is missing styles", name)
+		}
+	}
+}
+
+func TestLoadTheme_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeTheme(t, dir, "loop2.json", `{"extends": "loop.json"}`)
+	tests := map[string]struct {
+		content, want string
+	}{
+		"loop.json":        {`{"extends": "loop2.json"}`, "extends itself"},
+		"self.json":        {`{"extends": "self.json"}`, "extends itself"},
+		"missing.json":     {`{"extends": "nope.json"}`, "theme does not exist"},
+		"key.json":         {`{"color": {}}`, `unknown field "color"`},
+		"color_name.json":  {`{"colors": {"crimson": "#f00"}}`, `unknown color "crimson"`},
+		"color_key.json":   {`{"colors": {"red": {"lite": "#f00"}}}`, `unknown field "lite"`},
+		"style_name.json":  {`{"styles": {"title": {}}}`, `unknown style "title"`},
+		"style_key.json":   {`{"styles": {"logo": {"blink": true}}}`, `unknown field "blink"`},
+		"hex.json":         {`{"colors": {"red": "#ggg"}}`, `color red: invalid color "#ggg"`},
+		"ansi.json":        {`{"colors": {"red": {"light": "1", "dark": "256"}}}`, `color red: invalid color "256"`},
+		"style_color.json": {`{"styles": {"logo": {"foreground": "crimson"}}}`, `style logo: unknown color "crimson"`},
+		"syntax.json":      {`{"colors": `, "invalid theme"},
+	}
+	for name, tt := range tests {
+		path := writeTheme(t, dir, name, tt.content)
+		if _, err := LoadTheme(path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadTheme(%s) error = %v, want %q", name, err, tt.want)
+		}
+	}
+
+	if _, err := LoadTheme(filepath.Join(dir, "nope.json")); err == nil || !strings.Contains(err.Error(), "must be "+strings.Join(ThemeNames(), ", ")) {
+		t.Errorf("LoadTheme() of a missing file should list the built-in themes, got %v", err)
+	}
+}
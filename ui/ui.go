@@ -10,7 +10,6 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour/styles"
-	"github.com/charmbracelet/glow/v2/utils"
 	"github.com/charmbracelet/log"
 	"github.com/muesli/gitcha"
 	te "github.com/muesli/termenv"
@@ -25,7 +24,8 @@ var (
 	config Config
 
 	markdownExtensions = []string{
-		"*.md", "*.mdown", "*.mkdn", "*.mkd", "*.markdown",
+		"*.md", "*.mdown", "*.mkdn", "*.mkd", "*.markdown", "*.ipynb",
+		"*.rst", "*.adoc", "*.asciidoc", "*.org",
 	}
 )
 
@@ -40,6 +40,9 @@ func NewProgram(cfg Config, content string) *tea.Program {
 	)
 
 	config = cfg
+	if cfg.Theme != nil {
+		useTheme(*cfg.Theme)
+	}
 	opts := []tea.ProgramOption{tea.WithAltScreen()}
 	if cfg.EnableMouse {
 		opts = append(opts, tea.WithMouseCellMotion())
@@ -152,6 +155,12 @@ func newModel(cfg Config, content string) tea.Model {
 		stash:  newStashModel(&common),
 	}
 
+	if cfg.Diff != nil {
+		m.state = stateShowDocument
+		m.pager.currentDocument = markdown{Note: cfg.Diff.Title}
+		return m
+	}
+
 	path := cfg.Path
 	if path == "" && content != "" {
 		m.state = stateShowDocument
@@ -190,14 +199,11 @@ func (m model) Init() tea.Cmd {
 	case stateShowStash:
 		cmds = append(cmds, findLocalFiles(*m.common))
 	case stateShowDocument:
-		content, err := os.ReadFile(m.common.cfg.Path)
-		if err != nil {
-			log.Error("unable to read file", "file", m.common.cfg.Path, "error", err)
-			return func() tea.Msg { return errMsg{err} }
+		if m.pager.currentDocument.localPath == "" {
+			cmds = append(cmds, m.pager.render(m.pager.currentDocument.Body))
+			break
 		}
-		m.pager.preprocessedMarkdown = "" // Clear cache for new document
-		body := string(utils.RemoveFrontmatter(content))
-		cmds = append(cmds, renderWithGlamour(&m.pager, body))
+		cmds = append(cmds, loadLocalMarkdown(&m.pager.currentDocument))
 	}
 
 	return tea.Batch(cmds...)
@@ -248,7 +254,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 
 		case "left", "h", "delete":
-			if m.state == stateShowDocument {
+			// Wide documents are scrolled back to the left before leaving.
+			if m.state == stateShowDocument && (msg.String() == "delete" || !m.pager.scrolledRight()) {
 				cmds = append(cmds, m.unloadDocument()...)
 				return m, tea.Batch(cmds...)
 			}
@@ -277,8 +284,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// We've loaded a markdown file's contents for rendering
 		m.pager.currentDocument = *msg
 		m.pager.preprocessedMarkdown = "" // Clear cache for new document
-		body := string(utils.RemoveFrontmatter([]byte(msg.Body)))
-		cmds = append(cmds, renderWithGlamour(&m.pager, body))
+		cmds = append(cmds, m.pager.render(msg.Body))
 
 	case contentRenderedMsg:
 		m.state = stateShowDocument
@@ -382,14 +388,7 @@ func findLocalFiles(m commonModel) tea.Cmd {
 
 		log.Debug("local directory is", "cwd", cwd)
 
-		// Switch between FindFiles and FindAllFiles to bypass .gitignore rules
-		var ch chan gitcha.SearchResult
-		if m.cfg.ShowAllFiles {
-			ch, err = gitcha.FindAllFilesExcept(cwd, markdownExtensions, nil)
-		} else {
-			ch, err = gitcha.FindFilesExcept(cwd, markdownExtensions, ignorePatterns(m))
-		}
-
+		ch, err := FindLocalFiles(m.cfg, cwd)
 		if err != nil {
 			log.Error("error finding local files", "error", err)
 			return errMsg{err}
@@ -399,6 +398,17 @@ func findLocalFiles(m commonModel) tea.Cmd {
 	}
 }
 
+// FindLocalFiles searches dir for the documents the file browser lists. Files
+// ignored by git, hidden files and dependencies are left out unless
+// cfg.ShowAllFiles is set.
+func FindLocalFiles(cfg Config, dir string) (chan gitcha.SearchResult, error) {
+	// Switch between FindFiles and FindAllFiles to bypass .gitignore rules
+	if cfg.ShowAllFiles {
+		return gitcha.FindAllFilesExcept(dir, markdownExtensions, nil)
+	}
+	return gitcha.FindFilesExcept(dir, markdownExtensions, ignorePatterns(cfg))
+}
+
 func findNextLocalFile(m model) tea.Cmd {
 	return func() tea.Msg {
 		res, ok := <-m.localFileFinder
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"sync"
 )
@@ -84,3 +85,16 @@ func isURL(path string) bool {
 	_, err := url.ParseRequestURI(path)
 	return err == nil && strings.Contains(path, "://")
 }
+
+// imageBase returns what relative image sources in a document are resolved
+// against: the base URL of a remote document, or the directory of a local one.
+func imageBase(path, baseURL string) string {
+	switch {
+	case isURL(path):
+		return baseURL
+	case path == "":
+		return ""
+	default:
+		return filepath.Dir(path)
+	}
+}
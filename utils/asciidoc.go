@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	adocHeading     = regexp.MustCompile(`^(={1,6})\s+(.+?)(?:\s+=+)?$`)
+	adocAttribute   = regexp.MustCompile(`^:!?[\w-]+!?:.*$`)
+	adocBlockAttrs  = regexp.MustCompile(`^\[([^\]]*)\]$`)
+	adocBlockTitle  = regexp.MustCompile(`^\.([^\s.].*)$`)
+	adocList        = regexp.MustCompile(`^\s*(\*{1,5}|-|\.{1,5})\s+(.*)$`)
+	adocAdmonition  = regexp.MustCompile(`^(NOTE|TIP|IMPORTANT|WARNING|CAUTION):\s+(.*)$`)
+	adocBlockImage  = regexp.MustCompile(`^image::([^\[]+)\[([^\]]*)\]$`)
+	adocInlineImage = regexp.MustCompile(`image:([^\s\[]+)\[([^\]]*)\]`)
+	adocLink        = regexp.MustCompile(`(?:link:|(https?://|mailto:))([^\s\[]+)\[([^\]]*)\]`)
+	adocXref        = regexp.MustCompile(`xref:([^\s\[]+)\[([^\]]*)\]`)
+	adocCrossRef    = regexp.MustCompile(`<<([^,>]+)(?:,\s*([^>]+))?>>`)
+	adocStrong      = regexp.MustCompile(`(^|[^\w*])\*([^*\s](?:[^*]*[^*\s])?)\*([^\w*]|$)`)
+	adocEmphasis    = regexp.MustCompile(`(^|[^\w_])_([^_\s](?:[^_]*[^_\s])?)_([^\w_]|$)`)
+	adocCols        = regexp.MustCompile(`cols="?([^",\]]*(?:,[^",\]]*)*)"?`)
+	adocPassthrough = regexp.MustCompile(`(^|[^\w+])\+([^+\s](?:[^+]*[^+\s])?)\+([^\w+]|$)`)
+)
+
+// asciidocToMarkdown converts AsciiDoc to markdown.
+func asciidocToMarkdown(content string) string {
+	lines := strings.Split(content, "\n")
+	var (
+		out   []string
+		attrs string // attributes of the next block, like [source,go]
+		title string // title of the next block
+	)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+
+		switch {
+		case line == "":
+			attrs = ""
+			out = append(out, "")
+
+		case strings.HasPrefix(line, "////"):
+			// Comment block.
+			i = adocDelimitedEnd(lines, i)
+
+		case strings.HasPrefix(line, "//"), adocAttribute.MatchString(line):
+
+		case line == "+":
+			// List continuation.
+			out = append(out, "")
+
+		case adocBlockAttrs.MatchString(line) && !strings.HasPrefix(line, "[["):
+			attrs = adocBlockAttrs.FindStringSubmatch(line)[1]
+			continue
+
+		case adocBlockTitle.MatchString(line):
+			title = adocBlockTitle.FindStringSubmatch(line)[1]
+			continue
+
+		case strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]"):
+			// Anchor.
+
+		case line == "----" || line == "....":
+			end := adocDelimitedEnd(lines, i)
+			language := ""
+			if style, rest, _ := strings.Cut(attrs, ","); style == "source" || style == "" && rest != "" {
+				language, _, _ = strings.Cut(rest, ",")
+			}
+			if title != "" {
+				out = append(out, "**"+adocInline(title)+"**", "")
+			}
+			out = append(out, codeLines(lines[i+1:end], language, "")...)
+			i = end
+
+		case line == "====" || line == "****" || line == "____" || line == "--":
+			end := adocDelimitedEnd(lines, i)
+			body := strings.Split(strings.TrimSuffix(asciidocToMarkdown(strings.Join(lines[i+1:end], "\n")), "\n"), "\n")
+			style, _, _ := strings.Cut(attrs, ",")
+			switch {
+			case line == "====" && style != "":
+				out = append(out, calloutLines(style, adocInline(title), body, "")...)
+			case line == "____" || style == "quote":
+				for _, l := range body {
+					out = append(out, strings.TrimRight("> "+l, " "))
+				}
+			default:
+				if title != "" {
+					out = append(out, "**"+adocInline(title)+"**", "")
+				}
+				out = append(out, body...)
+			}
+			i = end
+
+		case line == "|===":
+			end := adocDelimitedEnd(lines, i)
+			if title != "" {
+				out = append(out, "**"+adocInline(title)+"**", "")
+			}
+			out = append(out, pipeTable(adocTableRows(lines[i+1:end], adocColumns(attrs)), "")...)
+			i = end
+
+		case adocHeading.MatchString(line):
+			m := adocHeading.FindStringSubmatch(line)
+			out = append(out, strings.Repeat("#", len(m[1]))+" "+adocInline(m[2]))
+
+		case adocBlockImage.MatchString(line):
+			m := adocBlockImage.FindStringSubmatch(line)
+			alt, _, _ := strings.Cut(m[2], ",")
+			out = append(out, "!["+alt+"]("+m[1]+")")
+
+		case adocAdmonition.MatchString(line):
+			// Admonition paragraph, up to the next blank line.
+			m := adocAdmonition.FindStringSubmatch(line)
+			body := []string{adocInline(m[2])}
+			for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+				i++
+				body = append(body, adocInline(strings.TrimSpace(lines[i])))
+			}
+			out = append(out, calloutLines(m[1], "", body, "")...)
+
+		case adocList.MatchString(line):
+			m := adocList.FindStringSubmatch(line)
+			marker, depth := "- ", len(m[1])
+			if m[1][0] == '.' {
+				marker = "1. "
+			} else if m[1] == "-" {
+				depth = 1
+			}
+			out = append(out, strings.Repeat("  ", depth-1)+marker+adocInline(m[2]))
+
+		default:
+			if strings.HasPrefix(line, " ") {
+				// A literal paragraph, up to the next blank line.
+				end := i
+				for end+1 < len(lines) && strings.TrimSpace(lines[end+1]) != "" {
+					end++
+				}
+				out = append(out, codeLines(dedent(lines[i:end+1]), "", "")...)
+				i = end
+				break
+			}
+			if title != "" {
+				out = append(out, "**"+adocInline(title)+"**", "")
+			}
+			out = append(out, adocInline(line))
+		}
+		attrs, title = "", ""
+	}
+	return strings.Join(trimBlankEdges(out), "\n") + "\n"
+}
+
+// adocDelimitedEnd returns the line that closes the delimited block opened at
+// line i, or the last line if it isn't closed.
+func adocDelimitedEnd(lines []string, i int) int {
+	delimiter := strings.TrimRight(lines[i], " \t")
+	for j := i + 1; j < len(lines); j++ {
+		if strings.TrimRight(lines[j], " \t") == delimiter {
+			return j
+		}
+	}
+	return len(lines) - 1
+}
+
+// adocTableRows parses the rows of a table with the given number of columns.
+// If it is 0, the first line has a cell for each column.
+func adocTableRows(lines []string, cols int) [][]string {
+	var cells []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			// Cells may continue on the following lines.
+			if line != "" && len(cells) > 0 {
+				cells[len(cells)-1] += " " + adocInline(line)
+			}
+			continue
+		}
+		parts := strings.Split(line[1:], "|")
+		if cols == 0 {
+			cols = len(parts)
+		}
+		for _, p := range parts {
+			cells = append(cells, adocInline(strings.TrimSpace(p)))
+		}
+	}
+
+	var rows [][]string
+	for i := 0; i < len(cells); i += cols {
+		rows = append(rows, cells[i:min(i+cols, len(cells))])
+	}
+	return rows
+}
+
+// adocColumns returns the number of columns set by the cols attribute of a
+// table, like cols="1,2,1" or cols="3*", or 0 if there is none.
+func adocColumns(attrs string) int {
+	m := adocCols.FindStringSubmatch(attrs)
+	if m == nil {
+		return 0
+	}
+	if n, ok := strings.CutSuffix(m[1], "*"); ok {
+		cols, _ := strconv.Atoi(n)
+		return cols
+	}
+	return strings.Count(m[1], ",") + 1
+}
+
+// adocInline converts inline markup: strong and emphasized text, links,
+// cross references and images.
+func adocInline(s string) string {
+	// Leave code spans alone.
+	parts := strings.Split(s, "`")
+	for i := 0; i < len(parts); i += 2 {
+		p := parts[i]
+		p = adocInlineImage.ReplaceAllString(p, "![$2]($1)")
+		p = adocLink.ReplaceAllStringFunc(p, func(match string) string {
+			m := adocLink.FindStringSubmatch(match)
+			url := m[1] + m[2]
+			text, _, _ := strings.Cut(m[3], ",")
+			if text == "" {
+				return url
+			}
+			return "[" + text + "](" + url + ")"
+		})
+		p = adocXref.ReplaceAllString(p, "[$2]($1)")
+		p = adocCrossRef.ReplaceAllStringFunc(p, func(match string) string {
+			m := adocCrossRef.FindStringSubmatch(match)
+			if m[2] != "" {
+				return m[2]
+			}
+			return m[1]
+		})
+		// Twice, since adjacent matches share the whitespace between them.
+		for _, r := range []struct {
+			pattern *regexp.Regexp
+			repl    string
+		}{
+			{adocStrong, "$1**$2**$3"},
+			{adocEmphasis, "$1*$2*$3"},
+			{adocPassthrough, "$1`$2`$3"},
+		} {
+			p = r.pattern.ReplaceAllString(r.pattern.ReplaceAllString(p, r.repl), r.repl)
+		}
+		parts[i] = p
+	}
+	return strings.Join(parts, "`")
+}
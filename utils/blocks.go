@@ -0,0 +1,357 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BlockRenderer renders the contents of a fenced code block as text, like a
+// diagram, to fit within width columns. A width of 0 means no limit.
+type BlockRenderer interface {
+	RenderBlock(content string, width int) (string, error)
+}
+
+// ContextBlockRenderer is a BlockRenderer that stops rendering when ctx is
+// done. Other renderers are left to finish in the background.
+type ContextBlockRenderer interface {
+	RenderBlockContext(ctx context.Context, content string, width int) (string, error)
+}
+
+// BlockRenderers maps the languages of fenced code blocks, lowercased, to
+// their renderers.
+type BlockRenderers map[string]BlockRenderer
+
+// fencedBlock represents a parsed fenced code block.
+type fencedBlock struct {
+	startLine    int    // line index where block starts
+	endLine      int    // line index where block ends (inclusive)
+	fenceChar    rune   // '`' or '~'
+	fenceLen     int    // length of fence (>= 3)
+	prefix       string // container markers and indentation before the opening fence
+	indentPrefix string // what's before the other lines, like "> " in a block quote
+	infoString   string // language/info after fence
+	language     string // first word of the info string, lowercased
+	content      string // content inside the block
+}
+
+// RenderBlocks replaces the fenced code blocks of languages that have a
+// renderer with their rendered output, in a plain code block. Blocks that
+// fail to render are kept after a visible error message.
+// This correctly handles nested fences, indentation, and CRLF line endings.
+func RenderBlocks(content string, renderers BlockRenderers, maxWidth int) string {
+	return RenderBlocksContext(context.Background(), content, renderers, maxWidth)
+}
+
+// RenderBlocksContext is like RenderBlocks, but stops when ctx is done, like
+// when the document isn't shown anymore, and then returns content unchanged.
+// Blocks are rendered concurrently.
+func RenderBlocksContext(ctx context.Context, content string, renderers BlockRenderers, maxWidth int) string {
+	if content == "" || len(renderers) == 0 {
+		return content
+	}
+
+	// Normalize CRLF to LF for consistent processing
+	normalized := strings.ReplaceAll(content, "\r\n", "\n")
+
+	lines := strings.Split(normalized, "\n")
+	blocks := findFencedBlocks(lines, func(language string) bool {
+		_, ok := renderers[language]
+		return ok
+	})
+
+	// If no blocks found, return original content unchanged
+	if len(blocks) == 0 {
+		return content
+	}
+
+	// Render the blocks with as many workers as there are CPUs, as diagrams
+	// are laid out in process.
+	rendered := make([][]string, len(blocks))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, block := range blocks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			rendered[i] = renderFencedBlock(ctx, block, renderers[block.language], maxWidth)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return content
+	}
+
+	// Process blocks in reverse order to preserve line indices
+	for i := len(blocks) - 1; i >= 0; i-- {
+		lines = replaceLines(lines, blocks[i].startLine, blocks[i].endLine, rendered[i])
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// findFencedBlocks scans lines and returns all top-level fenced blocks of the
+// languages that match, which are lowercased. Blocks nested inside other
+// fenced blocks are ignored, while those in block quotes, list items and
+// admonitions are found at any depth.
+func findFencedBlocks(lines []string, match func(language string) bool) []fencedBlock {
+	var blocks []fencedBlock
+	var currentBlock *fencedBlock
+	var containers containerScanner
+	inFence := false
+	var fenceChar rune
+	var fenceLen int
+	var fenceIndent string
+	var contentLines []string
+
+	for i, line := range lines {
+		prefix, rest, continued := containers.scan(line, inFence)
+		if inFence && !continued {
+			// The fence ends with its container, unclosed.
+			inFence = false
+			currentBlock = nil
+		}
+
+		// Check if this line is a fence
+		indent, char, length, info := parseFenceLine(rest)
+
+		if !inFence {
+			// Not currently in a fence - check for opening fence
+			if length >= 3 {
+				inFence = true
+				fenceChar = char
+				fenceLen = length
+				fenceIndent = indent
+
+				// Check if the language matches (case-insensitive)
+				infoToken := strings.Fields(info)
+				if len(infoToken) == 0 {
+					continue
+				}
+				language := strings.ToLower(infoToken[0])
+				if match(language) {
+					currentBlock = &fencedBlock{
+						startLine:    i,
+						fenceChar:    char,
+						fenceLen:     length,
+						prefix:       prefix + indent,
+						indentPrefix: continuation(prefix) + indent,
+						infoString:   info,
+						language:     language,
+					}
+					contentLines = nil
+				}
+			}
+		} else {
+			// Currently in a fence - check for closing fence
+			// Closing fence must use same char and length >= opening length
+			if char == fenceChar && length >= fenceLen && strings.TrimSpace(info) == "" {
+				if currentBlock != nil {
+					// End of a rendered block
+					currentBlock.endLine = i
+					currentBlock.content = strings.Join(contentLines, "\n")
+					blocks = append(blocks, *currentBlock)
+					currentBlock = nil
+				}
+				inFence = false
+				fenceChar = 0
+				fenceLen = 0
+			} else if currentBlock != nil {
+				// Remove the containers and the indent of the fence from
+				// content lines
+				contentLines = append(contentLines, strings.TrimPrefix(rest, fenceIndent))
+			}
+		}
+	}
+
+	return blocks
+}
+
+// renderFencedBlock renders a block with r and returns replacement lines.
+func renderFencedBlock(ctx context.Context, block fencedBlock, r BlockRenderer, maxWidth int) []string {
+	rendered, err := renderBlockContext(ctx, r, block.content, block.width(maxWidth))
+	if errors.Is(err, errKeepSource) {
+		return block.indent(block.source())
+	}
+	if err != nil {
+		// On error, show visible error message and keep original block
+		result := []string{"```", block.language + " render error: " + err.Error(), "```"}
+		return block.indent(append(result, block.source()...))
+	}
+
+	// Wrap rendered output in a plain code block, in the containers of the
+	// block
+	rendered = strings.TrimRight(rendered, "\n\r\t ")
+	result := []string{"```"}
+	result = append(result, strings.Split(rendered, "\n")...)
+	return block.indent(append(result, "```"))
+}
+
+// errKeepSource is returned by renderers for blocks to be shown as they are.
+var errKeepSource = errors.New("keep the source of the block")
+
+// source returns the lines of the block, fences included, without its
+// containers and indentation.
+func (block fencedBlock) source() []string {
+	fence := strings.Repeat(string(block.fenceChar), block.fenceLen)
+	result := []string{fence + block.infoString}
+	result = append(result, strings.Split(block.content, "\n")...)
+	return append(result, fence)
+}
+
+// indent puts lines replacing the block in its place: in the containers of
+// the block, at its indentation.
+func (block fencedBlock) indent(lines []string) []string {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		if i == 0 {
+			result[i] = block.prefix + line
+		} else {
+			result[i] = block.indentPrefix + line
+		}
+	}
+	return result
+}
+
+// width returns the width the rendered block has, within a document of
+// maxWidth columns.
+func (block fencedBlock) width(maxWidth int) int {
+	if maxWidth <= 0 {
+		return 0
+	}
+	width := maxWidth - len(block.indentPrefix)
+	const codeBlockMargin = 4
+	if width > codeBlockMargin {
+		return width - codeBlockMargin
+	}
+	return 0
+}
+
+// renderBlockContext renders content with r, giving up when ctx is done.
+func renderBlockContext(ctx context.Context, r BlockRenderer, content string, width int) (string, error) {
+	if r, ok := r.(ContextBlockRenderer); ok {
+		return r.RenderBlockContext(ctx, content, width)
+	}
+
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := r.RenderBlock(content, width)
+		done <- result{out, err}
+	}()
+	select {
+	case res := <-done:
+		return res.out, res.err
+	case <-ctx.Done():
+		return "", ctx.Err() //nolint:wrapcheck
+	}
+}
+
+// replaceLines replaces lines[start:end+1] with newLines.
+func replaceLines(lines []string, start, end int, newLines []string) []string {
+	result := make([]string, 0, len(lines)-end+start-1+len(newLines))
+	result = append(result, lines[:start]...)
+	result = append(result, newLines...)
+	result = append(result, lines[end+1:]...)
+	return result
+}
+
+// DefaultRenderTimeout is how long a CommandRenderer without a timeout may
+// run.
+const DefaultRenderTimeout = 10 * time.Second
+
+// CommandRenderer renders blocks with an external command, which reads the
+// contents of a block on stdin and writes the rendered text to stdout. The
+// width available is passed in the GLOW_WIDTH environment variable.
+type CommandRenderer struct {
+	Command string        // command line, split into words like a shell does
+	Timeout time.Duration // DefaultRenderTimeout if 0
+}
+
+// Output of commands, which are only run once for each block and width, so
+// that resizing and reloading doesn't run them over and over. Failures aren't
+// kept, so that reloading retries them, like after fixing the command.
+var renderCache sync.Map // renderKey -> renderResult
+
+type renderKey struct {
+	command, content string
+	width            int
+}
+
+type renderResult struct {
+	out string
+	err error
+}
+
+func (r CommandRenderer) RenderBlock(content string, width int) (string, error) {
+	return r.RenderBlockContext(context.Background(), content, width)
+}
+
+// RenderBlockContext renders a block like RenderBlock, and kills the command
+// when ctx is done.
+func (r CommandRenderer) RenderBlockContext(ctx context.Context, content string, width int) (string, error) {
+	key := renderKey{r.Command, content, width}
+	if res, ok := renderCache.Load(key); ok {
+		return res.(renderResult).out, res.(renderResult).err
+	}
+	out, err := r.run(ctx, content, width)
+	if ctx.Err() != nil {
+		// Not rendered, rather than failed.
+		return "", ctx.Err() //nolint:wrapcheck
+	}
+	if err == nil {
+		renderCache.Store(key, renderResult{out, nil})
+	}
+	return out, err
+}
+
+func (r CommandRenderer) run(ctx context.Context, content string, width int) (string, error) {
+	args, err := ShellWords(r.Command)
+	if err != nil {
+		return "", fmt.Errorf("invalid command: %w", err)
+	}
+	if len(args) == 0 {
+		return "", errors.New("no command")
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultRenderTimeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	c := exec.CommandContext(runCtx, args[0], args[1:]...) //nolint:gosec
+	c.Env = append(os.Environ(), "GLOW_WIDTH="+strconv.Itoa(width))
+	c.Stdin = strings.NewReader(content)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		if runCtx.Err() != nil && ctx.Err() == nil {
+			return "", fmt.Errorf("%s timed out after %s", args[0], timeout)
+		}
+		if msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
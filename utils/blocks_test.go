@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderBlocks_Command(t *testing.T) {
+	input := "# Title\n\n```dot\ndigraph { a -> b }\n```\n\n```go\nfmt.Println()\n```"
+	renderers := BlockRenderers{"dot": CommandRenderer{Command: "tr a-z A-Z"}}
+	want := "# Title\n\n```\nDIGRAPH { A -> B }\n```\n\n```go\nfmt.Println()\n```"
+	if got := RenderBlocks(input, renderers, 80); got != want {
+		t.Errorf("RenderBlocks() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderBlocks_Width(t *testing.T) {
+	renderers := BlockRenderers{"abc": CommandRenderer{Command: "env"}}
+	result := RenderBlocks("```abc\nX:1\n```", renderers, 80)
+	// The width excludes the code block margin.
+	if !strings.Contains(result, "GLOW_WIDTH=76\n") {
+		t.Errorf("expected the width in the environment, got:\n%s", result)
+	}
+}
+
+func TestRenderBlocks_Containers(t *testing.T) {
+	renderers := BlockRenderers{"abc": CommandRenderer{Command: "tr a-z A-Z"}}
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			"block quote",
+			"> quote\n>\n> ```abc\n> a\n>\n>  b\n> ```\n> end",
+			"> quote\n>\n> ```\n> A\n> \n>  B\n> ```\n> end",
+		},
+		{
+			"nested list",
+			"- item\n  1. nested\n\n     ```abc\n     a\n     ```",
+			"- item\n  1. nested\n\n     ```\n     A\n     ```",
+		},
+		{
+			"list marker",
+			"* ```abc\n  a\n  ```",
+			"* ```\n  A\n  ```",
+		},
+		{
+			"list in quote",
+			"> - ```abc\n>   a\n>   ```",
+			"> - ```\n>   A\n>   ```",
+		},
+		{
+			"alert",
+			"> [!NOTE]\n> ```abc\n> a\n> ```",
+			"> [!NOTE]\n> ```\n> A\n> ```",
+		},
+		{
+			"admonition",
+			"!!! note\n    ```abc\n    a\n    ```\n",
+			"!!! note\n    ```\n    A\n    ```\n",
+		},
+		{
+			// The fence ends with the quote, without a closing fence.
+			"closed container",
+			"> ```abc\n> a\n\n```\n",
+			"> ```abc\n> a\n\n```\n",
+		},
+		{
+			"indented code",
+			"- item\n\n          ```abc\n          a\n          ```",
+			"- item\n\n          ```abc\n          a\n          ```",
+		},
+	}
+	for _, tt := range tests {
+		if got := RenderBlocks(tt.in, renderers, 0); got != tt.want {
+			t.Errorf("%s: RenderBlocks() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRenderBlocks_CommandError(t *testing.T) {
+	renderers := BlockRenderers{
+		"d2":       CommandRenderer{Command: "false"},
+		"plantuml": CommandRenderer{Command: "sleep 5", Timeout: 50 * time.Millisecond},
+	}
+	input := "```d2\na -> b\n```\n\n```plantuml\n@startuml\n```"
+	result := RenderBlocks(input, renderers, 0)
+
+	for _, want := range []string{
+		"d2 render error: false: exit status 1",
+		"```d2\na -> b\n```",
+		"plantuml render error: sleep timed out after 50ms",
+		"```plantuml\n@startuml\n```",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in:\n%s", want, result)
+		}
+	}
+}
+
+func TestRenderBlocks_CommandQuoting(t *testing.T) {
+	renderers := BlockRenderers{"abc": CommandRenderer{Command: `sh -c 'printf "%s|%s" "$0" "$1"' "a b" c`}}
+	if got, want := RenderBlocks("```abc\nx\n```", renderers, 0), "```\na b|c\n```"; got != want {
+		t.Errorf("RenderBlocks() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderBlocks_CommandRetry(t *testing.T) {
+	// The command fails until the file exists.
+	path := filepath.Join(t.TempDir(), "ready")
+	renderers := BlockRenderers{"abc": CommandRenderer{Command: "cat " + path}}
+	input := "```abc\nx\n```"
+	if got := RenderBlocks(input, renderers, 0); !strings.Contains(got, "render error") {
+		t.Fatalf("expected an error, got:\n%s", got)
+	}
+	if err := os.WriteFile(path, []byte("ready"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := RenderBlocks(input, renderers, 0), "```\nready\n```"; got != want {
+		t.Errorf("a failed block wasn't rendered again: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// sleepRenderer renders blocks that hold a duration after sleeping for it.
+type sleepRenderer struct{}
+
+func (sleepRenderer) RenderBlock(content string, _ int) (string, error) {
+	d, err := time.ParseDuration(content)
+	if err != nil {
+		return "", err
+	}
+	time.Sleep(d)
+	return "slept " + content, nil
+}
+
+func TestRenderBlocks_Order(t *testing.T) {
+	var input, want []string
+	for i := range 8 {
+		// The first blocks take the longest.
+		d := fmt.Sprintf("%dms", (8-i)*5)
+		input = append(input, "```sleep\n"+d+"\n```")
+		want = append(want, "```\nslept "+d+"\n```")
+	}
+	got := RenderBlocks(strings.Join(input, "\n\ntext\n\n"), BlockRenderers{"sleep": sleepRenderer{}}, 0)
+	if w := strings.Join(want, "\n\ntext\n\n"); got != w {
+		t.Errorf("RenderBlocks() =\n%s\nwant\n%s", got, w)
+	}
+}
+
+func TestRenderBlocksContext_Cancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	input := "```sleep\n1ms\n```\n\n```sleep\n5s\n```"
+	start := time.Now()
+	if got := RenderBlocksContext(ctx, input, BlockRenderers{"sleep": sleepRenderer{}}, 0); got != input {
+		t.Errorf("RenderBlocksContext() =\n%s\nwant the content unchanged", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("RenderBlocksContext() took %s after being cancelled", elapsed)
+	}
+}
+
+func TestRenderMermaidBlocks_Timeout(t *testing.T) {
+	timeout := DiagramTimeout
+	DiagramTimeout = 0
+	t.Cleanup(func() { DiagramTimeout = timeout })
+	mermaidResults.Clear()
+
+	input := "```mermaid\ngraph LR\nA --> B\n```"
+	got := RenderMermaidBlocks(input, "unicode", 80)
+	for _, want := range []string{"mermaid render error: diagram timed out after 0s", input} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderMermaidBlocks() =\n%s\nwant it to contain\n%s", got, want)
+		}
+	}
+	// The layout goes on in the background, and other tests render the
+	// diagram too.
+	<-layoutMermaid("graph LR\nA --> B", false, 76).done
+}
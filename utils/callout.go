@@ -0,0 +1,393 @@
+package utils
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+// calloutKind describes a type of callout.
+type calloutKind struct {
+	title string
+	icon  string
+	dark  string // default colors
+	light string
+}
+
+var calloutKinds = map[string]calloutKind{
+	"note":      {"Note", "ℹ", "#4493F8", "#0969DA"},
+	"abstract":  {"Abstract", "≡", "#39C5CF", "#1B7C83"},
+	"info":      {"Info", "ℹ", "#4493F8", "#0969DA"},
+	"tip":       {"Tip", "★", "#3FB950", "#1A7F37"},
+	"success":   {"Success", "✓", "#3FB950", "#1A7F37"},
+	"question":  {"Question", "?", "#AB7DF8", "#8250DF"},
+	"important": {"Important", "‼", "#AB7DF8", "#8250DF"},
+	"warning":   {"Warning", "⚠", "#D29922", "#9A6700"},
+	"failure":   {"Failure", "✗", "#F85149", "#D1242F"},
+	"danger":    {"Danger", "⚡", "#F85149", "#D1242F"},
+	"caution":   {"Caution", "⊘", "#F85149", "#D1242F"},
+	"bug":       {"Bug", "✱", "#F85149", "#D1242F"},
+	"example":   {"Example", "▸", "#AB7DF8", "#8250DF"},
+	"quote":     {"Quote", "❝", "#9198A1", "#59636E"},
+}
+
+// Aliases used by MkDocs Material and Obsidian.
+var calloutAliases = map[string]string{
+	"summary": "abstract", "tldr": "abstract", "todo": "info", "hint": "tip",
+	"check": "success", "done": "success", "help": "question", "faq": "question",
+	"attention": "warning", "fail": "failure", "missing": "failure",
+	"error": "danger", "cite": "quote",
+}
+
+var (
+	alertPattern      = regexp.MustCompile(`^( {0,3})>\s?\[!(\w+)\][+-]?\s*(.*)$`)
+	admonitionPattern = regexp.MustCompile(`^(\s*)(?:!!!|\?\?\?\+?)\s+(\w+)(?:\s+"(.*)")?\s*$`)
+)
+
+// Placeholders are substituted for blocks rendered on their own, like
+// callouts, before rendering the document. They're made of characters of the
+// private use area, which documents don't have, around a name and an index.
+const (
+	placeholderStart = "\uE000"
+	placeholderEnd   = "\uE001"
+
+	calloutPlaceholder = placeholderStart + "callout"
+)
+
+// placeholderFor returns the placeholder of the block at index n.
+func placeholderFor(placeholder string, n int) string {
+	return placeholder + strconv.Itoa(n) + placeholderEnd
+}
+
+type callout struct {
+	kind  string
+	title string
+	body  string
+}
+
+// Callouts holds the callouts extracted from a markdown document.
+type Callouts []callout
+
+// ExtractCallouts finds GitHub alerts (> [!NOTE]) and MkDocs admonitions
+// (!!! note "Title") in markdown content. Each one is replaced by a
+// placeholder paragraph that Callouts.Render later swaps for a styled box.
+func ExtractCallouts(content string) (string, Callouts) {
+	if !strings.Contains(content, "[!") && !strings.Contains(content, "!!!") && !strings.Contains(content, "???") {
+		return content, nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	var result []string
+	var callouts Callouts
+	var fenceChar rune
+	var fenceLen int
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		_, char, length, info := parseFenceLine(line)
+		if fenceLen > 0 {
+			if char == fenceChar && length >= fenceLen && info == "" {
+				fenceChar, fenceLen = 0, 0
+			}
+			result = append(result, line)
+			continue
+		}
+		if length >= 3 {
+			fenceChar, fenceLen = char, length
+			result = append(result, line)
+			continue
+		}
+
+		var c callout
+		var indent string
+		end := -1
+		if m := alertPattern.FindStringSubmatch(line); m != nil {
+			if kind, ok := calloutKindOf(m[2]); ok {
+				indent = m[1]
+				c = callout{kind: kind, title: m[3]}
+				end, c.body = alertBody(lines, i+1, indent)
+			}
+		} else if m := admonitionPattern.FindStringSubmatch(line); m != nil {
+			if kind, ok := calloutKindOf(m[2]); ok {
+				indent = m[1]
+				c = callout{kind: kind, title: m[3]}
+				end, c.body = admonitionBody(lines, i+1, indent)
+			}
+		}
+		if end < 0 {
+			result = append(result, line)
+			continue
+		}
+
+		result = append(result, indent+placeholderFor(calloutPlaceholder, len(callouts)))
+		callouts = append(callouts, c)
+		i = end
+	}
+
+	if len(callouts) == 0 {
+		return content, nil
+	}
+	return strings.Join(result, "\n"), callouts
+}
+
+func calloutKindOf(s string) (string, bool) {
+	s = strings.ToLower(s)
+	if alias, ok := calloutAliases[s]; ok {
+		s = alias
+	}
+	_, ok := calloutKinds[s]
+	return s, ok
+}
+
+// alertBody collects the quoted lines following an alert's first line.
+func alertBody(lines []string, from int, indent string) (int, string) {
+	var body []string
+	end := from - 1
+	for j := from; j < len(lines); j++ {
+		l := strings.TrimPrefix(lines[j], indent)
+		if !strings.HasPrefix(l, ">") {
+			break
+		}
+		l = strings.TrimPrefix(l[1:], " ")
+		body = append(body, l)
+		end = j
+	}
+	return end, strings.Join(body, "\n")
+}
+
+// admonitionBody collects the indented lines following an admonition's first
+// line. Blank lines are included as long as more indented content follows.
+func admonitionBody(lines []string, from int, indent string) (int, string) {
+	bodyIndent := indent + "    "
+	var body []string
+	end := from - 1
+	for j := from; j < len(lines); j++ {
+		l := lines[j]
+		if strings.TrimSpace(l) == "" {
+			body = append(body, "")
+			continue
+		}
+		if !strings.HasPrefix(l, bodyIndent) && !strings.HasPrefix(l, indent+"\t") {
+			break
+		}
+		l = strings.TrimPrefix(l, bodyIndent)
+		l = strings.TrimPrefix(l, indent+"\t")
+		body = append(body, l)
+		end = j
+	}
+	return end, strings.Join(body[:max(0, end-from+1)], "\n")
+}
+
+// CalloutStyle is how a callout type is displayed. Custom JSON styles can set
+// these under a "callouts" key, for instance:
+//
+//	"callouts": { "note": { "color": "#4493F8", "prefix": "ℹ" } }
+type CalloutStyle = ansi.StylePrimitive
+
+// Render replaces the callout placeholders in glamour's output with boxed
+// callouts. The callout bodies are rendered with the given renderer options,
+// wrapped to fit within width. Colors are taken from the named style, with
+// the overrides on top.
+func (c Callouts) Render(out string, style string, overrides map[string]any, width int, options ...glamour.TermRendererOption) string {
+	if len(c) == 0 {
+		return out
+	}
+	// Leave a right margin matching glamour's document margin.
+	return c.replace(out, calloutPalette(style, overrides), max(0, width-2), options)
+}
+
+func (c Callouts) replace(out string, palette map[string]CalloutStyle, width int, options []glamour.TermRendererOption) string {
+	return replacePlaceholders(out, calloutPlaceholder, width, func(n, width int) (string, bool) {
+		if n >= len(c) {
+			return "", false
+		}
+		if width > 0 {
+			width = max(20, width)
+		}
+		return c[n].render(palette, width, options), true
+	})
+}
+
+// replacePlaceholders swaps lines of rendered output containing a placeholder
+// and index for the block returned by render. The block is indented like the
+// placeholder and gets the remaining width, if width is set.
+func replacePlaceholders(out, placeholder string, width int, render func(n, width int) (string, bool)) string {
+	lines := strings.Split(out, "\n")
+	for i, line := range lines {
+		plain := xansi.Strip(line)
+		idx := strings.Index(plain, placeholder)
+		if idx < 0 {
+			continue
+		}
+		digits, _, ok := strings.Cut(plain[idx+len(placeholder):], placeholderEnd)
+		n, err := strconv.Atoi(digits)
+		if !ok || err != nil {
+			continue
+		}
+		token := placeholderFor(placeholder, n)
+		at := strings.Index(line, token)
+		if at < 0 {
+			continue
+		}
+		prefix := plain[:idx]
+		var head, tail []string
+		if strings.Trim(prefix, " │") != "" {
+			// Glamour may join the placeholder with text around it, such as
+			// a list item. Keep that text on lines of its own.
+			head = append(head, line[:at])
+			prefix = strings.Repeat(" ", len(prefix)-len(strings.TrimLeft(prefix, " "))+2)
+		}
+		if after := line[at+len(token):]; strings.TrimSpace(xansi.Strip(after)) != "" {
+			tail = append(tail, prefix+after)
+		}
+		blockWidth := 0
+		if width > 0 {
+			blockWidth = max(1, width-xansi.StringWidth(prefix))
+		}
+		block, ok := render(n, blockWidth)
+		if !ok {
+			continue
+		}
+		blockLines := strings.Split(block, "\n")
+		for j := range blockLines {
+			blockLines[j] = prefix + blockLines[j]
+		}
+		lines[i] = strings.Join(slices.Concat(head, blockLines, tail), "\n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c callout) render(palette map[string]CalloutStyle, width int, options []glamour.TermRendererOption) string {
+	kind := calloutKinds[c.kind]
+	st := palette[c.kind]
+
+	icon := kind.icon
+	if st.Prefix != "" {
+		icon = strings.TrimSpace(st.Prefix)
+	}
+	title := c.title
+	if title == "" {
+		title = kind.title
+	}
+
+	border := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Padding(0, 1)
+	titleStyle := lipgloss.NewStyle().Bold(true)
+	if st.Color != nil {
+		border = border.BorderForeground(lipgloss.Color(*st.Color))
+		titleStyle = titleStyle.Foreground(lipgloss.Color(*st.Color))
+	}
+
+	innerWidth := 0
+	if width > 0 {
+		innerWidth = max(1, width-4) // border and padding
+		border = border.Width(width - 2)
+	}
+
+	body := c.renderBody(innerWidth, palette, options)
+	content := titleStyle.Render(icon + " " + title)
+	if body != "" {
+		content += "\n" + body
+	}
+	return border.Render(content)
+}
+
+// renderBody renders the markdown body of a callout, including any callouts
+// nested within it.
+func (c callout) renderBody(width int, palette map[string]CalloutStyle, options []glamour.TermRendererOption) string {
+	if strings.TrimSpace(c.body) == "" {
+		return ""
+	}
+	body, nested := ExtractCallouts(c.body)
+	r, err := glamour.NewTermRenderer(append(options, glamour.WithWordWrap(width))...)
+	if err != nil {
+		return c.body
+	}
+	out, err := r.Render(body)
+	if err != nil {
+		return c.body
+	}
+	return trimRendered(nested.replace(out, palette, width, options))
+}
+
+// trimRendered removes the margins glamour adds around a document: blank
+// lines at the start and end, the common indentation and trailing padding.
+func trimRendered(out string) string {
+	lines := strings.Split(out, "\n")
+	for len(lines) > 0 && strings.TrimSpace(xansi.Strip(lines[0])) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(xansi.Strip(lines[len(lines)-1])) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	indent := -1
+	for _, l := range lines {
+		plain := xansi.Strip(l)
+		if strings.TrimSpace(plain) == "" {
+			continue
+		}
+		n := len(plain) - len(strings.TrimLeft(plain, " "))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	indent = max(0, indent)
+
+	for i, l := range lines {
+		w := xansi.StringWidth(strings.TrimRight(xansi.Strip(l), " "))
+		lines[i] = xansi.Cut(l, indent, max(indent, w))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// calloutPalette returns the callout styles for the given glamour style.
+// They have the default colors for the background of the built-in style it's
+// based on; JSON styles and overrides can change them with a "callouts" key.
+func calloutPalette(style string, overrides map[string]any) map[string]CalloutStyle {
+	values, base, err := loadStyleValues(style, overrides)
+	if err != nil {
+		values = nil
+	}
+
+	palette := make(map[string]CalloutStyle, len(calloutKinds))
+	if base != styles.NoTTYStyle && base != styles.AsciiStyle {
+		dark := base != styles.LightStyle
+		for name, kind := range calloutKinds {
+			color := kind.dark
+			if !dark {
+				color = kind.light
+			}
+			palette[name] = CalloutStyle{Color: &color}
+		}
+	}
+
+	b, err := json.Marshal(values["callouts"])
+	if err != nil {
+		return palette
+	}
+	var custom map[string]CalloutStyle
+	if err := json.Unmarshal(b, &custom); err != nil {
+		return palette
+	}
+	for name, st := range custom {
+		name, ok := calloutKindOf(name)
+		if !ok {
+			continue
+		}
+		if st.Color == nil {
+			st.Color = palette[name].Color
+		}
+		palette[name] = st
+	}
+	return palette
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/glamour"
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+func TestExtractCallouts_GitHubAlert(t *testing.T) {
+	input := "Intro\n\n> [!WARNING]\n> Be careful.\n> Really.\n\nAfter"
+	result, callouts := ExtractCallouts(input)
+
+	if len(callouts) != 1 {
+		t.Fatalf("expected 1 callout, got %d", len(callouts))
+	}
+	if callouts[0].kind != "warning" {
+		t.Errorf("expected kind warning, got %q", callouts[0].kind)
+	}
+	if callouts[0].body != "Be careful.\nReally." {
+		t.Errorf("unexpected body %q", callouts[0].body)
+	}
+	if result != "Intro\n\n"+placeholderFor(calloutPlaceholder, 0)+"\n\nAfter" {
+		t.Errorf("unexpected result %q", result)
+	}
+}
+
+func TestExtractCallouts_Admonition(t *testing.T) {
+	input := "!!! tldr \"In short\"\n    First line.\n\n    Second paragraph.\n\nAfter"
+	result, callouts := ExtractCallouts(input)
+
+	if len(callouts) != 1 {
+		t.Fatalf("expected 1 callout, got %d", len(callouts))
+	}
+	c := callouts[0]
+	if c.kind != "abstract" || c.title != "In short" {
+		t.Errorf("unexpected callout %+v", c)
+	}
+	if c.body != "First line.\n\nSecond paragraph." {
+		t.Errorf("unexpected body %q", c.body)
+	}
+	if !strings.HasSuffix(result, "\n\nAfter") {
+		t.Errorf("content after the admonition should be kept, got %q", result)
+	}
+}
+
+func TestExtractCallouts_Ignored(t *testing.T) {
+	for _, input := range []string{
+		"```\n> [!NOTE]\n> Code\n```",
+		"> [!UNKNOWN]\n> Not a callout",
+		"> Plain quote",
+	} {
+		result, callouts := ExtractCallouts(input)
+		if len(callouts) != 0 || result != input {
+			t.Errorf("expected %q to be left unchanged, got %q", input, result)
+		}
+	}
+}
+
+func TestCalloutsRender(t *testing.T) {
+	input := "> [!TIP] Custom title\n> Some *body* text."
+	md, callouts := ExtractCallouts(input)
+
+	options := []glamour.TermRendererOption{glamour.WithStandardStyle("notty")}
+	r, err := glamour.NewTermRenderer(append(options, glamour.WithWordWrap(40))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Render(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = xansi.Strip(callouts.Render(out, "notty", nil, 40, options...))
+
+	if strings.Contains(out, calloutPlaceholder) {
+		t.Errorf("placeholder should be replaced, got %q", out)
+	}
+	for _, want := range []string{"╭", "★ Custom title", "Some *body* text.", "╰"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if w := xansi.StringWidth(line); w > 40 {
+			t.Errorf("line exceeds width (%d): %q", w, line)
+		}
+	}
+}
+
+func TestCalloutsRender_Placeholders(t *testing.T) {
+	callouts := Callouts{{kind: "note", body: "Body."}}
+	palette := calloutPalette("notty", nil)
+
+	// Text that looks like a placeholder is left alone.
+	for _, out := range []string{"After GLOWCALLOUT0 text.", "After callout0 text."} {
+		if got := callouts.replace(out, palette, 40, nil); got != out {
+			t.Errorf("replace(%q) = %q, want it unchanged", out, got)
+		}
+	}
+
+	// Text around a placeholder is kept.
+	out := "  Before " + placeholderFor(calloutPlaceholder, 0) + " after."
+	got := xansi.Strip(callouts.replace(out, palette, 40, nil))
+	lines := strings.Split(got, "\n")
+	if first, last := lines[0], lines[len(lines)-1]; first != "  Before " || strings.TrimSpace(last) != "after." {
+		t.Errorf("replace() lost the text around the placeholder:\n%s", got)
+	}
+	if !strings.Contains(got, "Body.") {
+		t.Errorf("replace() didn't render the callout:\n%s", got)
+	}
+}
+
+func TestCalloutPalette_CustomStyle(t *testing.T) {
+	style := filepath.Join(t.TempDir(), "style.json")
+	custom := `{"document": {}, "callouts": {"hint": {"color": "#123456", "prefix": "!"}}}`
+	if err := os.WriteFile(style, []byte(custom), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	palette := calloutPalette(style, nil)
+	tip := palette["tip"]
+	if tip.Color == nil || *tip.Color != "#123456" || tip.Prefix != "!" {
+		t.Errorf("custom tip style not applied: %+v", tip)
+	}
+	if note := palette["note"]; note.Color == nil || *note.Color != calloutKinds["note"].dark {
+		t.Errorf("other kinds should keep their default colors: %+v", note)
+	}
+	light := filepath.Join(t.TempDir(), "light.json")
+	if err := os.WriteFile(light, []byte(`{"extends": "light"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	overrides := map[string]any{"callouts": map[string]any{"note": map[string]any{"prefix": "N"}}}
+	if note := calloutPalette(light, overrides)["note"]; *note.Color != calloutKinds["note"].light || note.Prefix != "N" {
+		t.Errorf("styles extending light should use light colors and the overrides: %+v", note)
+	}
+	if len(calloutPalette("notty", nil)) != 0 {
+		t.Error("notty style should not use colors")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package utils
+
+// terminalCellSize returns the size of a terminal cell in pixels, or zeros if
+// the terminal doesn't report it.
+func terminalCellSize() (int, int) {
+	return 0, 0
+}
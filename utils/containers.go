@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// container is a block other blocks are nested in: a block quote, a list item
+// or the body of an admonition, whose lines are indented.
+type container struct {
+	quote  bool
+	indent int // columns the contents of a list item or admonition start at
+}
+
+var (
+	listMarkerPattern       = regexp.MustCompile(`^ {0,3}(?:[-+*]|\d{1,9}[.)])(?:[ \t]+|$)`)
+	admonitionMarkerPattern = regexp.MustCompile(`^(?:!!!|\?\?\?\+?)[ \t]+\w+`)
+)
+
+// containerScanner follows the containers of the lines of a markdown
+// document, after the rules of CommonMark, to find the blocks nested in them.
+// Lazy continuation lines, which only continue paragraphs, aren't followed.
+type containerScanner struct {
+	open []container
+}
+
+// scan returns the prefix of line made of the markers and indentation of its
+// containers, and the rest of it. In a fenced block, no containers start,
+// but the block ends when its containers do, which continued tells.
+func (s *containerScanner) scan(line string, inFence bool) (prefix, rest string, continued bool) {
+	rest = line
+	continued = true
+	for i, c := range s.open {
+		var ok bool
+		if c.quote {
+			rest, ok = cutQuoteMarker(rest)
+		} else if strings.TrimSpace(rest) == "" {
+			rest, ok = strings.TrimLeft(rest, " \t"), true
+		} else if columns(rest) >= c.indent {
+			rest, ok = cutColumns(rest, c.indent), true
+		}
+		if !ok {
+			s.open = s.open[:i]
+			continued = false
+			break
+		}
+	}
+
+	for !inFence || !continued {
+		if r, ok := cutQuoteMarker(rest); ok {
+			s.open = append(s.open, container{quote: true})
+			rest = r
+			continue
+		}
+		if m := listMarkerPattern.FindString(rest); m != "" {
+			// Contents indented by more than 4 columns past the marker are
+			// indented code, starting a column after it.
+			marker := len(strings.TrimRight(m, " \t"))
+			indent := columnsOf(m)
+			if indent-marker > 4 || strings.TrimSpace(rest[len(m):]) == "" {
+				indent = marker + 1
+			}
+			s.open = append(s.open, container{indent: indent})
+			rest = cutColumns(rest, indent)
+			continue
+		}
+		if indent := columns(rest); indent < 4 && admonitionMarkerPattern.MatchString(strings.TrimLeft(rest, " \t")) {
+			// The title is all there is on the first line.
+			s.open = append(s.open, container{indent: indent + 4})
+			rest = ""
+		}
+		break
+	}
+	return line[:len(line)-len(rest)], rest, continued
+}
+
+// cutQuoteMarker returns s after the > that starts a line of a block quote,
+// and the space after it.
+func cutQuoteMarker(s string) (string, bool) {
+	t := strings.TrimLeft(s, " ")
+	if len(s)-len(t) > 3 || !strings.HasPrefix(t, ">") {
+		return s, false
+	}
+	t = t[1:]
+	if strings.HasPrefix(t, " ") || strings.HasPrefix(t, "\t") {
+		t = t[1:]
+	}
+	return t, true
+}
+
+// columns returns the columns of indentation of s, with tab stops every 4
+// columns.
+func columns(s string) int {
+	n := 0
+	for _, c := range s {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4 - n%4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// columnsOf returns the columns s takes, with tab stops every 4 columns.
+func columnsOf(s string) int {
+	n := 0
+	for _, c := range s {
+		if c == '\t' {
+			n += 4 - n%4
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// cutColumns returns s after n columns of indentation, or the markers of a
+// list item that are that wide. A tab is removed whole.
+func cutColumns(s string, n int) string {
+	col := 0
+	for i, c := range s {
+		if col >= n {
+			return s[i:]
+		}
+		if c == '\t' {
+			col += 4 - col%4
+		} else {
+			col++
+		}
+	}
+	return ""
+}
+
+// continuation returns what's before the lines of a container after the
+// first, given what's before the first: its quote markers, and spaces in
+// place of list markers.
+func continuation(prefix string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '>' || r == ' ' || r == '\t' {
+			return r
+		}
+		return ' '
+	}, prefix)
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+// DiffOp describes how a block differs between two documents.
+type DiffOp int
+
+// Block differences.
+const (
+	DiffEqual DiffOp = iota
+	DiffAdded
+	DiffRemoved
+	DiffChanged
+)
+
+// minChangedSimilarity is how similar a removed and an added block need to
+// be to show them as a single changed block.
+const minChangedSimilarity = 0.5
+
+// DiffBlock is a block of markdown in the comparison of two documents. Old
+// is empty for added blocks and New is empty for removed ones.
+type DiffBlock struct {
+	Op  DiffOp
+	Old string
+	New string
+}
+
+var diffGutters = map[DiffOp]struct {
+	mark  string
+	color string
+}{
+	DiffAdded:   {"+", "#3FB950"},
+	DiffRemoved: {"-", "#F85149"},
+	DiffChanged: {"~", "#D29922"},
+}
+
+// SplitBlocks splits markdown into its top-level blocks, such as paragraphs,
+// headings, lists and code blocks. Blocks are separated by blank lines;
+// fenced code blocks and indented continuations are kept together.
+func SplitBlocks(md string) []string {
+	var (
+		blocks  []string
+		current []string
+		fence   rune
+		fenceN  int
+	)
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		block := strings.Join(current, "\n")
+		current = nil
+
+		// Indented blocks continue the list item or block before them.
+		if len(blocks) > 0 && (block[0] == ' ' || block[0] == '\t') {
+			blocks[len(blocks)-1] += "\n\n" + block
+			return
+		}
+		blocks = append(blocks, block)
+	}
+
+	for _, line := range strings.Split(md, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		_, char, length, info := parseFenceLine(line)
+		switch {
+		case fence != 0:
+			if char == fence && length >= fenceN && info == "" {
+				fence = 0
+			}
+		case length >= 3:
+			fence, fenceN = char, length
+		case line == "":
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return blocks
+}
+
+// DiffBlocks compares the blocks of two documents. Removed blocks that are
+// followed by a similar added block are reported as changed.
+func DiffBlocks(from, to []string) []DiffBlock {
+	// Longest common subsequence of blocks.
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var (
+		blocks         []DiffBlock
+		removed, added []string
+		i, j           int
+	)
+	flush := func() {
+		blocks = append(blocks, pairChanges(removed, added)...)
+		removed, added = nil, nil
+	}
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && from[i] == to[j]:
+			flush()
+			blocks = append(blocks, DiffBlock{Op: DiffEqual, Old: from[i], New: to[j]})
+			i++
+			j++
+		case i < len(from) && (j == len(to) || lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, from[i])
+			i++
+		default:
+			added = append(added, to[j])
+			j++
+		}
+	}
+	flush()
+	return blocks
+}
+
+// pairChanges turns a run of removed and added blocks into diff blocks,
+// pairing up similar blocks in order as changes.
+func pairChanges(removed, added []string) []DiffBlock {
+	var blocks []DiffBlock
+	j := 0
+	for _, r := range removed {
+		k := j
+		for k < len(added) && similarity(r, added[k]) < minChangedSimilarity {
+			k++
+		}
+		if k == len(added) {
+			blocks = append(blocks, DiffBlock{Op: DiffRemoved, Old: r})
+			continue
+		}
+		for _, a := range added[j:k] {
+			blocks = append(blocks, DiffBlock{Op: DiffAdded, New: a})
+		}
+		blocks = append(blocks, DiffBlock{Op: DiffChanged, Old: r, New: added[k]})
+		j = k + 1
+	}
+	for _, a := range added[j:] {
+		blocks = append(blocks, DiffBlock{Op: DiffAdded, New: a})
+	}
+	return blocks
+}
+
+// similarity returns the share of words two blocks have in common, from 0
+// to 1.
+func similarity(a, b string) float64 {
+	wa, wb := strings.Fields(a), strings.Fields(b)
+	if len(wa)+len(wb) == 0 {
+		return 1
+	}
+	counts := make(map[string]int, len(wa))
+	for _, w := range wa {
+		counts[w]++
+	}
+	common := 0
+	for _, w := range wb {
+		if counts[w] > 0 {
+			counts[w]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(wa)+len(wb))
+}
+
+// RenderDiff renders the blocks of a diff with render and marks added,
+// removed and changed blocks in a gutter. Changed blocks show the old
+// version followed by the new one. It returns the output and the line
+// numbers at which each run of changes starts.
+func RenderDiff(blocks []DiffBlock, render func(string) (string, error)) (string, []int, error) {
+	var (
+		lines   []string
+		changes []int
+		prev    = DiffEqual
+	)
+	write := func(md string, op DiffOp) error {
+		out, err := render(md)
+		if err != nil {
+			return err
+		}
+		gutter := "  "
+		if g, ok := diffGutters[op]; ok {
+			gutter = lipgloss.NewStyle().Foreground(lipgloss.Color(g.color)).Render(g.mark) + " "
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		for _, l := range trimBlankLines(strings.Split(out, "\n")) {
+			lines = append(lines, gutter+l)
+		}
+		return nil
+	}
+
+	for _, b := range blocks {
+		if b.Op != DiffEqual && prev == DiffEqual {
+			changes = append(changes, len(lines)+min(len(lines), 1))
+		}
+		prev = b.Op
+
+		var err error
+		switch b.Op {
+		case DiffEqual, DiffAdded:
+			err = write(b.New, b.Op)
+		case DiffRemoved:
+			err = write(b.Old, b.Op)
+		case DiffChanged:
+			if err = write(b.Old, DiffRemoved); err == nil {
+				err = write(b.New, DiffChanged)
+			}
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return strings.Join(lines, "\n") + "\n", changes, nil
+}
+
+// trimBlankLines removes leading and trailing lines that are blank once
+// escape sequences are stripped.
+func trimBlankLines(lines []string) []string {
+	blank := func(l string) bool {
+		return strings.TrimSpace(xansi.Strip(l)) == ""
+	}
+	for len(lines) > 0 && blank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && blank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitBlocks(t *testing.T) {
+	md := "# Title\n\nFirst paragraph\ncontinues here.\n\n```go\nfunc main() {\n\n}\n```\n\n- item\n\n  more about the item\n"
+	want := []string{
+		"# Title",
+		"First paragraph\ncontinues here.",
+		"```go\nfunc main() {\n\n}\n```",
+		"- item\n\n  more about the item",
+	}
+	if got := SplitBlocks(md); !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitBlocks() = %q, want %q", got, want)
+	}
+}
+
+func TestDiffBlocks(t *testing.T) {
+	from := []string{"# Title", "The quick brown fox jumps.", "Old paragraph nobody wants.", "Outro"}
+	to := []string{"# Title", "The quick red fox jumps.", "Outro", "Appendix"}
+	want := []DiffBlock{
+		{Op: DiffEqual, Old: "# Title", New: "# Title"},
+		{Op: DiffChanged, Old: "The quick brown fox jumps.", New: "The quick red fox jumps."},
+		{Op: DiffRemoved, Old: "Old paragraph nobody wants."},
+		{Op: DiffEqual, Old: "Outro", New: "Outro"},
+		{Op: DiffAdded, New: "Appendix"},
+	}
+	if got := DiffBlocks(from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffBlocks() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestRenderDiff(t *testing.T) {
+	blocks := []DiffBlock{
+		{Op: DiffEqual, Old: "a", New: "a"},
+		{Op: DiffChanged, Old: "b", New: "b2"},
+		{Op: DiffAdded, New: "c"},
+		{Op: DiffEqual, Old: "d", New: "d"},
+		{Op: DiffRemoved, Old: "e"},
+	}
+	render := func(md string) (string, error) {
+		return "\n" + md + "\n\n", nil
+	}
+	out, changes, err := RenderDiff(blocks, render)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	want := []string{"  a", "", "- b", "", "~ b2", "", "+ c", "", "  d", "", "- e"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("RenderDiff() lines = %q, want %q", lines, want)
+	}
+	if want := []int{2, 10}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("RenderDiff() changes = %v, want %v", changes, want)
+	}
+}
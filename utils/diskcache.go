@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultCacheSize is the size a DiskCache without a maximum size is kept
+// under.
+const DefaultCacheSize = 32 << 20
+
+// DiskCache keeps rendered text in files named after the hash of what it was
+// rendered from, so that it's reused across runs. When the files grow larger
+// than MaxSize, the ones used least recently are removed.
+type DiskCache struct {
+	Dir     string
+	MaxSize int64 // DefaultCacheSize if 0
+}
+
+// CacheKey returns the key of the text rendered from parts, like the source of
+// a diagram and the options it was rendered with.
+func CacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		// Separate the parts, so that moving text between them changes the key.
+		fmt.Fprintf(h, "%d:%s", len(p), p) //nolint:errcheck
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *DiskCache) path(key string) string {
+	return filepath.Join(c.Dir, key)
+}
+
+// Get returns the text cached under key.
+func (c *DiskCache) Get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	// Mark the entry as used, for eviction.
+	now := time.Now()
+	_ = os.Chtimes(c.path(key), now, now)
+	return string(b), true
+}
+
+// Put caches text under key, then evicts entries if the cache has grown too
+// large.
+func (c *DiskCache) Put(key, text string) error {
+	if c == nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return fmt.Errorf("unable to create cache directory: %w", err)
+	}
+
+	// Write to a temporary file first, so that other instances never read a
+	// partial entry.
+	f, err := os.CreateTemp(c.Dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("unable to write cache: %w", err)
+	}
+	_, err = f.WriteString(text)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("unable to write cache: %w", err)
+	}
+	return c.evict()
+}
+
+// evict removes the entries used least recently until the cache fits in its
+// maximum size.
+func (c *DiskCache) evict() error {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return fmt.Errorf("unable to read cache directory: %w", err)
+	}
+
+	type entry struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var (
+		files []entry
+		total int64
+	)
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// Evicted by another instance.
+			continue
+		} else if err != nil {
+			return fmt.Errorf("unable to read cache directory: %w", err)
+		}
+		files = append(files, entry{e.Name(), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+
+	maxSize := cmp.Or(c.MaxSize, DefaultCacheSize)
+	if total <= maxSize {
+		return nil
+	}
+	slices.SortFunc(files, func(a, b entry) int {
+		return a.modTime.Compare(b.modTime)
+	})
+	for _, f := range files {
+		if total <= maxSize {
+			break
+		}
+		if err := os.Remove(c.path(f.name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("unable to evict cache entry: %w", err)
+		}
+		total -= f.size
+	}
+	return nil
+}
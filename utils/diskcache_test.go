@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskCache(t *testing.T) {
+	c := &DiskCache{Dir: filepath.Join(t.TempDir(), "cache")}
+	key := CacheKey("a", "b")
+	if key == CacheKey("ab") || key == CacheKey("a", "b", "") {
+		t.Errorf("CacheKey() doesn't separate its parts")
+	}
+
+	if _, ok := c.Get(key); ok {
+		t.Fatal("Get() found an entry in an empty cache")
+	}
+	if err := c.Put(key, "rendered"); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := c.Get(key); !ok || got != "rendered" {
+		t.Errorf("Get() = %q, %v, want %q", got, ok, "rendered")
+	}
+
+	var nilCache *DiskCache
+	if err := nilCache.Put(key, "rendered"); err != nil {
+		t.Error(err)
+	}
+	if _, ok := nilCache.Get(key); ok {
+		t.Error("a nil cache found an entry")
+	}
+}
+
+func TestDiskCache_Evict(t *testing.T) {
+	c := &DiskCache{Dir: t.TempDir(), MaxSize: 25}
+	old := time.Now().Add(-time.Hour)
+	for i, key := range []string{"a", "b"} {
+		if err := c.Put(key, strings.Repeat(key, 10)); err != nil {
+			t.Fatal(err)
+		}
+		at := old.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(c.Dir, key), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Using a makes b the least recently used entry.
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get() didn't find a")
+	}
+	if err := c.Put("c", strings.Repeat("c", 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := c.Get(key); ok != want {
+			t.Errorf("Get(%q) found = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestRenderMermaidBlocks_Cache(t *testing.T) {
+	DiagramCache = &DiskCache{Dir: t.TempDir()}
+	t.Cleanup(func() { DiagramCache = nil })
+	// Diagrams rendered by other tests are kept in memory.
+	mermaidResults.Clear()
+
+	src := "```mermaid\ngraph LR\nA --> B\n```"
+	RenderMermaidBlocks(src, "unicode", 80)
+	entries, err := os.ReadDir(DiagramCache.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("cached %d diagrams, want 1", len(entries))
+	}
+
+	// The cached diagram is used instead of rendering it again, in another
+	// run.
+	path := filepath.Join(DiagramCache.Dir, entries[0].Name())
+	if err := os.WriteFile(path, []byte("cached"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mermaidResults.Clear()
+	if got := RenderMermaidBlocks(src, "unicode", 80); !strings.Contains(got, "cached") {
+		t.Errorf("RenderMermaidBlocks() didn't use the cache:\n%s", got)
+	}
+
+	// Other modes and widths are rendered on their own.
+	if got := RenderMermaidBlocks(src, "ascii", 80); strings.Contains(got, "cached") {
+		t.Errorf("RenderMermaidBlocks() used the diagram of another mode:\n%s", got)
+	}
+	if got := RenderMermaidBlocks(src, "unicode", 40); strings.Contains(got, "cached") {
+		t.Errorf("RenderMermaidBlocks() used the diagram of another width:\n%s", got)
+	}
+}
@@ -0,0 +1,585 @@
+package utils
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RenderDotBlocks renders Graphviz DOT code blocks, like RenderMermaidBlocks
+// does for mermaid diagrams. Mode "raw" returns content unchanged; "ascii"
+// and "unicode" render graphs.
+func RenderDotBlocks(content string, mode string, maxWidth int) string {
+	return RenderDotBlocksContext(context.Background(), content, mode, maxWidth)
+}
+
+// RenderDotBlocksContext is like RenderDotBlocks, but stops when ctx is done,
+// like RenderBlocksContext.
+func RenderDotBlocksContext(ctx context.Context, content string, mode string, maxWidth int) string {
+	if content == "" {
+		return content
+	}
+
+	mode = strings.ToLower(mode)
+	if mode == "raw" {
+		return content
+	}
+
+	r := dotRenderer{ascii: mode == "ascii"}
+	return RenderBlocksContext(ctx, content, BlockRenderers{"dot": r, "graphviz": r, "gv": r}, maxWidth)
+}
+
+// dotRenderer renders DOT graphs as ASCII or Unicode art. Graphs are laid out
+// in layers from top to bottom, or left to right with rankdir=LR, by the
+// mermaid flowchart renderer.
+type dotRenderer struct {
+	ascii bool
+}
+
+func (r dotRenderer) RenderBlock(content string, width int) (string, error) {
+	return r.RenderBlockContext(context.Background(), content, width)
+}
+
+func (r dotRenderer) RenderBlockContext(ctx context.Context, content string, width int) (string, error) {
+	g, err := parseDot(content)
+	if err != nil {
+		return "", err
+	}
+	out, err := renderMermaidContext(ctx, g.flowchart(), r.ascii, width)
+	if err != nil || g.directed {
+		return out, err
+	}
+	// The layout engine only draws arrows, so the links of undirected graphs
+	// lose their heads afterwards.
+	return dropArrowHeads(out), nil
+}
+
+// arrowHeads are the heads the layout engine ends arrows with, by the way
+// they point, and the line they're replaced with to make links.
+var arrowHeads = map[rune]struct {
+	dx, dy int
+	line   rune
+}{
+	'▲': {0, -1, '│'}, '▼': {0, 1, '│'}, '◄': {-1, 0, '─'}, '►': {1, 0, '─'},
+	'^': {0, -1, '|'}, 'v': {0, 1, '|'}, '<': {-1, 0, '-'}, '>': {1, 0, '-'},
+}
+
+// Characters arrows come from, and the borders of the boxes they point at,
+// by whether they're vertical.
+const (
+	dotVerticalLines   = "│┼├┤┬┴┌┐└┘|+"
+	dotHorizontalLines = "─┼├┤┬┴┌┐└┘-+"
+)
+
+// dropArrowHeads replaces the heads of the arrows of a rendered diagram with
+// the lines they end. Only characters between a line and the border of a box
+// are heads, so that labels are left alone. The engine puts a character in
+// each cell, so runes line up across lines.
+func dropArrowHeads(out string) string {
+	lines := strings.Split(out, "\n")
+	grid := make([][]rune, len(lines))
+	for y, l := range lines {
+		grid[y] = []rune(l)
+	}
+	at := func(x, y int) rune {
+		if y < 0 || y >= len(grid) || x < 0 || x >= len(grid[y]) {
+			return ' '
+		}
+		return grid[y][x]
+	}
+
+	for y, row := range grid {
+		for x, c := range row {
+			head, ok := arrowHeads[c]
+			if !ok {
+				continue
+			}
+			from, border := dotVerticalLines, dotHorizontalLines
+			if head.dy == 0 {
+				from, border = dotHorizontalLines, dotVerticalLines
+			}
+			if strings.ContainsRune(from, at(x-head.dx, y-head.dy)) && strings.ContainsRune(border, at(x+head.dx, y+head.dy)) {
+				row[x] = head.line
+			}
+		}
+		lines[y] = string(row)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dotGraph is a parsed DOT graph.
+type dotGraph struct {
+	directed bool
+	rankdir  string
+	nodes    []*dotNode // in order of appearance
+	byID     map[string]*dotNode
+	edges    []dotEdge
+	clusters []*dotCluster // top-level clusters
+}
+
+type dotNode struct {
+	id, label string
+	cluster   *dotCluster
+}
+
+type dotEdge struct {
+	from, to *dotNode
+	label    string
+}
+
+// dotCluster is a subgraph whose name starts with "cluster", which is drawn
+// around its nodes.
+type dotCluster struct {
+	label    string
+	nodes    []*dotNode
+	children []*dotCluster
+}
+
+// node returns the node with the id, adding it to the cluster if it's new.
+func (g *dotGraph) node(id string, cluster *dotCluster) *dotNode {
+	if n, ok := g.byID[id]; ok {
+		return n
+	}
+	n := &dotNode{id: id, cluster: cluster}
+	g.byID[id] = n
+	g.nodes = append(g.nodes, n)
+	if cluster != nil {
+		cluster.nodes = append(cluster.nodes, n)
+	}
+	return n
+}
+
+// flowchart returns the graph as a mermaid flowchart. Nodes are declared in
+// their clusters first, since the renderer puts nodes in the subgraph they
+// first appear in.
+func (g *dotGraph) flowchart() string {
+	direction := "TD"
+	if r := strings.ToUpper(g.rankdir); r == "LR" || r == "RL" {
+		direction = "LR"
+	}
+	ids := make(map[*dotNode]string, len(g.nodes))
+	for i, n := range g.nodes {
+		ids[n] = "n" + strconv.Itoa(i)
+	}
+	declare := func(lines []string, n *dotNode) []string {
+		label := n.label
+		if label == "" {
+			label = n.id
+		}
+		return append(lines, ids[n]+"["+dotMermaidText(label)+"]")
+	}
+
+	lines := []string{"graph " + direction}
+	var cluster func(c *dotCluster)
+	cluster = func(c *dotCluster) {
+		lines = append(lines, "subgraph "+dotMermaidText(c.label))
+		for _, n := range c.nodes {
+			lines = declare(lines, n)
+		}
+		for _, child := range c.children {
+			cluster(child)
+		}
+		lines = append(lines, "end")
+	}
+	for _, c := range g.clusters {
+		cluster(c)
+	}
+	for _, n := range g.nodes {
+		if n.cluster == nil {
+			lines = declare(lines, n)
+		}
+	}
+	for _, e := range g.edges {
+		if e.label != "" {
+			label := strings.ReplaceAll(dotMermaidText(e.label), "|", "/")
+			lines = append(lines, ids[e.from]+" -->|"+label+"| "+ids[e.to])
+		} else {
+			lines = append(lines, ids[e.from]+" --> "+ids[e.to])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dotMermaidText escapes text that has a meaning in flowcharts.
+var dotMermaidText = strings.NewReplacer(
+	`\`, "/",
+	"%%", "%",
+	":::", "::",
+	"-->", "->",
+	" & ", " and ",
+).Replace
+
+// parseDot parses a DOT graph. Attributes other than labels and rankdir are
+// ignored.
+func parseDot(src string) (*dotGraph, error) {
+	tokens, err := dotTokens(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &dotParser{tokens: tokens, g: &dotGraph{byID: map[string]*dotNode{}}}
+	if err := p.graph(); err != nil {
+		return nil, err
+	}
+	return p.g, nil
+}
+
+// dotToken is a token of the DOT language. Quoted and HTML strings are
+// unquoted, and keep quoted set so they're never taken for keywords.
+type dotToken struct {
+	text   string
+	quoted bool
+}
+
+// dotTokens splits DOT source into tokens.
+func dotTokens(src string) ([]dotToken, error) {
+	var tokens []dotToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case strings.HasPrefix(src[i:], "//") || c == '#' && (i == 0 || src[i-1] == '\n'):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			i += end
+
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated comment")
+			}
+			i += end + 4
+
+		case strings.HasPrefix(src[i:], "->") || strings.HasPrefix(src[i:], "--"):
+			tokens = append(tokens, dotToken{text: src[i : i+2]})
+			i += 2
+
+		case strings.ContainsRune("{}[]=;,:", rune(c)):
+			tokens = append(tokens, dotToken{text: string(c)})
+			i++
+
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					switch src[j] {
+					case 'n', 'l', 'r':
+						b.WriteByte(' ')
+					case '\n':
+					default:
+						if src[j] != '"' {
+							b.WriteByte('\\')
+						}
+						b.WriteByte(src[j])
+					}
+					continue
+				}
+				b.WriteByte(src[j])
+			}
+			if j == len(src) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, dotToken{text: strings.Join(strings.Fields(b.String()), " "), quoted: true})
+			i = j + 1
+
+		case c == '<':
+			// HTML string: keep the text, without tags.
+			depth, j := 0, i
+			for ; j < len(src); j++ {
+				if src[j] == '<' {
+					depth++
+				} else if src[j] == '>' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			if j == len(src) {
+				return nil, errors.New("unterminated HTML string")
+			}
+			text := htmlTagPattern.ReplaceAllString(src[i+1:j], " ")
+			tokens = append(tokens, dotToken{text: strings.Join(strings.Fields(text), " "), quoted: true})
+			i = j + 1
+
+		default:
+			j := i
+			for j < len(src) && !strings.ContainsRune(" \t\r\n{}[]=;,:\"<", rune(src[j])) &&
+				!strings.HasPrefix(src[j:], "->") && !strings.HasPrefix(src[j:], "--") {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+			tokens = append(tokens, dotToken{text: src[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+type dotParser struct {
+	tokens []dotToken
+	pos    int
+	g      *dotGraph
+}
+
+// peek returns the next token, or an empty one at the end.
+func (p *dotParser) peek() dotToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return dotToken{}
+}
+
+// is returns whether the next token is the unquoted text, ignoring case.
+func (p *dotParser) is(text string) bool {
+	t := p.peek()
+	return !t.quoted && strings.EqualFold(t.text, text)
+}
+
+// accept consumes the next token if it is the unquoted text.
+func (p *dotParser) accept(text string) bool {
+	if p.is(text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *dotParser) expect(text string) error {
+	if !p.accept(text) {
+		if p.pos >= len(p.tokens) {
+			return fmt.Errorf("expected %q at end of graph", text)
+		}
+		return fmt.Errorf("expected %q, got %q", text, p.peek().text)
+	}
+	return nil
+}
+
+// id consumes an identifier.
+func (p *dotParser) id() (string, error) {
+	t := p.peek()
+	if !t.quoted && (t.text == "" || strings.Contains("{}[]=;,:", t.text) || t.text == "->" || t.text == "--") {
+		if p.pos >= len(p.tokens) {
+			return "", errors.New("expected an identifier at end of graph")
+		}
+		return "", fmt.Errorf("expected an identifier, got %q", t.text)
+	}
+	p.pos++
+	return t.text, nil
+}
+
+// graph parses: [strict] (graph | digraph) [ID] { stmt_list }
+func (p *dotParser) graph() error {
+	p.accept("strict")
+	switch {
+	case p.accept("digraph"):
+		p.g.directed = true
+	case p.accept("graph"):
+	default:
+		return errors.New("expected graph or digraph")
+	}
+	if !p.is("{") {
+		if _, err := p.id(); err != nil {
+			return err
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	if _, err := p.stmts(nil); err != nil {
+		return err
+	}
+	if p.pos < len(p.tokens) {
+		return fmt.Errorf("unexpected %q after graph", p.peek().text)
+	}
+	return nil
+}
+
+// stmts parses statements up to the closing brace, and returns the nodes
+// they mention.
+func (p *dotParser) stmts(cluster *dotCluster) ([]*dotNode, error) {
+	var nodes []*dotNode
+	for !p.accept("}") {
+		if p.pos >= len(p.tokens) {
+			return nil, errors.New(`expected "}" at end of graph`)
+		}
+		n, err := p.stmt(cluster)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n...)
+		p.accept(";")
+	}
+	return nodes, nil
+}
+
+// stmt parses a node, edge, attribute or subgraph statement.
+func (p *dotParser) stmt(cluster *dotCluster) ([]*dotNode, error) {
+	if p.is("graph") || p.is("node") || p.is("edge") {
+		kind := strings.ToLower(p.peek().text)
+		p.pos++
+		attrs, err := p.attrs()
+		if kind == "graph" {
+			p.graphAttrs(attrs, cluster)
+		}
+		return nil, err
+	}
+
+	// An operand: a node or a subgraph.
+	operand, isNode, err := p.operand(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if isNode && p.accept("=") {
+		// Graph attribute, like rankdir=LR. The operand was taken for a node.
+		value, err := p.id()
+		if err != nil {
+			return nil, err
+		}
+		p.forget(operand[0])
+		p.graphAttrs(map[string]string{operand[0].id: value}, cluster)
+		return nil, nil
+	}
+
+	nodes := operand
+	chain := [][]*dotNode{operand}
+	for p.is("->") || p.is("--") {
+		if p.is("->") != p.g.directed {
+			return nil, errors.New("edges must be -> in a digraph and -- in a graph")
+		}
+		p.pos++
+		next, _, err := p.operand(cluster)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, next)
+		nodes = append(nodes, next...)
+	}
+
+	attrs, err := p.attrs()
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 1 {
+		if label, ok := attrs["label"]; ok && isNode {
+			operand[0].label = label
+		}
+		return nodes, nil
+	}
+	label := cmp.Or(attrs["label"], attrs["xlabel"])
+	for i := 1; i < len(chain); i++ {
+		for _, from := range chain[i-1] {
+			for _, to := range chain[i] {
+				p.g.edges = append(p.g.edges, dotEdge{from: from, to: to, label: label})
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// operand parses a node id with an optional port, or a subgraph, and returns
+// the nodes and whether it was a node.
+func (p *dotParser) operand(cluster *dotCluster) ([]*dotNode, bool, error) {
+	if p.is("subgraph") || p.is("{") {
+		nodes, err := p.subgraph(cluster)
+		return nodes, false, err
+	}
+	id, err := p.id()
+	if err != nil {
+		return nil, false, err
+	}
+	// Ports aren't drawn.
+	for p.accept(":") {
+		if _, err := p.id(); err != nil {
+			return nil, false, err
+		}
+	}
+	return []*dotNode{p.g.node(id, cluster)}, true, nil
+}
+
+// subgraph parses: [subgraph [ID]] { stmt_list }
+func (p *dotParser) subgraph(cluster *dotCluster) ([]*dotNode, error) {
+	name := ""
+	if p.accept("subgraph") && !p.is("{") {
+		var err error
+		if name, err = p.id(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(name, "cluster") {
+		return p.stmts(cluster)
+	}
+
+	c := &dotCluster{label: cmp.Or(strings.TrimLeft(strings.TrimPrefix(name, "cluster"), "_ "), name)}
+	if cluster != nil {
+		cluster.children = append(cluster.children, c)
+	} else {
+		p.g.clusters = append(p.g.clusters, c)
+	}
+	return p.stmts(c)
+}
+
+// attrs parses any number of attribute lists: [ a=b, c=d; ... ]
+func (p *dotParser) attrs() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.accept("[") {
+		for !p.accept("]") {
+			key, err := p.id()
+			if err != nil {
+				return nil, err
+			}
+			value := "true"
+			if p.accept("=") {
+				if value, err = p.id(); err != nil {
+					return nil, err
+				}
+			}
+			attrs[strings.ToLower(key)] = value
+			if !p.accept(",") {
+				p.accept(";")
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// graphAttrs applies the attributes of a graph or cluster.
+func (p *dotParser) graphAttrs(attrs map[string]string, cluster *dotCluster) {
+	for key, value := range attrs {
+		switch {
+		case strings.EqualFold(key, "rankdir") && cluster == nil:
+			p.g.rankdir = value
+		case strings.EqualFold(key, "label") && cluster != nil && value != "":
+			cluster.label = value
+		}
+	}
+}
+
+// forget removes a node that was added by mistake.
+func (p *dotParser) forget(n *dotNode) {
+	if len(p.g.nodes) == 0 || p.g.nodes[len(p.g.nodes)-1] != n {
+		// The node appeared before.
+		return
+	}
+	p.g.nodes = p.g.nodes[:len(p.g.nodes)-1]
+	delete(p.g.byID, n.id)
+	if c := n.cluster; c != nil {
+		c.nodes = c.nodes[:len(c.nodes)-1]
+	}
+}
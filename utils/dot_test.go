@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDot(t *testing.T) {
+	src := `// Services
+digraph "web" {
+  rankdir=LR; node [shape=box]
+  subgraph cluster_web {
+    label = "Web tier"
+    lb [label="Load\nbalancer"]
+    app
+  }
+  lb -> { app api } [label="http://"]
+  /* storage */
+  api -> db:port
+}`
+	g, err := parseDot(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "graph LR\n" +
+		"subgraph Web tier\nn0[Load balancer]\nn1[app]\nend\n" +
+		"n2[api]\nn3[db]\n" +
+		"n0 -->|http://| n1\nn0 -->|http://| n2\nn2 --> n3"
+	if got := g.flowchart(); got != want {
+		t.Errorf("flowchart() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseDot_Errors(t *testing.T) {
+	for _, src := range []string{
+		"flowchart TD\nA --> B",
+		"digraph { a -> }",
+		"digraph { a -- b }",
+		`graph { a [label="b }`,
+	} {
+		if _, err := parseDot(src); err == nil {
+			t.Errorf("expected an error parsing %q", src)
+		}
+	}
+}
+
+func TestRenderDotBlocks(t *testing.T) {
+	input := "```dot\ngraph { a -- b }\n```"
+	if got := RenderDotBlocks(input, "raw", 80); got != input {
+		t.Errorf("raw mode should leave content unchanged, got:\n%s", got)
+	}
+
+	result := RenderDotBlocks(input, "ascii", 80)
+	if strings.Contains(result, "```dot") || !strings.Contains(result, "| a |") || !strings.Contains(result, "| b |") {
+		t.Errorf("expected an ASCII graph, got:\n%s", result)
+	}
+
+	result = RenderDotBlocks("```graphviz\ndigraph { a -> }\n```", "unicode", 80)
+	if !strings.Contains(result, "graphviz render error:") || !strings.Contains(result, "```graphviz") {
+		t.Errorf("expected an error and the original block, got:\n%s", result)
+	}
+}
+
+func TestRenderDotBlocks_Undirected(t *testing.T) {
+	// Links of undirected graphs have no heads, unlike labels that look like
+	// them.
+	want := "" +
+		"+-----+\n" +
+		"|     |\n" +
+		"| dev |\n" +
+		"|     |\n" +
+		"+-----+\n" +
+		"   |   \n" +
+		"   |   \n" +
+		"   v   \n" +
+		"   |   \n" +
+		"   |   \n" +
+		"+-----+\n" +
+		"|     |\n" +
+		"|  v  |\n" +
+		"|     |\n" +
+		"+-----+"
+	got, err := dotRenderer{ascii: true}.RenderBlock("graph { dev -- v [label=v] }", 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got = strings.Trim(got, "\n"); got != want {
+		t.Errorf("RenderBlock() =\n%s\nwant\n%s", got, want)
+	}
+
+	got, err = dotRenderer{}.RenderBlock("graph { rankdir=LR; a -- b; b -- c; a -- c }", 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.ContainsAny(got, "▲▼◄►") {
+		t.Errorf("RenderBlock() drew arrows in an undirected graph:\n%s", got)
+	}
+	got, err = dotRenderer{}.RenderBlock("digraph { rankdir=LR; a -> b }", 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "─►") {
+		t.Errorf("RenderBlock() didn't draw the arrow of a directed graph:\n%s", got)
+	}
+}
+
+func TestRenderDotBlocks_SelfLoops(t *testing.T) {
+	// Self-loops go around the right of the node, back into it.
+	for src, want := range map[string]string{
+		"digraph { a -> a }": "" +
+			"+---+  \n" +
+			"|   |  \n" +
+			"| a |<+\n" +
+			"|   | |\n" +
+			"+---+ |\n" +
+			"  |   |\n" +
+			"  +---+",
+		"graph { a -- a }": "" +
+			"+---+  \n" +
+			"|   |  \n" +
+			"| a |-+\n" +
+			"|   | |\n" +
+			"+---+ |\n" +
+			"  |   |\n" +
+			"  +---+",
+	} {
+		got, err := dotRenderer{ascii: true}.RenderBlock(src, 80)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got = strings.Trim(got, "\n"); got != want {
+			t.Errorf("RenderBlock(%q) =\n%s\nwant\n%s", src, got, want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// Frontmatter is the metadata header found at the top of a document.
+type Frontmatter struct {
+	Format string // "yaml", "toml" or "json"
+	Raw    []byte // header contents, without the delimiters
+}
+
+var (
+	yamlPattern = regexp.MustCompile(`(?m)^---\r?\n(\s*\r?\n)?`)
+	tomlPattern = regexp.MustCompile(`(?m)^\+\+\+\r?\n(\s*\r?\n)?`)
+)
+
+// ExtractFrontmatter splits the content into its front matter header and the
+// remaining body. YAML (---), TOML (+++) and JSON ({ ... }) headers are
+// recognised. If there is no header, fm is nil and body is the whole content.
+func ExtractFrontmatter(content []byte) (fm *Frontmatter, body []byte) {
+	for format, pattern := range map[string]*regexp.Regexp{"yaml": yamlPattern, "toml": tomlPattern} {
+		if matches := pattern.FindAllIndex(content, 2); len(matches) > 1 && matches[0][0] == 0 {
+			fm := &Frontmatter{Format: format, Raw: content[matches[0][1]:matches[1][0]]}
+			return fm, content[matches[1][1]:]
+		}
+	}
+
+	// JSON front matter opens with a lone brace on the first line.
+	if bytes.HasPrefix(content, []byte("{\n")) || bytes.HasPrefix(content, []byte("{\r\n")) {
+		dec := json.NewDecoder(bytes.NewReader(content))
+		var v map[string]any
+		if err := dec.Decode(&v); err == nil {
+			end := int(dec.InputOffset())
+			body := bytes.TrimLeft(content[end:], " \t")
+			body = bytes.TrimPrefix(bytes.TrimPrefix(body, []byte("\r")), []byte("\n"))
+			return &Frontmatter{Format: "json", Raw: content[:end]}, body
+		}
+	}
+
+	return nil, content
+}
+
+// Fields parses the front matter into a map of top-level keys.
+func (f *Frontmatter) Fields() (map[string]any, error) {
+	fields := map[string]any{}
+	var err error
+	switch f.Format {
+	case "yaml":
+		err = yaml.Unmarshal(f.Raw, &fields)
+	case "toml":
+		err = toml.Unmarshal(f.Raw, &fields)
+	case "json":
+		err = json.Unmarshal(f.Raw, &fields)
+	default:
+		return nil, fmt.Errorf("unknown front matter format: %s", f.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s front matter: %w", f.Format, err)
+	}
+	return fields, nil
+}
+
+// RenderFrontmatter processes the front matter header of a markdown document.
+// Mode "hide" removes it, "raw" shows it as a code block and "table" renders
+// its fields as a table above the document. If the header can't be parsed in
+// table mode it's shown raw instead.
+func RenderFrontmatter(content []byte, mode string) []byte {
+	fm, body := ExtractFrontmatter(content)
+	if fm == nil {
+		return content
+	}
+
+	switch strings.ToLower(mode) {
+	case "raw":
+		return append(fm.codeBlock(), body...)
+	case "table":
+		fields, err := fm.Fields()
+		if err != nil || len(fields) == 0 {
+			return append(fm.codeBlock(), body...)
+		}
+		return append([]byte(FrontmatterTable(fields)), body...)
+	default:
+		return body
+	}
+}
+
+func (f *Frontmatter) codeBlock() []byte {
+	raw := strings.TrimRight(strings.ReplaceAll(string(f.Raw), "\r\n", "\n"), "\n")
+	return []byte(WrapCodeBlock(raw+"\n", f.Format) + "\n\n")
+}
+
+// Keys shown first, in this order, when rendering front matter as a table.
+var frontmatterKeyOrder = []string{
+	"title", "description", "author", "authors", "date", "lastmod", "updated", "tags", "categories",
+}
+
+// FrontmatterTable renders front matter fields as a markdown table. Well-known
+// keys such as title, author, date and tags come first; the remaining keys
+// follow in alphabetical order.
+func FrontmatterTable(fields map[string]any) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	rank := func(k string) int {
+		for i, v := range frontmatterKeyOrder {
+			if strings.EqualFold(k, v) {
+				return i
+			}
+		}
+		return len(frontmatterKeyOrder)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, rj := rank(keys[i]), rank(keys[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+
+	var b strings.Builder
+	b.WriteString("| Field | Value |\n| --- | --- |\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "| **%s** | %s |\n", escapeTableCell(k), escapeTableCell(frontmatterValue(fields[k])))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func frontmatterValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []any:
+		s := make([]string, 0, len(v))
+		for _, e := range v {
+			s = append(s, frontmatterValue(e))
+		}
+		return strings.Join(s, ", ")
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	case map[string]any:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func escapeTableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
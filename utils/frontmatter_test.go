@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractFrontmatter(t *testing.T) {
+	tt := []struct {
+		name   string
+		input  string
+		format string
+		body   string
+	}{
+		{
+			name:   "yaml",
+			input:  "---\ntitle: Hello\n---\n# Doc",
+			format: "yaml",
+			body:   "# Doc",
+		},
+		{
+			name:   "toml",
+			input:  "+++\ntitle = \"Hello\"\n+++\n# Doc",
+			format: "toml",
+			body:   "# Doc",
+		},
+		{
+			name:   "json",
+			input:  "{\n  \"title\": \"Hello\"\n}\n# Doc",
+			format: "json",
+			body:   "# Doc",
+		},
+		{
+			name:  "none",
+			input: "# Doc\n\n---\n\nText\n\n---\n",
+			body:  "# Doc\n\n---\n\nText\n\n---\n",
+		},
+		{
+			name:  "unterminated",
+			input: "---\ntitle: Hello\n# Doc",
+			body:  "---\ntitle: Hello\n# Doc",
+		},
+	}
+
+	for _, v := range tt {
+		t.Run(v.name, func(t *testing.T) {
+			fm, body := ExtractFrontmatter([]byte(v.input))
+			if string(body) != v.body {
+				t.Errorf("unexpected body\ngot: %q\nwant: %q", body, v.body)
+			}
+			if v.format == "" {
+				if fm != nil {
+					t.Errorf("expected no front matter, got %s", fm.Format)
+				}
+				return
+			}
+			if fm == nil {
+				t.Fatal("expected front matter, got nil")
+			}
+			if fm.Format != v.format {
+				t.Errorf("unexpected format: got %s, want %s", fm.Format, v.format)
+			}
+			fields, err := fm.Fields()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fields["title"] != "Hello" {
+				t.Errorf("unexpected title: %v", fields["title"])
+			}
+		})
+	}
+}
+
+func TestRenderFrontmatter_Hide(t *testing.T) {
+	result := string(RenderFrontmatter([]byte("---\ntitle: Hello\n---\n# Doc"), "hide"))
+	if result != "# Doc" {
+		t.Errorf("hide mode should strip front matter, got %q", result)
+	}
+}
+
+func TestRenderFrontmatter_Raw(t *testing.T) {
+	result := string(RenderFrontmatter([]byte("---\ntitle: Hello\n---\n# Doc"), "raw"))
+	if !strings.HasPrefix(result, "```yaml\ntitle: Hello\n```") {
+		t.Errorf("raw mode should show front matter as a code block, got %q", result)
+	}
+	if !strings.HasSuffix(result, "# Doc") {
+		t.Errorf("raw mode should keep the body, got %q", result)
+	}
+}
+
+func TestRenderFrontmatter_Table(t *testing.T) {
+	input := "---\ntags: [a, b]\nzzz: last\ntitle: Hello\ndate: 2024-01-02\nauthor: Jane | Doe\n---\n# Doc"
+	result := string(RenderFrontmatter([]byte(input), "table"))
+
+	for _, want := range []string{"| **title** | Hello |", "| **tags** | a, b |", "| **date** | 2024-01-02 |", `Jane \| Doe`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("table should contain %q, got:\n%s", want, result)
+		}
+	}
+	if strings.Index(result, "title") > strings.Index(result, "zzz") {
+		t.Error("well-known keys should come before other keys")
+	}
+	if !strings.HasSuffix(result, "# Doc") {
+		t.Errorf("table mode should keep the body, got %q", result)
+	}
+}
+
+func TestRenderFrontmatter_TableParseErrorFallsBackToRaw(t *testing.T) {
+	input := "---\ntitle: [unclosed\n---\n# Doc"
+	result := string(RenderFrontmatter([]byte(input), "table"))
+	if !strings.Contains(result, "title: [unclosed") {
+		t.Errorf("unparsable front matter should be shown raw, got %q", result)
+	}
+}
@@ -0,0 +1,376 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// Link text is surrounded by markers made of zero-width characters, which
+// survive rendering without affecting word wrapping. An opening marker holds
+// the link's index in binary.
+const (
+	markerEdge = "\u200b" // zero width space
+	markerZero = "\u200c" // zero width non-joiner
+	markerOne  = "\u180e" // Mongolian vowel separator
+	markerEnd  = markerEdge + markerEdge
+)
+
+// HyperlinksSupported reports whether the terminal is known to support OSC 8
+// hyperlinks, judging by its environment.
+func HyperlinksSupported() bool {
+	term := os.Getenv("TERM")
+	if os.Getenv("TMUX") != "" || strings.HasPrefix(term, "screen") || term == "dumb" {
+		return false
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper", "Tabby", "rio":
+		return true
+	}
+	if v, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	for _, env := range []string{"KITTY_WINDOW_ID", "WT_SESSION", "KONSOLE_VERSION", "DOMTERM"} {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	for _, prefix := range []string{"xterm-kitty", "xterm-ghostty", "foot", "alacritty", "contour", "wezterm"} {
+		if strings.HasPrefix(term, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Links holds the links found in a markdown document.
+type Links []link
+
+type link struct {
+	target string // absolute URL the link points to
+	href   string // destination as displayed by glamour, if any
+}
+
+// MarkLinks marks the text of links and autolinks in markdown content, so
+// Links.Render can turn them into hyperlinks once rendered. Relative
+// destinations are resolved against base, which is either an http(s) URL or a
+// local directory; local paths become file:// URLs. baseURL is the base URL
+// given to glamour, which it uses when displaying destinations.
+func MarkLinks(content, base, baseURL string) (string, Links) {
+	if !strings.Contains(content, "](") && !strings.Contains(content, "://") && !strings.Contains(content, "@") {
+		return content, nil
+	}
+
+	source := []byte(content)
+	doc := goldmark.New(goldmark.WithExtensions(extension.GFM, extension.DefinitionList)).
+		Parser().Parse(text.NewReader(source))
+
+	type insertion struct {
+		pos    int
+		marker string
+	}
+	var (
+		links      Links
+		insertions []insertion
+	)
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		var start, stop int
+		var l link
+		switch n := n.(type) {
+		case *ast.Link:
+			var ok bool
+			if start, stop, ok = textSpan(n); !ok {
+				return ast.WalkSkipChildren, nil
+			}
+			dest := string(n.Destination)
+			l = link{target: linkTarget(dest, base)}
+			if u, err := url.Parse(dest); err == nil && "#"+u.Fragment != dest {
+				l.href = displayedHref(baseURL, dest)
+			}
+		case *ast.AutoLink:
+			dest := string(n.URL(source))
+			start, stop = autoLinkSpan(n, source)
+			if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(dest), "mailto:") {
+				dest = "mailto:" + dest
+				l.href = dest
+			}
+			l.target = linkTarget(dest, base)
+		default:
+			return ast.WalkContinue, nil
+		}
+		if l.target == "" {
+			return ast.WalkSkipChildren, nil
+		}
+
+		insertions = append(insertions,
+			insertion{start, linkMarker(len(links))},
+			insertion{stop, markerEnd})
+		links = append(links, l)
+		return ast.WalkSkipChildren, nil
+	})
+
+	if len(links) == 0 {
+		return content, nil
+	}
+
+	// Insert markers from the end, so positions stay valid.
+	slices.SortStableFunc(insertions, func(a, b insertion) int { return b.pos - a.pos })
+	for _, ins := range insertions {
+		source = slices.Insert(source, ins.pos, []byte(ins.marker)...)
+	}
+	return string(source), links
+}
+
+// textSpan returns the range of source text making up a link's text. Links
+// without text of their own, such as linked images, are skipped.
+func textSpan(n ast.Node) (int, int, bool) {
+	start, stop := -1, -1
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := c.(type) {
+		case *ast.Image:
+			start = -1
+			return ast.WalkStop, nil
+		case *ast.Text:
+			if start == -1 || c.Segment.Start < start {
+				start = c.Segment.Start
+			}
+			stop = max(stop, c.Segment.Stop)
+		}
+		return ast.WalkContinue, nil
+	})
+	return start, stop, start >= 0 && stop > start
+}
+
+// autoLinkSpan returns the range of source text making up an autolink,
+// including any angle brackets.
+func autoLinkSpan(n *ast.AutoLink, source []byte) (int, int) {
+	label := n.Label(source)
+	// The label is a slice of source; find where it starts.
+	start := cap(source) - cap(label)
+	stop := start + len(label)
+	if start > 0 && source[start-1] == '<' && stop < len(source) && source[stop] == '>' {
+		start, stop = start-1, stop+1
+	}
+	return start, stop
+}
+
+func linkMarker(n int) string {
+	var sb strings.Builder
+	sb.WriteString(markerEdge)
+	for _, b := range strconv.FormatInt(int64(n), 2) {
+		if b == '1' {
+			sb.WriteString(markerOne)
+		} else {
+			sb.WriteString(markerZero)
+		}
+	}
+	sb.WriteString(markerEdge)
+	return sb.String()
+}
+
+// linkTarget returns the absolute URL of a link destination. Anchors within
+// the document itself have no target.
+func linkTarget(dest, base string) string {
+	u, err := url.Parse(dest)
+	if err != nil || strings.HasPrefix(dest, "#") || dest == "" {
+		return ""
+	}
+	if u.Scheme != "" && !filepath.IsAbs(dest) {
+		return u.String()
+	}
+	if isHTTP(base) {
+		b, err := url.Parse(base)
+		if err != nil {
+			return ""
+		}
+		return b.ResolveReference(u).String()
+	}
+
+	path := u.Path
+	if filepath.IsAbs(dest) {
+		path = dest
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs // Windows drive letters
+	}
+	return (&url.URL{Scheme: "file", Path: abs, Fragment: u.Fragment}).String()
+}
+
+// displayedHref returns a link destination the way glamour displays it.
+func displayedHref(baseURL, dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.IsAbs() {
+		return dest
+	}
+	u.Path = strings.TrimPrefix(u.Path, "/")
+	b, err := url.Parse(baseURL)
+	if err != nil {
+		return dest
+	}
+	return b.ResolveReference(u).String()
+}
+
+// Render turns the marked link text in glamour's output into OSC 8
+// hyperlinks, along with the destinations glamour displays after it. Each
+// line is self-contained: hyperlinks spanning lines are closed at the end of
+// a line and reopened on the next, so lines can be cut without leaving a
+// hyperlink open. Indentation and padding are left out of hyperlinks.
+func (l Links) Render(out string) string {
+	if len(l) == 0 {
+		return out
+	}
+
+	lines := strings.Split(out, "\n")
+	open, pending := -1, -1
+	for i, line := range lines {
+		end := textEnd(line)
+		active := false // whether a hyperlink is open on this line
+		var sb strings.Builder
+		for j := 0; j < len(line); {
+			if active && j >= end {
+				sb.WriteString(hyperlinkClose)
+				active = false
+			}
+			switch {
+			case line[j] == '\x1b':
+				n := escapeLen(line[j:])
+				sb.WriteString(line[j : j+n])
+				j += n
+			case strings.HasPrefix(line[j:], markerEnd):
+				if active {
+					sb.WriteString(hyperlinkClose)
+					active = false
+				}
+				if open >= 0 {
+					pending = open
+				}
+				open = -1
+				j += len(markerEnd)
+			case strings.HasPrefix(line[j:], markerEdge):
+				n, size := parseLinkMarker(line[j:])
+				if n >= 0 && n < len(l) {
+					open, pending = n, -1
+				}
+				j += size
+			case pending >= 0 && l[pending].href != "" && strings.HasPrefix(line[j:], l[pending].href):
+				sb.WriteString(l.open(pending) + l[pending].href + hyperlinkClose)
+				j += len(l[pending].href)
+				pending = -1
+			default:
+				r, size := utf8.DecodeRuneInString(line[j:])
+				if r != ' ' {
+					pending = -1
+					if open >= 0 && !active {
+						sb.WriteString(l.open(open))
+						active = true
+					}
+				}
+				sb.WriteString(line[j : j+size])
+				j += size
+			}
+		}
+		if active {
+			sb.WriteString(hyperlinkClose)
+		}
+		lines[i] = sb.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textEnd returns the offset just past the last visible character of a
+// line, ignoring escape sequences, link markers and trailing spaces.
+func textEnd(line string) int {
+	end := 0
+	for j := 0; j < len(line); {
+		if line[j] == '\x1b' {
+			j += escapeLen(line[j:])
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[j:])
+		j += size
+		if r != ' ' && r != '\u200b' && r != '\u200c' && r != '\u180e' {
+			end = j
+		}
+	}
+	return end
+}
+
+const hyperlinkClose = "\x1b]8;;\x1b\\"
+
+func (l Links) open(n int) string {
+	// The id lets terminals treat a hyperlink spanning lines as one.
+	return fmt.Sprintf("\x1b]8;id=glow-%d;%s\x1b\\", n, l[n].target)
+}
+
+// parseLinkMarker parses an opening marker, returning the link index and the
+// size of the marker.
+func parseLinkMarker(s string) (int, int) {
+	i := len(markerEdge)
+	n := 0
+	for {
+		switch {
+		case strings.HasPrefix(s[i:], markerZero):
+			n *= 2
+			i += len(markerZero)
+		case strings.HasPrefix(s[i:], markerOne):
+			n = n*2 + 1
+			i += len(markerOne)
+		case strings.HasPrefix(s[i:], markerEdge):
+			return n, i + len(markerEdge)
+		default:
+			return -1, i
+		}
+	}
+}
+
+// escapeLen returns the length of the escape sequence at the start of s.
+func escapeLen(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']', '_', 'P', '^':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+	default:
+		return 2
+	}
+	return len(s)
+}
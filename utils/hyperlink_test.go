@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/glamour"
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+func renderLinks(t *testing.T, md, base, baseURL string, width int) string {
+	t.Helper()
+	md, links := MarkLinks(md, base, baseURL)
+	r, err := glamour.NewTermRenderer(
+		glamour.WithStandardStyle("notty"),
+		glamour.WithWordWrap(width),
+		glamour.WithBaseURL(baseURL),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Render(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return links.Render(out)
+}
+
+func TestMarkLinks(t *testing.T) {
+	out := renderLinks(t, "See [the docs](guide.md#setup), <https://charm.sh> and [top](#top).\n\n`[code](x)`", "/docs", "", 80)
+
+	for _, want := range []string{
+		"\x1b]8;id=glow-0;file:///docs/guide.md#setup\x1b\\the docs\x1b]8;;\x1b\\",
+		"\x1b]8;id=glow-0;file:///docs/guide.md#setup\x1b\\/guide.md#setup\x1b]8;;\x1b\\",
+		"\x1b]8;id=glow-1;https://charm.sh\x1b\\https://charm.sh\x1b]8;;\x1b\\",
+		"[code](x)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%q", want, out)
+		}
+	}
+	if strings.Contains(out, "top\x1b]8") {
+		t.Error("anchors within the document should not be linked")
+	}
+	if strings.ContainsAny(out, markerEdge+markerZero+markerOne) {
+		t.Error("markers should be removed from the output")
+	}
+}
+
+func TestMarkLinks_Remote(t *testing.T) {
+	base := "https://example.com/docs/"
+	out := renderLinks(t, "[Guide](guide.md) and [Home](/)", base, base, 80)
+	for _, want := range []string{
+		"\x1b]8;id=glow-0;https://example.com/docs/guide.md\x1b\\Guide",
+		"\x1b]8;id=glow-1;https://example.com/\x1b\\Home",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%q", want, out)
+		}
+	}
+}
+
+func TestLinksRender_Wrapped(t *testing.T) {
+	out := renderLinks(t, "Some text [with a long link text that wraps](https://charm.sh)", "", "", 30)
+
+	for i, line := range strings.Split(out, "\n") {
+		if strings.Count(line, "\x1b]8;id=") != strings.Count(line, "\x1b]8;;\x1b\\") {
+			t.Errorf("line %d has unbalanced hyperlinks: %q", i, line)
+		}
+		if strings.Contains(line, " \x1b]8;;\x1b\\") {
+			t.Errorf("line %d includes padding in a hyperlink: %q", i, line)
+		}
+	}
+	if n := strings.Count(out, "\x1b]8;id=glow-0;"); n < 3 {
+		t.Errorf("expected the link to be reopened on each line, got %d", n)
+	}
+
+	// Cutting a line keeps the closing sequence.
+	for _, line := range strings.Split(out, "\n") {
+		cut := xansi.Truncate(line, 10, "")
+		if strings.Count(cut, "\x1b]8;id=") != strings.Count(cut, "\x1b]8;;\x1b\\") {
+			t.Errorf("truncated line has unbalanced hyperlinks: %q", cut)
+		}
+	}
+}
+
+func TestHyperlinksSupported(t *testing.T) {
+	for _, k := range []string{"TMUX", "TERM_PROGRAM", "VTE_VERSION", "KITTY_WINDOW_ID", "WT_SESSION", "KONSOLE_VERSION", "DOMTERM"} {
+		t.Setenv(k, "")
+	}
+
+	t.Setenv("TERM", "xterm-256color")
+	if HyperlinksSupported() {
+		t.Error("plain xterm should not be assumed to support hyperlinks")
+	}
+	t.Setenv("VTE_VERSION", "6003")
+	if !HyperlinksSupported() {
+		t.Error("VTE terminals support hyperlinks")
+	}
+	t.Setenv("TMUX", "/tmp/tmux")
+	if HyperlinksSupported() {
+		t.Error("tmux should not be assumed to pass hyperlinks through")
+	}
+}
@@ -0,0 +1,624 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"  // register decoder
+	_ "image/jpeg" // register decoder
+	"image/png"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Ways of displaying images in the terminal.
+const (
+	ImagesNone      = "none"
+	ImagesHalfblock = "halfblock"
+	ImagesKitty     = "kitty"
+	ImagesITerm2    = "iterm2"
+	ImagesSixel     = "sixel"
+)
+
+const (
+	// imagePlaceholder is substituted for images before rendering.
+	imagePlaceholder = placeholderStart + "image"
+
+	maxImageSize = 20 << 20
+	// Images are decoded only when their bitmap, 4 bytes a pixel, stays
+	// under 256 MiB, as a small file can declare huge dimensions.
+	maxImagePixels = 64 << 20
+
+	// Remote images are downloaded a few at a time, and given up on quickly,
+	// as the document waits for them.
+	imageHTTPTimeout  = 3 * time.Second
+	maxImageDownloads = 8
+
+	// Cell size assumed when the terminal doesn't report it.
+	defaultCellWidth  = 8
+	defaultCellHeight = 16
+
+	// Image width used when rendering without a width limit.
+	defaultImageCols = 80
+)
+
+// imagePattern matches a line holding nothing but an image, optionally
+// wrapped in a link: ![alt](src "title") or [![alt](src)](href).
+var imagePattern = regexp.MustCompile(`^(\s*)\[?!\[[^\]]*\]\(\s*<?([^\s>)]+)>?(?:\s+"[^"]*")?\s*\)(?:\]\([^)]*\))?\s*$`)
+
+// DetectImageProtocol guesses how images can be displayed in the current
+// terminal by looking at its environment.
+func DetectImageProtocol() string {
+	term := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("TMUX") != "" || strings.HasPrefix(term, "screen") || strings.HasPrefix(term, "tmux"):
+		// Multiplexers don't pass graphics through by default.
+		return ImagesHalfblock
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty" || program == "ghostty":
+		return ImagesKitty
+	case program == "iTerm.app" || program == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return ImagesITerm2
+	case strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm") || strings.HasPrefix(term, "contour"):
+		return ImagesSixel
+	}
+	return ImagesHalfblock
+}
+
+// Images holds the images extracted from a markdown document.
+type Images struct {
+	protocol string
+	images   []*loadedImage
+}
+
+type loadedImage struct {
+	img image.Image
+	id  uint32 // kitty image id
+}
+
+// ExtractImages finds images that stand on a line of their own in markdown
+// content and replaces them with placeholders, which Images.Render later swaps
+// for the images themselves. Relative sources are resolved against base, which
+// is either an http(s) URL or a local directory. Images that can't be loaded
+// or decoded are left for glamour to render as text.
+func ExtractImages(content, base, protocol string) (string, Images) {
+	images := Images{protocol: protocol}
+	if protocol == "" || protocol == ImagesNone || !strings.Contains(content, "![") {
+		return content, images
+	}
+
+	lines := strings.Split(content, "\n")
+	var fenceChar rune
+	var fenceLen int
+
+	// The lines holding images, and the images they hold.
+	type found struct {
+		indent string
+		img    *loadedImage
+	}
+	matches := map[int]*found{}
+	locations := map[string][]*found{}
+
+	for i, line := range lines {
+		_, char, length, info := parseFenceLine(strings.TrimSuffix(line, "\r"))
+		if fenceLen > 0 {
+			if char == fenceChar && length >= fenceLen && info == "" {
+				fenceChar, fenceLen = 0, 0
+			}
+			continue
+		}
+		if length >= 3 {
+			fenceChar, fenceLen = char, length
+			continue
+		}
+
+		if m := imagePattern.FindStringSubmatch(strings.TrimSuffix(line, "\r")); m != nil {
+			f := &found{indent: m[1]}
+			matches[i] = f
+			location := resolveImageSource(m[2], base)
+			locations[location] = append(locations[location], f)
+		}
+	}
+	if len(matches) == 0 {
+		return content, images
+	}
+
+	// Load the images concurrently, each once.
+	sem := make(chan struct{}, maxImageDownloads)
+	var wg sync.WaitGroup
+	for location, fs := range locations {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			img, err := loadImage(location)
+			if err != nil {
+				return
+			}
+			for _, f := range fs {
+				f.img = img
+			}
+		}()
+	}
+	wg.Wait()
+
+	var result []string
+	for i, line := range lines {
+		f := matches[i]
+		if f == nil || f.img == nil {
+			result = append(result, line)
+			continue
+		}
+		// Images get a paragraph of their own, even if they were part of
+		// a larger one.
+		result = append(result, "", f.indent+placeholderFor(imagePlaceholder, len(images.images)), "")
+		images.images = append(images.images, f.img)
+	}
+
+	if len(images.images) == 0 {
+		return content, images
+	}
+	return strings.Join(result, "\n"), images
+}
+
+// resolveImageSource turns an image source into a URL or a local path.
+func resolveImageSource(src, base string) string {
+	if isHTTP(src) || isDataURI(src) {
+		return src
+	}
+	if isHTTP(base) {
+		b, err := url.Parse(base)
+		if err != nil {
+			return src
+		}
+		ref, err := url.Parse(src)
+		if err != nil {
+			return src
+		}
+		return b.ResolveReference(ref).String()
+	}
+
+	path := src
+	if !filepath.IsAbs(path) {
+		// Strip query strings and fragments, and undo escaping.
+		if u, err := url.Parse(src); err == nil && (u.Scheme == "" || u.Scheme == "file") {
+			path = u.Path
+		}
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(base, path)
+	}
+	return path
+}
+
+func isHTTP(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+func isDataURI(s string) bool {
+	return strings.HasPrefix(s, "data:")
+}
+
+var (
+	imageCache   sync.Map // location -> *loadedImage
+	imageIDs     atomic.Uint32
+	imageIDsOnce sync.Once
+)
+
+// loadImage reads and decodes an image from a URL, data URI or local path.
+// Images are cached for the lifetime of the process; local files are reloaded
+// when they change.
+func loadImage(location string) (*loadedImage, error) {
+	key := location
+	if !isHTTP(location) && !isDataURI(location) {
+		st, err := os.Stat(location)
+		if err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+		if st.Size() > maxImageSize {
+			return nil, errors.New("image too large")
+		}
+		key = fmt.Sprintf("%s@%d", location, st.ModTime().UnixNano())
+	}
+	if img, ok := imageCache.Load(key); ok {
+		return img.(*loadedImage), nil //nolint:forcetypeassert
+	}
+
+	r, err := openImage(location)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() //nolint:errcheck
+
+	b, err := io.ReadAll(io.LimitReader(r, maxImageSize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read image: %w", err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %w", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+		return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %w", err)
+	}
+
+	// Kitty image ids are 24 bit; start at a random one so that several
+	// instances don't overwrite each other's images.
+	imageIDsOnce.Do(func() {
+		imageIDs.Store(rand.Uint32N(1 << 23)) //nolint:gosec
+	})
+	loaded := &loadedImage{img: img, id: imageIDs.Add(1) & 0xFFFFFF}
+	imageCache.Store(key, loaded)
+	return loaded, nil
+}
+
+var errUnsupportedImage = errors.New("unsupported image format")
+
+func openImage(location string) (io.ReadCloser, error) {
+	if isDataURI(location) {
+		// Only base64 encoded data URIs are supported: data:image/png;base64,...
+		meta, data, ok := strings.Cut(strings.TrimPrefix(location, "data:"), ",")
+		if !ok || !strings.HasSuffix(meta, ";base64") {
+			return nil, errors.New("unsupported data URI")
+		}
+		return io.NopCloser(base64.NewDecoder(base64.StdEncoding, strings.NewReader(data))), nil
+	}
+	if !isHTTP(location) {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open image: %w", err)
+		}
+		return f, nil
+	}
+
+	// SVG images, like most badges, can't be decoded, so they aren't
+	// downloaded.
+	if u, err := url.Parse(location); err == nil && strings.EqualFold(path.Ext(u.Path), ".svg") {
+		return nil, errUnsupportedImage
+	}
+	client := http.Client{Timeout: imageHTTPTimeout}
+	resp, err := client.Get(location) //nolint:noctx
+	if err != nil {
+		return nil, fmt.Errorf("unable to get image: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("HTTP status %d", resp.StatusCode)
+	}
+	if t, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); t == "image/svg+xml" {
+		_ = resp.Body.Close()
+		return nil, errUnsupportedImage
+	}
+	return resp.Body, nil
+}
+
+// Render replaces the image placeholders in glamour's output with the images,
+// scaled to fit within width. Each image takes up as many lines as it is tall,
+// so the output can be scrolled like text.
+func (imgs Images) Render(out string, width int) string {
+	if len(imgs.images) == 0 {
+		return out
+	}
+	if width <= 0 {
+		width = defaultImageCols
+	}
+	// Leave a right margin matching glamour's document margin.
+	return replacePlaceholders(out, imagePlaceholder, max(0, width-2), func(n, width int) (string, bool) {
+		if n >= len(imgs.images) {
+			return "", false
+		}
+		return imgs.images[n].render(imgs.protocol, width), true
+	})
+}
+
+func (l *loadedImage) render(protocol string, width int) string {
+	cw, ch := terminalCellSize()
+	if cw <= 0 || ch <= 0 {
+		cw, ch = defaultCellWidth, defaultCellHeight
+	}
+	cols, rows := imageCells(l.img.Bounds().Size(), width, cw, ch)
+
+	switch protocol {
+	case ImagesKitty:
+		return l.kitty(cols, rows, cw, ch)
+	case ImagesITerm2:
+		return reserveImageRows(iterm2Image(fitImage(l.img, cols*cw, rows*ch), cols, rows), rows)
+	case ImagesSixel:
+		return reserveImageRows(sixelImage(fitImage(l.img, cols*cw, rows*ch)), rows)
+	default:
+		return halfblockImage(scaleImage(l.img, cols, rows*2))
+	}
+}
+
+// imageCells returns the number of terminal cells an image of the given size
+// takes up when scaled to fit width. Images are never scaled up.
+func imageCells(size image.Point, width, cw, ch int) (int, int) {
+	if size.X <= 0 || size.Y <= 0 {
+		return 1, 1
+	}
+	cols := max(1, min(width, (size.X+cw-1)/cw))
+	rows := max(1, (size.Y*cols*cw+size.X*ch/2)/(size.X*ch))
+	return cols, rows
+}
+
+// fitImage scales img down to fit within w×h pixels, keeping its aspect
+// ratio.
+func fitImage(img image.Image, w, h int) *image.RGBA {
+	sw, sh := img.Bounds().Dx(), img.Bounds().Dy()
+	if sw*h > sh*w {
+		h = max(1, sh*w/sw)
+	} else {
+		w = max(1, sw*h/sh)
+	}
+	return scaleImage(img, min(w, sw), min(h, sh))
+}
+
+// scaleImage scales img to w×h pixels. Each output pixel is the average of
+// the pixels it covers.
+func scaleImage(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	if w == sw && h == sh {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := range w {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := range sum {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := dst.PixOffset(x, y)
+			for c := range sum {
+				dst.Pix[i+c] = uint8(sum[c] / n) //nolint:gosec
+			}
+		}
+	}
+	return dst
+}
+
+// halfblockImage draws an image with colored half blocks, two pixels per
+// cell. Mostly transparent pixels are left blank.
+func halfblockImage(img *image.RGBA) string {
+	profile := lipgloss.ColorProfile()
+	seq := func(c color.RGBA, bg bool) string {
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA) //nolint:forcetypeassert
+		hex := fmt.Sprintf("#%02x%02x%02x", nc.R, nc.G, nc.B)
+		if s := profile.Color(hex).Sequence(bg); s != "" {
+			return termenv.CSI + s + "m"
+		}
+		return ""
+	}
+
+	b := img.Bounds()
+	var sb strings.Builder
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		if y > b.Min.Y {
+			sb.WriteByte('\n')
+		}
+		for x := b.Min.X; x < b.Max.X; x++ {
+			top := img.RGBAAt(x, y)
+			bottom := color.RGBA{}
+			if y+1 < b.Max.Y {
+				bottom = img.RGBAAt(x, y+1)
+			}
+			switch {
+			case top.A >= 128 && bottom.A >= 128:
+				sb.WriteString(seq(top, false) + seq(bottom, true) + "▀")
+			case top.A >= 128:
+				sb.WriteString(seq(top, false) + "▀")
+			case bottom.A >= 128:
+				sb.WriteString(seq(bottom, false) + "▄")
+			default:
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(termenv.CSI + termenv.ResetSeq + "m")
+		}
+	}
+	return sb.String()
+}
+
+// kittyPlaceholder is the character that kitty replaces with image cells. The
+// row of each cell is given by a combining diacritic.
+const kittyPlaceholder = '\U0010EEEE'
+
+var kittyDiacritics = []rune{
+	0x0305, 0x030D, 0x030E, 0x0310, 0x0312, 0x033D, 0x033E, 0x033F, 0x0346, 0x034A,
+	0x034B, 0x034C, 0x0350, 0x0351, 0x0352, 0x0357, 0x035B, 0x0363, 0x0364, 0x0365,
+	0x0366, 0x0367, 0x0368, 0x0369, 0x036A, 0x036B, 0x036C, 0x036D, 0x036E, 0x036F,
+	0x0483, 0x0484, 0x0485, 0x0486, 0x0487, 0x0592, 0x0593, 0x0594, 0x0595, 0x0597,
+	0x0598, 0x0599, 0x059C, 0x059D, 0x059E, 0x059F, 0x05A0, 0x05A1, 0x05A8, 0x05A9,
+	0x05AB, 0x05AC, 0x05AF, 0x05C4, 0x0610, 0x0611, 0x0612, 0x0613, 0x0614, 0x0615,
+	0x0616, 0x0617, 0x0657, 0x0658, 0x0659, 0x065A, 0x065B, 0x065D, 0x065E, 0x06D6,
+	0x06D7, 0x06D8, 0x06D9, 0x06DA, 0x06DB, 0x06DC, 0x06DF, 0x06E0, 0x06E1, 0x06E2,
+	0x06E4, 0x06E7, 0x06E8, 0x06EB, 0x06EC, 0x0730, 0x0732, 0x0733, 0x0735, 0x0736,
+	0x073A, 0x073D, 0x073F, 0x0740, 0x0741, 0x0743, 0x0745, 0x0747, 0x0749, 0x074A,
+}
+
+// kitty displays an image with the kitty graphics protocol. The image is
+// transmitted with a virtual placement and drawn with placeholder characters,
+// so it scrolls and clears like text.
+func (l *loadedImage) kitty(cols, rows, cw, ch int) string {
+	if rows > len(kittyDiacritics) {
+		cols = max(1, cols*len(kittyDiacritics)/rows)
+		rows = len(kittyDiacritics)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, fitImage(l.img, cols*cw, rows*ch)); err != nil {
+		return ""
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	var sb strings.Builder
+	const chunkSize = 4096
+	for i := 0; i < len(data); i += chunkSize {
+		more := 0
+		if i+chunkSize < len(data) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(&sb, "\x1b_Ga=T,U=1,f=100,t=d,q=2,i=%d,c=%d,r=%d,m=%d;", l.id, cols, rows, more)
+		} else {
+			fmt.Fprintf(&sb, "\x1b_Gm=%d;", more)
+		}
+		sb.WriteString(data[i:min(len(data), i+chunkSize)])
+		sb.WriteString("\x1b\\")
+	}
+
+	// The foreground color identifies the image.
+	fg := fmt.Sprintf("\x1b[38;2;%d;%d;%dm", l.id>>16&0xFF, l.id>>8&0xFF, l.id&0xFF)
+	rest := strings.Repeat(string(kittyPlaceholder), cols-1)
+	for r := range rows {
+		if r > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(fg + string(kittyPlaceholder) + string(kittyDiacritics[r]) + string(kittyDiacritics[0]) + rest + "\x1b[39m")
+	}
+	return sb.String()
+}
+
+// iterm2Image displays an image with iTerm2's inline image protocol, sized
+// in cells.
+func iterm2Image(img image.Image, cols, rows int) string {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return ""
+	}
+	return fmt.Sprintf("\x1b]1337;File=inline=1;size=%d;width=%d;height=%d;preserveAspectRatio=1:%s\a",
+		buf.Len(), cols, rows, base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+// reserveImageRows returns blank lines for an image that's drawn over them
+// by an escape sequence. The sequence goes on an extra line below the image,
+// so that redrawing the lines above doesn't erase it, and the cursor is moved
+// back once it is drawn.
+func reserveImageRows(seq string, rows int) string {
+	if seq == "" {
+		return ""
+	}
+	return strings.Repeat("\n", rows) + fmt.Sprintf("\x1b7\x1b[%dA%s\x1b8", rows, seq)
+}
+
+// sixelImage encodes an image as sixels, reducing it to a 256 color palette.
+// Mostly transparent pixels are left transparent.
+func sixelImage(img *image.RGBA) string {
+	b := img.Bounds()
+	pal := image.NewPaletted(b, sixelPalette)
+	draw.FloydSteinberg.Draw(pal, b, img, b.Min)
+
+	var sb strings.Builder
+	sb.WriteString("\x1bP0;1;0q")
+	fmt.Fprintf(&sb, "\"1;1;%d;%d", b.Dx(), b.Dy())
+
+	defined := make([]bool, len(sixelPalette))
+	opaque := func(x, y int) bool { return img.Pix[img.PixOffset(x, y)+3] >= 128 }
+
+	for y0 := b.Min.Y; y0 < b.Max.Y; y0 += 6 {
+		// Colors used in this band, in order of appearance.
+		var colors []uint8
+		used := make([]bool, len(sixelPalette))
+		for y := y0; y < min(y0+6, b.Max.Y); y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if c := pal.ColorIndexAt(x, y); opaque(x, y) && !used[c] {
+					used[c] = true
+					colors = append(colors, c)
+				}
+			}
+		}
+
+		for i, c := range colors {
+			if !defined[c] {
+				r, g, bl, _ := sixelPalette[c].RGBA()
+				fmt.Fprintf(&sb, "#%d;2;%d;%d;%d", c, r*100/0xFFFF, g*100/0xFFFF, bl*100/0xFFFF)
+				defined[c] = true
+			}
+			fmt.Fprintf(&sb, "#%d", c)
+
+			var run int
+			var last byte
+			flush := func() {
+				switch {
+				case run > 3:
+					fmt.Fprintf(&sb, "!%d%c", run, last)
+				case run > 0:
+					sb.WriteString(strings.Repeat(string(last), run))
+				}
+			}
+			for x := b.Min.X; x < b.Max.X; x++ {
+				var bits byte
+				for dy := range 6 {
+					y := y0 + dy
+					if y < b.Max.Y && opaque(x, y) && pal.ColorIndexAt(x, y) == c {
+						bits |= 1 << dy
+					}
+				}
+				ch := 63 + bits
+				if ch == last {
+					run++
+					continue
+				}
+				flush()
+				last, run = ch, 1
+			}
+			flush()
+			if i < len(colors)-1 {
+				sb.WriteByte('$')
+			}
+		}
+		sb.WriteByte('-')
+	}
+
+	sb.WriteString("\x1b\\")
+	return sb.String()
+}
+
+// sixelPalette is a 6×7×6 color cube plus a ramp of grays.
+var sixelPalette = func() color.Palette {
+	var p color.Palette
+	for r := range 6 {
+		for g := range 7 {
+			for b := range 6 {
+				p = append(p, color.RGBA{uint8(r * 51), uint8(g * 255 / 6), uint8(b * 51), 0xFF}) //nolint:gosec
+			}
+		}
+	}
+	for _, v := range []uint8{32, 96, 160, 224} {
+		p = append(p, color.RGBA{v, v, v, 0xFF})
+	}
+	return p
+}()
@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.NRGBA{uint8(x), uint8(y), 128, 255}) //nolint:gosec
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractImages(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shot.png"), testPNG(t, 160, 64), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	input := strings.Join([]string{
+		"Intro",
+		"![shot](shot.png)",
+		"[![linked](./shot.png \"Title\")](https://example.com)",
+		"![missing](missing.png)",
+		"Text with ![inline](shot.png) image",
+		"```",
+		"![code](shot.png)",
+		"```",
+	}, "\n")
+
+	result, images := ExtractImages(input, dir, ImagesHalfblock)
+	if len(images.images) != 2 {
+		t.Fatalf("expected 2 images, got %d", len(images.images))
+	}
+	for _, want := range []string{
+		"Intro\n\n" + placeholderFor(imagePlaceholder, 0) + "\n\n",
+		"\n\n" + placeholderFor(imagePlaceholder, 1) + "\n\n",
+		"![missing](missing.png)",
+		"Text with ![inline](shot.png) image",
+		"```\n![code](shot.png)\n```",
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q in result:\n%s", want, result)
+		}
+	}
+
+	if result, _ := ExtractImages(input, dir, ImagesNone); result != input {
+		t.Error("content should be unchanged when images are disabled")
+	}
+}
+
+func TestLoadImage_TooLarge(t *testing.T) {
+	// A small PNG that declares a huge bitmap isn't decoded.
+	b := testPNG(t, 1, 1)
+	ihdr := b[12:29] // the type and data of the header chunk
+	binary.BigEndian.PutUint32(ihdr[4:], 100_000)
+	binary.BigEndian.PutUint32(ihdr[8:], 100_000)
+	binary.BigEndian.PutUint32(b[29:], crc32.ChecksumIEEE(ihdr))
+	path := filepath.Join(t.TempDir(), "huge.png")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadImage(path); err == nil || err.Error() != "image too large: 100000x100000" {
+		t.Errorf("loadImage() error = %v, want image too large", err)
+	}
+}
+
+func TestExtractImages_Remote(t *testing.T) {
+	data := testPNG(t, 16, 16)
+	var requested string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	_, images := ExtractImages("![logo](img/logo.png)", srv.URL+"/docs/", ImagesHalfblock)
+	if len(images.images) != 1 {
+		t.Fatalf("expected 1 image, got %d", len(images.images))
+	}
+	if requested != "/docs/img/logo.png" {
+		t.Errorf("expected relative source to be resolved against base, got %q", requested)
+	}
+}
+
+func TestExtractImages_RemoteConcurrent(t *testing.T) {
+	data := testPNG(t, 16, 16)
+	var mu sync.Mutex
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/badge") {
+			w.Header().Set("Content-Type", "image/svg+xml")
+			_, _ = w.Write([]byte("<svg/>"))
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	input := "![a](a.png)\n\n![b](b.png)\n\n![c](c.png)\n\n![a](a.png)\n\n![badge](badge)\n\n![logo](logo.svg)"
+	start := time.Now()
+	result, images := ExtractImages(input, srv.URL+"/", ImagesHalfblock)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("images were downloaded one at a time, in %s", elapsed)
+	}
+	if len(images.images) != 4 {
+		t.Errorf("expected 4 images, got %d", len(images.images))
+	}
+	for _, want := range []string{"![badge](badge)", "![logo](logo.svg)"} {
+		if !strings.Contains(result, want) {
+			t.Errorf("expected %q to be left as a link in:\n%s", want, result)
+		}
+	}
+
+	// Each image is downloaded once, and SVG files not at all.
+	slices.Sort(requested)
+	if want := []string{"/a.png", "/b.png", "/badge", "/c.png"}; !slices.Equal(requested, want) {
+		t.Errorf("requested %q, want %q", requested, want)
+	}
+}
+
+func TestResolveImageSource(t *testing.T) {
+	dir := filepath.Join(string(filepath.Separator), "docs")
+	tests := []struct {
+		src, base, want string
+	}{
+		{"img.png", dir, filepath.Join(dir, "img.png")},
+		{"../img/a%20b.png?raw=true", dir, filepath.Join(string(filepath.Separator), "img", "a b.png")},
+		{"https://example.com/a.png", dir, "https://example.com/a.png"},
+		{"a.png", "https://example.com/docs/", "https://example.com/docs/a.png"},
+		{"/a.png", "https://example.com/docs/", "https://example.com/a.png"},
+	}
+	for _, tt := range tests {
+		if got := resolveImageSource(tt.src, tt.base); got != tt.want {
+			t.Errorf("resolveImageSource(%q, %q) = %q, want %q", tt.src, tt.base, got, tt.want)
+		}
+	}
+}
+
+func renderTestImage(t *testing.T, protocol string, width int) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shot.png"), testPNG(t, 160, 64), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	md, images := ExtractImages("![shot](shot.png)", dir, protocol)
+	return images.Render(strings.TrimSpace(md), width)
+}
+
+func TestImagesRender(t *testing.T) {
+	// With the default 8×16 cell size, a 160×64 image takes up 20×4 cells.
+	out := renderTestImage(t, ImagesHalfblock, 80)
+	lines := strings.Split(out, "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d", len(lines))
+	}
+	for _, l := range lines {
+		if w := xansi.StringWidth(l); w != 20 {
+			t.Errorf("expected line width 20, got %d", w)
+		}
+	}
+
+	// Images are scaled down to fit.
+	out = renderTestImage(t, ImagesHalfblock, 12)
+	if w := xansi.StringWidth(strings.Split(out, "\n")[0]); w != 10 {
+		t.Errorf("expected scaled line width 10, got %d", w)
+	}
+
+	out = renderTestImage(t, ImagesKitty, 80)
+	if !strings.HasPrefix(out, "\x1b_Ga=T,U=1,") {
+		t.Errorf("expected kitty transmission, got %q", out[:min(len(out), 40)])
+	}
+	if n := strings.Count(out, string(kittyPlaceholder)); n != 80 {
+		t.Errorf("expected 80 placeholder cells, got %d", n)
+	}
+
+	for protocol, seq := range map[string]string{ImagesITerm2: "\x1b]1337;File=", ImagesSixel: "\x1bP0;1;0q"} {
+		out = renderTestImage(t, protocol, 80)
+		lines := strings.Split(out, "\n")
+		if len(lines) != 5 {
+			t.Errorf("%s: expected 4 reserved lines and the image, got %d lines", protocol, len(lines))
+		}
+		if !strings.Contains(lines[len(lines)-1], seq) {
+			t.Errorf("%s: expected %q on the last line", protocol, seq)
+		}
+	}
+}
+
+func TestDetectImageProtocol(t *testing.T) {
+	for _, k := range []string{"TMUX", "KITTY_WINDOW_ID", "TERM_PROGRAM", "LC_TERMINAL"} {
+		t.Setenv(k, "")
+	}
+
+	tests := []struct {
+		env  map[string]string
+		want string
+	}{
+		{map[string]string{"TERM": "xterm-kitty"}, ImagesKitty},
+		{map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "iTerm.app"}, ImagesITerm2},
+		{map[string]string{"TERM": "foot"}, ImagesSixel},
+		{map[string]string{"TERM": "xterm-kitty", "TMUX": "/tmp/tmux"}, ImagesHalfblock},
+		{map[string]string{"TERM": "xterm-256color"}, ImagesHalfblock},
+	}
+	for _, tt := range tests {
+		for k, v := range tt.env {
+			t.Setenv(k, v)
+		}
+		if got := DetectImageProtocol(); got != tt.want {
+			t.Errorf("DetectImageProtocol() with %v = %s, want %s", tt.env, got, tt.want)
+		}
+		for k := range tt.env {
+			t.Setenv(k, "")
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxIncludeDepth limits how deeply include directives are expanded.
+const maxIncludeDepth = 10
+
+var (
+	includePattern = regexp.MustCompile(`^\s{0,3}<!--\s*include:\s*(.+?)\s*-->\s*$`)
+	embedPattern   = regexp.MustCompile(`^\s{0,3}!\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|[^\]]*)?\]\]\s*$`)
+)
+
+// ExpandIncludes replaces include directives in markdown content with the
+// contents of the referenced files. Both HTML comment directives such as
+// <!-- include: ../shared/oncall.md --> and Obsidian-style ![[note]] embeds
+// are supported. Relative paths are resolved from the directory of the
+// including file; path may be empty for content that doesn't come from a
+// file, in which case the working directory is used.
+//
+// Includes are expanded recursively up to a fixed depth. Cycles, missing
+// files and excessive nesting are reported inline. The returned slice holds
+// the absolute paths of all files that were included.
+func ExpandIncludes(content, path string) (string, []string) {
+	e := includeExpander{seen: map[string]bool{}}
+	var stack []string
+	if path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+			stack = append(stack, abs)
+		}
+	}
+	return e.expand(content, path, stack), e.files
+}
+
+type includeExpander struct {
+	files []string
+	seen  map[string]bool
+}
+
+func (e *includeExpander) expand(content, path string, stack []string) string {
+	if !strings.Contains(content, "<!--") && !strings.Contains(content, "![[") {
+		return content
+	}
+
+	dir := "."
+	if path != "" {
+		dir = filepath.Dir(path)
+	}
+
+	lines := strings.Split(content, "\n")
+	var fenceChar rune
+	var fenceLen int
+
+	for i, line := range lines {
+		_, char, length, info := parseFenceLine(strings.TrimSuffix(line, "\r"))
+		if fenceLen == 0 && length >= 3 {
+			fenceChar, fenceLen = char, length
+			continue
+		}
+		if fenceLen > 0 {
+			if char == fenceChar && length >= fenceLen && info == "" {
+				fenceChar, fenceLen = 0, 0
+			}
+			continue
+		}
+
+		target, ok := includeTarget(line)
+		if !ok {
+			continue
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		lines[i] = e.include(target, stack)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// includeTarget returns the file referenced by an include directive or embed
+// on the given line.
+func includeTarget(line string) (string, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if m := includePattern.FindStringSubmatch(line); m != nil {
+		return ExpandPath(m[1]), true
+	}
+	if m := embedPattern.FindStringSubmatch(line); m != nil {
+		target := strings.TrimSpace(m[1])
+		if filepath.Ext(target) == "" {
+			target += ".md"
+		}
+		if !IsMarkdownFile(target) {
+			// Embedded images and other files are left alone.
+			return "", false
+		}
+		return target, true
+	}
+	return "", false
+}
+
+func (e *includeExpander) include(path string, stack []string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return includeError(err)
+	}
+
+	for _, p := range stack {
+		if p == abs {
+			return includeError(fmt.Errorf("include cycle detected: %s", path))
+		}
+	}
+	if len(stack) >= maxIncludeDepth {
+		return includeError(fmt.Errorf("include depth limit of %d exceeded: %s", maxIncludeDepth, path))
+	}
+
+	b, err := os.ReadFile(abs)
+	if err != nil {
+		return includeError(err)
+	}
+	if !e.seen[abs] {
+		e.seen[abs] = true
+		e.files = append(e.files, abs)
+	}
+
+	content := strings.TrimRight(string(RemoveFrontmatter(b)), "\r\n")
+	return e.expand(content, abs, append(stack, abs))
+}
+
+// includeError returns a visible error message in place of an include.
+func includeError(err error) string {
+	return "```\ninclude error: " + err.Error() + "\n```"
+}
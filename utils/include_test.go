@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestExpandIncludes_Recursive(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"handbook/index.md":  "# Handbook\n\n<!-- include: ../shared/oncall.md -->\n\nEnd",
+		"shared/oncall.md":   "---\ntitle: On-call\n---\n## On-call\n\n<!-- include: pager.md -->\n",
+		"shared/pager.md":    "Pager details",
+		"handbook/unused.md": "Unused",
+	})
+
+	index := filepath.Join(dir, "handbook/index.md")
+	content, _ := os.ReadFile(index)
+	result, files := ExpandIncludes(string(content), index)
+
+	want := "# Handbook\n\n## On-call\n\nPager details\n\nEnd"
+	if result != want {
+		t.Errorf("unexpected expansion\ngot: %q\nwant: %q", result, want)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 included files, got %v", files)
+	}
+	if files[0] != filepath.Join(dir, "shared/oncall.md") || files[1] != filepath.Join(dir, "shared/pager.md") {
+		t.Errorf("unexpected included files: %v", files)
+	}
+}
+
+func TestExpandIncludes_ObsidianEmbed(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"note.md": "Embedded note",
+	})
+
+	input := "Before\n![[note|Alias]]\n![[diagram.png]]\nAfter"
+	result, _ := ExpandIncludes(input, filepath.Join(dir, "index.md"))
+
+	if !strings.Contains(result, "Embedded note") {
+		t.Error("note embeds should be expanded")
+	}
+	if !strings.Contains(result, "![[diagram.png]]") {
+		t.Error("image embeds should be left unchanged")
+	}
+}
+
+func TestExpandIncludes_Cycle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.md": "A\n<!-- include: b.md -->",
+		"b.md": "B\n<!-- include: a.md -->",
+	})
+
+	a := filepath.Join(dir, "a.md")
+	content, _ := os.ReadFile(a)
+	result, _ := ExpandIncludes(string(content), a)
+
+	if !strings.Contains(result, "B") {
+		t.Error("first level include should be expanded")
+	}
+	if !strings.Contains(result, "include error: include cycle detected") {
+		t.Errorf("cycle should be reported, got %q", result)
+	}
+}
+
+func TestExpandIncludes_DepthLimit(t *testing.T) {
+	// A chain of files longer than the depth limit.
+	files := map[string]string{}
+	for i := 0; i <= maxIncludeDepth+1; i++ {
+		files[filepath.Join("chain", string(rune('a'+i))+".md")] = "<!-- include: " + string(rune('a'+i+1)) + ".md -->"
+	}
+	dir := writeFiles(t, files)
+
+	result, _ := ExpandIncludes("<!-- include: chain/a.md -->", filepath.Join(dir, "index.md"))
+	if !strings.Contains(result, "include depth limit") {
+		t.Errorf("depth limit should be reported, got %q", result)
+	}
+}
+
+func TestExpandIncludes_MissingFile(t *testing.T) {
+	result, files := ExpandIncludes("<!-- include: missing.md -->", filepath.Join(t.TempDir(), "index.md"))
+	if !strings.Contains(result, "include error:") {
+		t.Errorf("missing file should be reported, got %q", result)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no included files, got %v", files)
+	}
+}
+
+func TestExpandIncludes_IgnoresCodeBlocks(t *testing.T) {
+	input := "```markdown\n<!-- include: missing.md -->\n```"
+	result, _ := ExpandIncludes(input, filepath.Join(t.TempDir(), "index.md"))
+	if result != input {
+		t.Errorf("directives inside code blocks should be left unchanged, got %q", result)
+	}
+}
@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// minAnalyseWeight is the confidence a chroma lexer needs when guessing a
+// language from content alone. Lower weights are too often wrong.
+const minAnalyseWeight = 0.5
+
+// Interpreters whose names aren't chroma lexer names or aliases.
+var interpreterLanguages = map[string]string{
+	"node": "javascript", "nodejs": "javascript", "bun": "javascript",
+	"deno": "typescript", "ts-node": "typescript",
+	"dash": "bash", "ash": "bash",
+	"Rscript": "r", "pwsh": "powershell", "tclsh": "tcl", "wish": "tcl",
+	"gawk": "awk", "mawk": "awk", "nawk": "awk",
+	"osascript": "applescript", "runghc": "haskell", "runhaskell": "haskell",
+	"escript": "erlang",
+}
+
+var (
+	vimModeline   = regexp.MustCompile(`(?:^|\s)(?:vi|vim|ex):\s*(?:set?\s+)?.*?\b(?:ft|filetype|syntax)=([\w+-]+)`)
+	emacsModeline = regexp.MustCompile(`-\*-\s*(.+?)\s*-\*-`)
+)
+
+// Unambiguous openings of common languages, which chroma doesn't pick up.
+var languageSignatures = []struct {
+	pattern  *regexp.Regexp
+	language string
+}{
+	{regexp.MustCompile(`^<\?php`), "php"},
+	{regexp.MustCompile(`^<\?xml\s`), "xml"},
+	{regexp.MustCompile(`(?i)^\s*<(?:!doctype html|html)[\s>]`), "html"},
+	{regexp.MustCompile(`(?m)^diff --git |^--- \S.*\n\+\+\+ \S`), "diff"},
+	{regexp.MustCompile(`(?m)^package \w+\s*$[\s\S]*^(?:func|import|type|var|const)\b`), "go"},
+	{regexp.MustCompile(`(?m)^#include\s*[<"]`), "c"},
+	{regexp.MustCompile(`(?m)^\s*(?:pub\s+)?fn \w+.*\{|^use \w+(?:::\w+)+;`), "rust"},
+	{regexp.MustCompile(`(?m)^(?:def \w+\(.*\):|from [\w.]+ import \w|class \w+(?:\(.*\))?:)\s*$`), "python"},
+	{regexp.MustCompile(`(?m)^(?:import .+ from ['"]|export (?:default|const|function) |const \w+ = require\()`), "javascript"},
+	{regexp.MustCompile(`(?im)^(?:select\s.+\sfrom\s|insert into\s|create (?:table|index|view)\s|update \w+ set\s)`), "sql"},
+}
+
+// DetectLanguage returns the language of a source file for syntax
+// highlighting. overrides maps file extensions or names to languages and
+// takes precedence; otherwise the language is detected from the filename,
+// shebang, modeline and content. It returns "" if the language is unknown.
+func DetectLanguage(filename, content string, overrides map[string]string) string {
+	if lang := overrideLanguage(filename, overrides); lang != "" {
+		return lang
+	}
+	if filename != "" {
+		if l := lexers.Match(filepath.Base(filename)); l != nil {
+			return lexerName(l)
+		}
+	}
+	return detectContentLanguage(content)
+}
+
+// IsCodeFile returns whether a file should be displayed as source code rather
+// than rendered as markdown. Files without an extension are markdown, unless
+// they have a shebang or are named like a source file, such as Makefile.
+// Notebooks and other markup languages are converted to markdown, so they
+// aren't code either.
+func IsCodeFile(filename string, content []byte, overrides map[string]string) bool {
+	if IsNotebook(filename) || IsMarkupFile(filename) || IsTableFile(filename) {
+		return false
+	}
+	if !IsMarkdownFile(filename) {
+		return true
+	}
+	if filename == "" || filepath.Ext(filename) != "" {
+		return false
+	}
+	return strings.HasPrefix(string(content), "#!") ||
+		overrideLanguage(filename, overrides) != "" ||
+		lexers.Match(filepath.Base(filename)) != nil
+}
+
+func overrideLanguage(filename string, overrides map[string]string) string {
+	if filename == "" || len(overrides) == 0 {
+		return ""
+	}
+	base := strings.ToLower(filepath.Base(filename))
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(base)), ".")
+	for k, lang := range overrides {
+		k = strings.ToLower(k)
+		if k == base || (ext != "" && strings.TrimPrefix(k, ".") == ext) {
+			return lang
+		}
+	}
+	return ""
+}
+
+// detectContentLanguage guesses the language of source code from its
+// shebang, a vim or emacs modeline, or its content.
+func detectContentLanguage(content string) string {
+	content = strings.TrimLeft(content, "\r\n")
+	if content == "" {
+		return ""
+	}
+
+	lines := strings.Split(content, "\n")
+	if strings.HasPrefix(lines[0], "#!") {
+		if lang := shebangLanguage(lines[0]); lang != "" {
+			return lang
+		}
+	}
+
+	// Modelines are found within the first or last few lines.
+	for i, line := range lines {
+		if i >= 5 && i < len(lines)-5 {
+			continue
+		}
+		if lang := modelineLanguage(line); lang != "" {
+			return lang
+		}
+	}
+
+	trimmed := strings.TrimSpace(content)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+	for _, sig := range languageSignatures {
+		if sig.pattern.MatchString(content) {
+			return sig.language
+		}
+	}
+
+	var picked chroma.Lexer
+	highest := float32(minAnalyseWeight)
+	for _, l := range lexers.GlobalLexerRegistry.Lexers {
+		if a, ok := l.(chroma.Analyser); ok {
+			if w := a.AnalyseText(content); w >= highest {
+				picked, highest = l, w
+			}
+		}
+	}
+	if picked != nil {
+		return lexerName(picked)
+	}
+	return ""
+}
+
+// shebangLanguage returns the language of a script's interpreter.
+func shebangLanguage(line string) string {
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// Skip env's options and variable assignments.
+		interpreter = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interpreter = path.Base(f)
+				break
+			}
+		}
+	}
+
+	// Try python3.12, then python3, then python.
+	for _, name := range []string{interpreter, strings.TrimRight(interpreter, "0123456789."), strings.TrimRight(interpreter, "0123456789.-")} {
+		if name == "" {
+			continue
+		}
+		if lang, ok := interpreterLanguages[name]; ok {
+			return lang
+		}
+		if l := lexers.Get(name); l != nil {
+			return lexerName(l)
+		}
+	}
+	return ""
+}
+
+// modelineLanguage returns the filetype set by a vim or emacs modeline.
+func modelineLanguage(line string) string {
+	var name string
+	if m := vimModeline.FindStringSubmatch(line); m != nil {
+		name = m[1]
+	} else if m := emacsModeline.FindStringSubmatch(line); m != nil {
+		// Either -*- python -*- or -*- mode: python; coding: utf-8 -*-
+		name = m[1]
+		for _, v := range strings.Split(m[1], ";") {
+			if k, val, ok := strings.Cut(v, ":"); ok && strings.TrimSpace(strings.ToLower(k)) == "mode" {
+				name = strings.TrimSpace(val)
+			}
+		}
+		if strings.Contains(name, ":") {
+			return ""
+		}
+	}
+	if name == "" {
+		return ""
+	}
+	if l := lexers.Get(name); l != nil {
+		return lexerName(l)
+	}
+	return ""
+}
+
+// lexerName returns the name to use for a lexer in a fenced code block.
+func lexerName(l chroma.Lexer) string {
+	cfg := l.Config()
+	if len(cfg.Aliases) > 0 {
+		return cfg.Aliases[0]
+	}
+	return strings.ToLower(cfg.Name)
+}
+
+// DetectFenceLanguages labels fenced code blocks that have no info string
+// with the language detected from their content, so they get highlighted.
+func DetectFenceLanguages(content string) string {
+	if !strings.Contains(content, "```") && !strings.Contains(content, "~~~") {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		indent, char, length, info := parseFenceLine(strings.TrimSuffix(lines[i], "\r"))
+		if length < 3 {
+			continue
+		}
+
+		// Find the closing fence.
+		end := len(lines)
+		for j := i + 1; j < len(lines); j++ {
+			_, c, l, inf := parseFenceLine(strings.TrimSuffix(lines[j], "\r"))
+			if c == char && l >= length && inf == "" {
+				end = j
+				break
+			}
+		}
+
+		if info == "" {
+			body := make([]string, 0, end-i-1)
+			for _, l := range lines[i+1 : end] {
+				body = append(body, strings.TrimPrefix(l, indent))
+			}
+			block := strings.Join(body, "\n")
+			if lang := detectContentLanguage(block); lang != "" {
+				lines[i] = indent + strings.Repeat(string(char), length) + lang
+			}
+		}
+		i = end
+	}
+	return strings.Join(lines, "\n")
+}
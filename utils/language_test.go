@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name      string
+		filename  string
+		content   string
+		overrides map[string]string
+		want      string
+	}{
+		{"extension", "main.go", "", nil, "go"},
+		{"makefile", "Makefile", "all:\n\tgo build\n", nil, "make"},
+		{"dockerfile", "Dockerfile", "FROM alpine\n", nil, "docker"},
+		{"shebang", "bin/deploy", "#!/bin/bash\necho hi\n", nil, "bash"},
+		{"env shebang", "bin/run", "#!/usr/bin/env -S python3.12 -u\nprint(1)\n", nil, "python"},
+		{"interpreter", "bin/serve", "#!/usr/bin/env node\n", nil, "javascript"},
+		{"vim modeline", "notes", "SELECT 1;\n# vim: set ft=sql:\n", nil, "sql"},
+		{"emacs modeline", "notes", "# -*- mode: ruby; coding: utf-8 -*-\nputs 1\n", nil, "rb"},
+		{"content", "", "package main\n\nfunc main() {}\n", nil, "go"},
+		{"override extension", "page.tpl", "", map[string]string{".tpl": "html"}, "html"},
+		{"override filename", "Justfile", "", map[string]string{"justfile": "make"}, "make"},
+		{"unknown", "", "just some words", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectLanguage(tt.filename, tt.content, tt.overrides); got != tt.want {
+				t.Errorf("DetectLanguage(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsCodeFile(t *testing.T) {
+	tests := []struct {
+		filename string
+		content  string
+		want     bool
+	}{
+		{"README.md", "#!/bin/sh\n", false},
+		{"README", "# Title\n", false},
+		{"main.go", "", true},
+		{"Makefile", "all:\n", true},
+		{"bin/deploy", "#!/bin/sh\n", true},
+		{"", "#!/bin/sh\n", false},
+	}
+	for _, tt := range tests {
+		if got := IsCodeFile(tt.filename, []byte(tt.content), nil); got != tt.want {
+			t.Errorf("IsCodeFile(%q) = %v, want %v", tt.filename, got, tt.want)
+		}
+	}
+}
+
+func TestDetectFenceLanguages(t *testing.T) {
+	in := "```\npackage main\n\nfunc main() {}\n```\n\n```sh\nls\n```\n\n~~~\nplain text\n~~~\n"
+	out := DetectFenceLanguages(in)
+	if !strings.HasPrefix(out, "```go\n") {
+		t.Errorf("expected unlabelled go fence to be labelled, got:\n%s", out)
+	}
+	if !strings.Contains(out, "```sh\nls\n```") {
+		t.Errorf("expected labelled fence to be unchanged, got:\n%s", out)
+	}
+	if !strings.Contains(out, "~~~\nplain text\n~~~") {
+		t.Errorf("expected undetected fence to be unchanged, got:\n%s", out)
+	}
+}
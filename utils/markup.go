@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Converters of lightweight markup languages to markdown, by file extension.
+var markupConverters = map[string]func(string) string{
+	".rst":      rstToMarkdown,
+	".rest":     rstToMarkdown,
+	".adoc":     asciidocToMarkdown,
+	".asciidoc": asciidocToMarkdown,
+	".org":      orgToMarkdown,
+}
+
+// IsMarkupFile returns whether the filename is a reStructuredText, AsciiDoc
+// or Org document, which are converted to markdown before rendering.
+func IsMarkupFile(filename string) bool {
+	_, ok := markupConverters[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// MarkupToMarkdown converts a reStructuredText, AsciiDoc or Org document to
+// markdown, picking the format by the file extension. Only common subsets of
+// the formats are supported: headings, lists, code blocks, tables, links and
+// admonitions. Other content is returned as is.
+func MarkupToMarkdown(filename, content string) string {
+	convert, ok := markupConverters[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return content
+	}
+	return convert(strings.ReplaceAll(content, "\r\n", "\n"))
+}
+
+// pipeTable formats rows as a markdown table. The first row is the header.
+func pipeTable(rows [][]string, indent string) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+
+	format := func(row []string) string {
+		cells := make([]string, cols)
+		for i := range cells {
+			if i < len(row) {
+				cells[i] = strings.ReplaceAll(strings.TrimSpace(row[i]), "|", `\|`)
+			}
+		}
+		return indent + "| " + strings.Join(cells, " | ") + " |"
+	}
+	lines := []string{format(rows[0]), indent + "|" + strings.Repeat(" --- |", cols)}
+	for _, row := range rows[1:] {
+		lines = append(lines, format(row))
+	}
+	return lines
+}
+
+// calloutLines formats a GitHub alert, which ExtractCallouts renders as a
+// callout. Unknown kinds become notes.
+func calloutLines(kind, title string, body []string, indent string) []string {
+	kind, ok := calloutKindOf(kind)
+	if !ok {
+		kind = "note"
+	}
+	first := indent + "> [!" + strings.ToUpper(kind) + "]"
+	if title != "" {
+		first += " " + title
+	}
+	lines := []string{first}
+	for _, l := range trimBlankEdges(body) {
+		lines = append(lines, strings.TrimRight(indent+"> "+l, " "))
+	}
+	return lines
+}
+
+// codeLines formats a fenced code block.
+func codeLines(code []string, language, indent string) []string {
+	var lines []string
+	for _, l := range strings.Split(fenceCode(strings.Join(trimBlankEdges(code), "\n"), language), "\n") {
+		lines = append(lines, strings.TrimRight(indent+l, " "))
+	}
+	return lines
+}
+
+// trimBlankEdges removes leading and trailing blank lines.
+func trimBlankEdges(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// dedent removes the indentation that all non-blank lines have in common.
+func dedent(lines []string) []string {
+	common := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if n := leadingSpaces(l); common < 0 || n < common {
+			common = n
+		}
+	}
+	common = max(common, 0)
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		if len(l) >= common {
+			out[i] = l[common:]
+		} else {
+			out[i] = strings.TrimLeft(l, " ")
+		}
+	}
+	return out
+}
+
+func leadingSpaces(s string) int {
+	return len(s) - len(strings.TrimLeft(s, " "))
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkupToMarkdown_RST(t *testing.T) {
+	rst := "=======\nProject\n=======\n\n" +
+		"Uses ``code``, a `link <https://example.com>`_ and Python_.\n\n" +
+		".. _Python: https://python.org\n\n" +
+		"Install\n-------\n\n" +
+		"* one\n* two\n\n" +
+		"#. first\n\n" +
+		"Run::\n\n    make\n\n" +
+		".. code-block:: go\n   :linenos:\n\n   fmt.Println()\n\n" +
+		".. note:: Read this.\n\n" +
+		"+---+---+\n| a | b |\n+===+===+\n| 1 | 2 |\n+---+---+\n"
+	want := "# Project\n\n" +
+		"Uses `code`, a [link](https://example.com) and [Python](https://python.org).\n\n" +
+		"## Install\n\n" +
+		"- one\n- two\n\n" +
+		"1. first\n\n" +
+		"Run:\n\n```\nmake\n```\n\n" +
+		"```go\nfmt.Println()\n```\n\n" +
+		"> [!NOTE]\n> Read this.\n\n" +
+		"| a | b |\n| --- | --- |\n| 1 | 2 |\n"
+	if got := MarkupToMarkdown("README.rst", rst); got != want {
+		t.Errorf("MarkupToMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarkupToMarkdown_AsciiDoc(t *testing.T) {
+	adoc := "= Title\n:toc:\n\n" +
+		"Some *bold*, _italic_ and link:docs/guide.adoc[the guide].\n\n" +
+		"* one\n** nested\n. first\n\n" +
+		"[source,python]\n----\nprint(1)\n----\n\n" +
+		"TIP: Try it.\n\n" +
+		"|===\n|Name |Value\n\n|a |1\n|===\n"
+	want := "# Title\n\n" +
+		"Some **bold**, *italic* and [the guide](docs/guide.adoc).\n\n" +
+		"- one\n  - nested\n1. first\n\n" +
+		"```python\nprint(1)\n```\n\n" +
+		"> [!TIP]\n> Try it.\n\n" +
+		"| Name | Value |\n| --- | --- |\n| a | 1 |\n"
+	if got := MarkupToMarkdown("README.adoc", adoc); got != want {
+		t.Errorf("MarkupToMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarkupToMarkdown_Org(t *testing.T) {
+	org := "#+TITLE: Notes\n\n" +
+		"* Heading\n:PROPERTIES:\n:ID: 1\n:END:\n" +
+		"Some *bold*, /italic/, =code= and [[https://example.com][a link]].\n\n" +
+		"- item\n  1. nested\n\n" +
+		"#+BEGIN_SRC sh\nls\n#+END_SRC\n\n" +
+		"#+begin_warning\nCareful.\n#+end_warning\n\n" +
+		"| a | b |\n|---+---|\n| 1 | 2 |\n"
+	want := "# Notes\n\n" +
+		"# Heading\n" +
+		"Some **bold**, *italic*, `code` and [a link](https://example.com).\n\n" +
+		"- item\n  1. nested\n\n" +
+		"```sh\nls\n```\n\n" +
+		"> [!WARNING]\n> Careful.\n\n" +
+		"| a | b |\n| --- | --- |\n| 1 | 2 |\n"
+	if got := MarkupToMarkdown("notes.org", org); got != want {
+		t.Errorf("MarkupToMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarkupToMarkdown_Other(t *testing.T) {
+	md := "# Title\n\n*emphasis*\n"
+	if got := MarkupToMarkdown("README.md", md); got != md {
+		t.Errorf("expected markdown to be unchanged, got:\n%s", got)
+	}
+	if !IsMarkupFile("docs/INDEX.RST") || IsMarkupFile("README.md") {
+		t.Error("IsMarkupFile() should match by extension, ignoring case")
+	}
+	if got := MarkupToMarkdown("a.rst", "Title\n=====\n\nText\n"); !strings.HasPrefix(got, "# Title\n") {
+		t.Errorf("expected a heading, got:\n%s", got)
+	}
+}
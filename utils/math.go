@@ -0,0 +1,1209 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+// RenderMathBlocks processes markdown content and renders TeX math as
+// Unicode. Inline math ($...$) is rendered on a single line; display math
+// ($$...$$ and fenced math blocks) is laid out over multiple lines, with
+// stacked fractions, limits and matrices. Formulas using unsupported
+// constructs are left as they are.
+func RenderMathBlocks(content string) string {
+	if !strings.Contains(content, "$") && !strings.Contains(content, "math") {
+		return content
+	}
+
+	normalized := strings.ReplaceAll(content, "\r\n", "\n")
+	lines := strings.Split(normalized, "\n")
+	var result []string
+	var fenceChar rune
+	var fenceLen int
+	var containers containerScanner
+	// Whether the last line was paragraph text, which indented lines and
+	// some HTML continue, rather than starting code or HTML blocks.
+	paragraph := false
+	// Whether the line is in an indented code block or an HTML block, and
+	// the text the HTML block ends with, or "" for a blank line.
+	inCode, inHTML := false, false
+	var htmlEnd string
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		_, rest, _ := containers.scan(line, fenceLen > 0)
+		blank := strings.TrimSpace(rest) == ""
+		indent, char, length, info := parseFenceLine(line)
+
+		// Inside a regular fenced code block: copy lines verbatim.
+		if fenceLen > 0 {
+			if char == fenceChar && length >= fenceLen && info == "" {
+				fenceChar, fenceLen = 0, 0
+			}
+			result = append(result, line)
+			continue
+		}
+
+		if length >= 3 {
+			if fields := strings.Fields(info); len(fields) > 0 && strings.EqualFold(fields[0], "math") {
+				if end := closingFence(lines, i+1, char, length); end > 0 {
+					src := trimIndent(lines[i+1:end], indent)
+					if rendered, err := renderDisplayMath(strings.Join(src, "\n")); err == nil {
+						result = append(result, codeBlockLines(rendered, indent)...)
+						i = end
+						continue
+					}
+				}
+			}
+			fenceChar, fenceLen = char, length
+			result = append(result, line)
+			paragraph, inCode = false, false
+			continue
+		}
+
+		// Indented code and HTML blocks: copy lines verbatim.
+		if inHTML {
+			if htmlEnd == "" && blank || htmlEnd != "" && strings.Contains(strings.ToLower(line), htmlEnd) {
+				inHTML = false
+			}
+			result = append(result, line)
+			continue
+		}
+		if blank {
+			paragraph = false
+			result = append(result, line)
+			continue
+		}
+		if columns(rest) >= 4 && (!paragraph || inCode) {
+			inCode = true
+			result = append(result, line)
+			continue
+		}
+		inCode = false
+		if ok, end := htmlBlock(rest, paragraph); ok {
+			inHTML = end == "" || !strings.Contains(strings.ToLower(rest), end)
+			htmlEnd = end
+			paragraph = false
+			result = append(result, line)
+			continue
+		}
+
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "$$") {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			if end, src, ok := displayMath(lines, i); ok {
+				if rendered, err := renderDisplayMath(src); err == nil {
+					result = append(result, codeBlockLines(rendered, indent)...)
+					i = end
+					continue
+				}
+			}
+		}
+
+		paragraph = !strings.HasPrefix(strings.TrimSpace(rest), "#")
+		result = append(result, renderInlineMath(line))
+	}
+
+	// If there was no math, return the original content unchanged
+	if out := strings.Join(result, "\n"); out != normalized {
+		return out
+	}
+	return content
+}
+
+var (
+	htmlRawBlockPattern = regexp.MustCompile(`^ {0,3}<(?i:(script|pre|style|textarea))(?:[\s>]|$)`)
+	htmlBlockPattern    = regexp.MustCompile(`^ {0,3}</?(?i:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[1-6]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:[\s>]|/>|$)`)
+	htmlLoneTagPattern  = regexp.MustCompile(`^ {0,3}(?:<[A-Za-z][A-Za-z0-9-]*(?:\s[^<>]*)?/?>|</[A-Za-z][A-Za-z0-9-]*\s*>)\s*$`)
+)
+
+// htmlBlock returns whether a line starts an HTML block, after the rules of
+// CommonMark, and the text that ends it, lowercased, or "" if it ends at a
+// blank line. A lone tag can't start one after paragraph text.
+func htmlBlock(line string, paragraph bool) (bool, string) {
+	trimmed := strings.TrimLeft(line, " ")
+	switch {
+	case len(line)-len(trimmed) > 3 || !strings.HasPrefix(trimmed, "<"):
+		return false, ""
+	case htmlRawBlockPattern.MatchString(line):
+		return true, "</" + strings.ToLower(htmlRawBlockPattern.FindStringSubmatch(line)[1]) + ">"
+	case strings.HasPrefix(trimmed, "<!--"):
+		return true, "-->"
+	case strings.HasPrefix(trimmed, "<?"):
+		return true, "?>"
+	case strings.HasPrefix(trimmed, "<![CDATA["):
+		return true, "]]>"
+	case len(trimmed) > 2 && trimmed[1] == '!' && unicode.IsLetter(rune(trimmed[2])):
+		return true, ">"
+	case htmlBlockPattern.MatchString(line):
+		return true, ""
+	case !paragraph && htmlLoneTagPattern.MatchString(line):
+		return true, ""
+	}
+	return false, ""
+}
+
+// closingFence returns the index of the line closing a fence opened with the
+// given character and length, or -1.
+func closingFence(lines []string, from int, char rune, length int) int {
+	for j := from; j < len(lines); j++ {
+		_, c, l, info := parseFenceLine(lines[j])
+		if c == char && l >= length && info == "" {
+			return j
+		}
+	}
+	return -1
+}
+
+func trimIndent(lines []string, indent string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = strings.TrimPrefix(l, indent)
+	}
+	return out
+}
+
+// displayMath collects a $$...$$ formula starting at line i. It returns the
+// index of the closing line and the TeX source.
+func displayMath(lines []string, i int) (int, string, bool) {
+	first := strings.TrimPrefix(strings.TrimSpace(lines[i]), "$$")
+	if strings.HasSuffix(first, "$$") {
+		src := strings.TrimSuffix(first, "$$")
+		return i, src, strings.TrimSpace(src) != ""
+	}
+
+	src := []string{first}
+	for j := i + 1; j < len(lines); j++ {
+		l := strings.TrimSpace(lines[j])
+		if strings.HasSuffix(l, "$$") {
+			src = append(src, strings.TrimSuffix(l, "$$"))
+			return j, strings.Join(src, "\n"), true
+		}
+		if l == "" {
+			// Display math doesn't span paragraphs.
+			return 0, "", false
+		}
+		src = append(src, l)
+	}
+	return 0, "", false
+}
+
+func codeBlockLines(rendered []string, indent string) []string {
+	result := []string{indent + "```"}
+	for _, l := range rendered {
+		result = append(result, indent+l)
+	}
+	return append(result, indent+"```")
+}
+
+// renderInlineMath replaces $...$ spans in a line of text. Code spans and
+// escaped dollar signs are left alone, as are spans that look like currency
+// amounts, such as "$5 and $10".
+func renderInlineMath(line string) string {
+	if !strings.Contains(line, "$") {
+		return line
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line):
+			b.WriteString(line[i : i+2])
+			i++
+			continue
+		case c == '`':
+			// Copy code spans verbatim.
+			n := 1
+			for i+n < len(line) && line[i+n] == '`' {
+				n++
+			}
+			ticks := line[i : i+n]
+			if end := strings.Index(line[i+n:], ticks); end >= 0 {
+				b.WriteString(line[i : i+n+end+n])
+				i += n + end + n - 1
+				continue
+			}
+			b.WriteString(ticks)
+			i += n - 1
+			continue
+		case c != '$':
+			b.WriteByte(c)
+			continue
+		}
+
+		end := inlineMathEnd(line, i)
+		if end < 0 {
+			b.WriteByte(c)
+			continue
+		}
+		rendered, err := renderTeX(line[i+1:end], false)
+		if err != nil || len(rendered.lines) != 1 {
+			b.WriteString(line[i : end+1])
+		} else {
+			b.WriteString(escapeMarkdown(rendered.lines[0]))
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// inlineMathEnd returns the index of the dollar sign closing an inline
+// formula opened at i, or -1.
+func inlineMathEnd(line string, i int) int {
+	if i+1 >= len(line) || line[i+1] == '$' || line[i+1] == ' ' || line[i+1] == '\t' {
+		return -1
+	}
+	for j := i + 1; j < len(line); j++ {
+		switch line[j] {
+		case '\\':
+			j++
+		case '$':
+			if line[j-1] == ' ' || line[j-1] == '\t' {
+				return -1
+			}
+			if j+1 < len(line) && line[j+1] >= '0' && line[j+1] <= '9' {
+				return -1
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+func escapeMarkdown(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// renderDisplayMath renders display math, which may consist of several
+// lines separated by \\.
+func renderDisplayMath(src string) ([]string, error) {
+	b, err := renderTeX(src, true)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, len(b.lines))
+	for i, l := range b.lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return lines, nil
+}
+
+// errUnsupportedMath is returned for TeX we can't render.
+var errUnsupportedMath = errors.New("unsupported math")
+
+func renderTeX(src string, display bool) (box, error) {
+	p := &mathParser{toks: tokenizeTeX(src), display: display}
+	var rows []box
+	for {
+		items, stop, err := p.parseSeq("\\\\", "")
+		if err != nil {
+			return box{}, err
+		}
+		if !display && stop == "\\\\" {
+			items = append(items, mathItem{textBox(" "), kindSpace})
+		}
+		rows = append(rows, joinItems(items))
+		if stop == "" {
+			break
+		}
+		p.pos++
+	}
+	if len(rows) == 1 {
+		return rows[0], nil
+	}
+	if !display {
+		return hcat(rows...), nil
+	}
+	return vstack(rows, 0, false), nil
+}
+
+// BOXES
+
+// box is a block of text lines with a baseline, used to lay out formulas.
+type box struct {
+	lines []string
+	base  int // index of the baseline row; may lie outside lines
+}
+
+func textBox(s string) box {
+	return box{lines: []string{s}}
+}
+
+func (b box) width() int {
+	w := 0
+	for _, l := range b.lines {
+		w = max(w, runewidth.StringWidth(l))
+	}
+	return w
+}
+
+func (b box) height() int {
+	return len(b.lines)
+}
+
+func (b box) isLine() bool {
+	return len(b.lines) == 1 && b.base == 0
+}
+
+func pad(s string, w int) string {
+	return s + strings.Repeat(" ", max(0, w-runewidth.StringWidth(s)))
+}
+
+func center(s string, w int) string {
+	left := (w - runewidth.StringWidth(s)) / 2
+	return pad(strings.Repeat(" ", max(0, left))+s, w)
+}
+
+// hcat joins boxes horizontally, aligning their baselines.
+func hcat(boxes ...box) box {
+	above, below := 0, 0
+	for _, b := range boxes {
+		above = max(above, b.base)
+		below = max(below, b.height()-1-b.base)
+	}
+	lines := make([]string, above+below+1)
+	for _, b := range boxes {
+		w := b.width()
+		for r := range lines {
+			i := r - above + b.base
+			if i >= 0 && i < b.height() {
+				lines[r] += pad(b.lines[i], w)
+			} else {
+				lines[r] += strings.Repeat(" ", w)
+			}
+		}
+	}
+	return trimBox(box{lines: lines, base: above})
+}
+
+// trimBox removes blank rows at the top and bottom of a box.
+func trimBox(b box) box {
+	for len(b.lines) > 1 && strings.TrimSpace(b.lines[0]) == "" && b.base > 0 {
+		b.lines = b.lines[1:]
+		b.base--
+	}
+	for len(b.lines) > 1 && strings.TrimSpace(b.lines[len(b.lines)-1]) == "" && b.base < len(b.lines)-1 {
+		b.lines = b.lines[:len(b.lines)-1]
+	}
+	return b
+}
+
+// vstack stacks boxes vertically, either centered or left-aligned, with the
+// baseline on the given row.
+func vstack(boxes []box, base int, centered bool) box {
+	w := 0
+	for _, b := range boxes {
+		w = max(w, b.width())
+	}
+	var lines []string
+	for _, b := range boxes {
+		for _, l := range b.lines {
+			if centered {
+				lines = append(lines, center(l, w))
+			} else {
+				lines = append(lines, pad(l, w))
+			}
+		}
+	}
+	return box{lines: lines, base: base}
+}
+
+// shift moves a box vertically so that its row at index row lies on
+// the given row relative to the surrounding baseline (negative is up).
+func shift(b box, index, row int) box {
+	b.base = index - row
+	return b
+}
+
+// delimiter builds a delimiter of the given height.
+func delimiter(d string, h int) box {
+	if d == "." || d == "" {
+		return box{lines: make([]string, h), base: h / 2}
+	}
+	if h <= 1 {
+		return textBox(d)
+	}
+
+	pieces := map[string][3]string{
+		"(": {"⎛", "⎜", "⎝"},
+		")": {"⎞", "⎟", "⎠"},
+		"[": {"⎡", "⎢", "⎣"},
+		"]": {"⎤", "⎥", "⎦"},
+		"{": {"⎧", "⎪", "⎩"},
+		"}": {"⎫", "⎪", "⎭"},
+		"⌈": {"⎡", "⎢", "⎢"},
+		"⌉": {"⎤", "⎥", "⎥"},
+		"⌊": {"⎢", "⎢", "⎣"},
+		"⌋": {"⎥", "⎥", "⎦"},
+	}
+	lines := make([]string, h)
+	p, ok := pieces[d]
+	if !ok {
+		// Vertical bars and other delimiters are repeated.
+		for i := range lines {
+			lines[i] = d
+		}
+		if d == "⟨" || d == "⟩" {
+			for i := range lines {
+				lines[i] = " "
+			}
+			lines[h/2] = d
+		}
+		return box{lines: lines, base: h / 2}
+	}
+	for i := range lines {
+		lines[i] = p[1]
+	}
+	lines[0], lines[h-1] = p[0], p[2]
+	if (d == "{" || d == "}") && h >= 3 {
+		lines[h/2] = map[string]string{"{": "⎨", "}": "⎬"}[d]
+	}
+	return box{lines: lines, base: h / 2}
+}
+
+// PARSER
+
+type atomKind int
+
+const (
+	kindOrd atomKind = iota
+	kindBin
+	kindRel
+	kindOpen
+	kindClose
+	kindPunct
+	kindOp
+	kindFunc
+	kindSpace
+)
+
+type mathItem struct {
+	b    box
+	kind atomKind
+}
+
+type mathToken struct {
+	s     string
+	space bool // preceded by whitespace
+}
+
+type mathParser struct {
+	toks    []mathToken
+	pos     int
+	display bool
+}
+
+func tokenizeTeX(src string) []mathToken {
+	var toks []mathToken
+	rs := []rune(src)
+	space := false
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case r == '\\' && i+1 < len(rs) && unicode.IsLetter(rs[i+1]):
+			j := i + 1
+			for j < len(rs) && unicode.IsLetter(rs[j]) {
+				j++
+			}
+			toks = append(toks, mathToken{string(rs[i:j]), space})
+			i = j - 1
+		case r == '\\' && i+1 < len(rs):
+			toks = append(toks, mathToken{string(rs[i : i+2]), space})
+			i++
+		default:
+			toks = append(toks, mathToken{string(r), space})
+		}
+		space = false
+	}
+	return toks
+}
+
+func (p *mathParser) peek() string {
+	if p.pos >= len(p.toks) {
+		return ""
+	}
+	return p.toks[p.pos].s
+}
+
+func (p *mathParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *mathParser) expect(s string) error {
+	if t := p.next(); t != s {
+		return fmt.Errorf("%w: expected %q, got %q", errUnsupportedMath, s, t)
+	}
+	return nil
+}
+
+// parseSeq parses items until one of the stop tokens; "" stops at the end of
+// the input. The stop token is not consumed.
+func (p *mathParser) parseSeq(stops ...string) ([]mathItem, string, error) {
+	var items []mathItem
+	for {
+		t := p.peek()
+		for _, s := range stops {
+			if t == s {
+				return items, t, nil
+			}
+		}
+		if t == "" {
+			return nil, "", fmt.Errorf("%w: unexpected end of formula", errUnsupportedMath)
+		}
+		item, err := p.parseScripted()
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, item...)
+	}
+}
+
+// parseGroup parses a {...} group, or a single atom.
+func (p *mathParser) parseGroup() (box, error) {
+	if p.peek() == "{" {
+		p.pos++
+		items, _, err := p.parseSeq("}")
+		if err != nil {
+			return box{}, err
+		}
+		p.pos++
+		return joinItems(items), nil
+	}
+	items, err := p.parseAtom()
+	if err != nil {
+		return box{}, err
+	}
+	return joinItems(items), nil
+}
+
+// parseRawGroup returns the text of a {...} group as written.
+func (p *mathParser) parseRawGroup() (string, error) {
+	if err := p.expect("{"); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	depth := 0
+	for {
+		if p.pos >= len(p.toks) {
+			return "", fmt.Errorf("%w: unclosed group", errUnsupportedMath)
+		}
+		t := p.toks[p.pos]
+		p.pos++
+		switch t.s {
+		case "{":
+			depth++
+		case "}":
+			if depth == 0 {
+				return b.String(), nil
+			}
+			depth--
+		}
+		if t.space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		if s, ok := mathSymbols[t.s]; ok && strings.HasPrefix(t.s, "\\") {
+			b.WriteString(s.s)
+		} else {
+			b.WriteString(strings.TrimPrefix(t.s, "\\"))
+		}
+	}
+}
+
+// parseScripted parses an atom followed by any sub- and superscripts.
+func (p *mathParser) parseScripted() ([]mathItem, error) {
+	items, err := p.parseAtom()
+	if err != nil || len(items) == 0 {
+		return items, err
+	}
+	last := &items[len(items)-1]
+
+	var sup, sub *box
+	for {
+		switch p.peek() {
+		case "^", "_":
+			op := p.next()
+			g, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			if op == "^" {
+				if sup != nil {
+					return nil, fmt.Errorf("%w: double superscript", errUnsupportedMath)
+				}
+				sup = &g
+			} else {
+				if sub != nil {
+					return nil, fmt.Errorf("%w: double subscript", errUnsupportedMath)
+				}
+				sub = &g
+			}
+			continue
+		case "'":
+			p.pos++
+			last.b = hcat(last.b, textBox("′"))
+			continue
+		case "\\limits", "\\nolimits":
+			p.pos++
+			continue
+		}
+		break
+	}
+	if sup == nil && sub == nil {
+		return items, nil
+	}
+
+	if last.kind == kindOp && p.display && limitOps[strings.TrimSpace(last.b.lines[last.b.base])] {
+		last.b = p.limits(last.b, sup, sub)
+	} else {
+		last.b = p.scripts(last.b, sup, sub)
+	}
+	return items, nil
+}
+
+// limits places limits above and below a big operator.
+func (p *mathParser) limits(op box, sup, sub *box) box {
+	rows := []box{}
+	base := op.base
+	if sup != nil {
+		rows = append(rows, *sup)
+		base += sup.height()
+	}
+	rows = append(rows, op)
+	if sub != nil {
+		rows = append(rows, *sub)
+	}
+	return vstack(rows, base, true)
+}
+
+// scripts attaches sub- and superscripts to a base box.
+func (p *mathParser) scripts(base box, sup, sub *box) box {
+	// Use Unicode super- and subscript characters where we can.
+	supText, supOK := "", true
+	subText, subOK := "", true
+	if sup != nil {
+		supText, supOK = convertScript(*sup, superscripts)
+	}
+	if sub != nil {
+		subText, subOK = convertScript(*sub, subscripts)
+	}
+	if supOK && subOK {
+		return hcat(base, textBox(subText+supText))
+	}
+
+	if !p.display {
+		s := ""
+		if sub != nil {
+			s += scriptText("_", *sub, subText, subOK)
+		}
+		if sup != nil {
+			s += scriptText("^", *sup, supText, supOK)
+		}
+		return hcat(base, textBox(s))
+	}
+
+	top := -base.base
+	bottom := base.height() - 1 - base.base
+	var parts []box
+	if sup != nil {
+		parts = append(parts, shift(*sup, sup.height()-1, min(-1, top)))
+	}
+	if sub != nil {
+		parts = append(parts, shift(*sub, 0, max(1, bottom)))
+	}
+	return hcat(base, column(parts...))
+}
+
+func scriptText(op string, b box, text string, ok bool) string {
+	if ok {
+		return text
+	}
+	s := strings.Join(b.lines, " ")
+	if runewidth.StringWidth(s) == 1 {
+		return op + s
+	}
+	return op + "(" + s + ")"
+}
+
+// column merges vertically disjoint boxes into one, left-aligned.
+func column(boxes ...box) box {
+	top, bottom := 0, 0
+	for _, b := range boxes {
+		top = min(top, -b.base)
+		bottom = max(bottom, b.height()-1-b.base)
+	}
+	lines := make([]string, bottom-top+1)
+	for _, b := range boxes {
+		for i, l := range b.lines {
+			lines[i-b.base-top] = l
+		}
+	}
+	return box{lines: lines, base: -top}
+}
+
+func convertScript(b box, table map[rune]rune) (string, bool) {
+	if !b.isLine() {
+		return "", false
+	}
+	var s strings.Builder
+	for _, r := range b.lines[0] {
+		if r == ' ' {
+			// Scripts are set tightly.
+			continue
+		}
+		c, ok := table[r]
+		if !ok {
+			return "", false
+		}
+		s.WriteRune(c)
+	}
+	return s.String(), true
+}
+
+func (p *mathParser) parseAtom() ([]mathItem, error) {
+	t := p.next()
+	switch t {
+	case "":
+		return nil, fmt.Errorf("%w: unexpected end of formula", errUnsupportedMath)
+	case "{":
+		items, _, err := p.parseSeq("}")
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		return []mathItem{{joinItems(items), kindOrd}}, nil
+	case "^", "_":
+		// A script without a base.
+		p.pos--
+		return []mathItem{{textBox(""), kindOrd}}, nil
+	case "\\frac", "\\dfrac", "\\tfrac", "\\cfrac":
+		num, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		den, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return []mathItem{{p.fraction(num, den), kindOrd}}, nil
+	case "\\binom":
+		n, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		k, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		if !p.display {
+			return []mathItem{{textBox("C(" + linear(n) + ", " + linear(k) + ")"), kindOrd}}, nil
+		}
+		inner := vstack([]box{n, k}, n.height(), true)
+		return []mathItem{{hcat(delimiter("(", inner.height()), inner, delimiter(")", inner.height())), kindOrd}}, nil
+	case "\\sqrt":
+		var index string
+		if p.peek() == "[" {
+			p.pos++
+			for p.peek() != "]" {
+				if p.peek() == "" {
+					return nil, fmt.Errorf("%w: unclosed root index", errUnsupportedMath)
+				}
+				index += p.next()
+			}
+			p.pos++
+		}
+		arg, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return []mathItem{{p.root(index, arg), kindOrd}}, nil
+	case "\\left":
+		open := p.next()
+		items, _, err := p.parseSeq("\\right")
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		closing := p.next()
+		inner := joinItems(items)
+		return []mathItem{{p.fenced(delimiterSymbol(open), inner, delimiterSymbol(closing)), kindOrd}}, nil
+	case "\\begin":
+		env, err := p.parseRawGroup()
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.environment(env)
+		if err != nil {
+			return nil, err
+		}
+		return []mathItem{{b, kindOrd}}, nil
+	case "\\text", "\\textrm", "\\textit", "\\textbf", "\\mbox", "\\operatorname":
+		s, err := p.parseRawGroup()
+		if err != nil {
+			return nil, err
+		}
+		kind := kindOrd
+		if t == "\\operatorname" {
+			kind = kindFunc
+		}
+		return []mathItem{{textBox(s), kind}}, nil
+	case "\\mathrm", "\\mathit", "\\mathbf", "\\mathsf", "\\mathtt", "\\boldsymbol", "\\bm", "\\displaystyle", "\\textstyle":
+		if t == "\\displaystyle" || t == "\\textstyle" {
+			return nil, nil
+		}
+		g, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return []mathItem{{g, kindOrd}}, nil
+	case "\\mathbb", "\\mathcal", "\\mathfrak":
+		s, err := p.parseRawGroup()
+		if err != nil {
+			return nil, err
+		}
+		table := map[string]map[rune]rune{"\\mathbb": doubleStruck, "\\mathcal": script, "\\mathfrak": fraktur}[t]
+		var b strings.Builder
+		for _, r := range s {
+			if c, ok := table[r]; ok {
+				b.WriteRune(c)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+		return []mathItem{{textBox(b.String()), kindOrd}}, nil
+	}
+
+	if mark, ok := accents[t]; ok {
+		g, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		if !g.isLine() {
+			return nil, fmt.Errorf("%w: accent on %s", errUnsupportedMath, t)
+		}
+		return []mathItem{{textBox(accent(g.lines[0], mark, t == "\\overline" || t == "\\underline")), kindOrd}}, nil
+	}
+
+	if s, ok := mathSymbols[t]; ok {
+		return []mathItem{{textBox(s.s), s.kind}}, nil
+	}
+
+	if strings.HasPrefix(t, "\\") {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedMath, t)
+	}
+
+	r := []rune(t)[0]
+	switch {
+	case strings.ContainsRune("+-*", r):
+		return []mathItem{{textBox(map[string]string{"+": "+", "-": "−", "*": "∗"}[t]), kindBin}}, nil
+	case strings.ContainsRune("=<>", r):
+		return []mathItem{{textBox(t), kindRel}}, nil
+	case strings.ContainsRune(",;", r):
+		return []mathItem{{textBox(t), kindPunct}}, nil
+	case strings.ContainsRune("([", r):
+		return []mathItem{{textBox(t), kindOpen}}, nil
+	case strings.ContainsRune(")]", r):
+		return []mathItem{{textBox(t), kindClose}}, nil
+	case r == '~':
+		return []mathItem{{textBox(" "), kindSpace}}, nil
+	case r == '&' || r == '}':
+		return nil, fmt.Errorf("%w: unexpected %s", errUnsupportedMath, t)
+	}
+	return []mathItem{{textBox(t), kindOrd}}, nil
+}
+
+func (p *mathParser) fraction(num, den box) box {
+	if !p.display {
+		return textBox(linear(num) + "/" + linear(den))
+	}
+	w := max(num.width(), den.width()) + 2
+	bar := textBox(strings.Repeat("─", w))
+	return vstack([]box{num, bar, den}, num.height(), true)
+}
+
+// linear returns a single-line form of a box, parenthesized if needed.
+func linear(b box) string {
+	s := strings.Join(b.lines, " ")
+	if runewidth.StringWidth(s) <= 1 || isWord(s) {
+		return s
+	}
+	return "(" + s + ")"
+}
+
+func isWord(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r) && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *mathParser) root(index string, arg box) box {
+	sign := "√"
+	switch index {
+	case "":
+	case "3":
+		sign = "∛"
+	case "4":
+		sign = "∜"
+	default:
+		if s, ok := convertScript(textBox(index), superscripts); ok {
+			sign = s + "√"
+		} else {
+			sign = "(" + index + ")√"
+		}
+	}
+
+	if !p.display || arg.isLine() && runewidth.StringWidth(arg.lines[0]) == 1 {
+		if arg.isLine() {
+			return textBox(sign + linear(arg))
+		}
+		return textBox(sign + "(" + strings.Join(arg.lines, " ") + ")")
+	}
+
+	// Draw a bar over the radicand.
+	w := runewidth.StringWidth(sign)
+	bar := textBox(strings.Repeat(" ", w) + strings.Repeat("_", arg.width()))
+	signBox := delimiterColumn(sign, arg)
+	body := hcat(signBox, arg)
+	lines := append([]string{bar.lines[0]}, body.lines...)
+	return box{lines: lines, base: body.base + 1}
+}
+
+// delimiterColumn returns a column with the sign on the box's bottom row.
+func delimiterColumn(sign string, b box) box {
+	lines := make([]string, b.height())
+	for i := range lines {
+		lines[i] = strings.Repeat(" ", runewidth.StringWidth(sign))
+	}
+	lines[len(lines)-1] = sign
+	return box{lines: lines, base: b.base}
+}
+
+func (p *mathParser) fenced(open string, inner box, closing string) box {
+	if !p.display || inner.isLine() {
+		if !inner.isLine() {
+			inner = textBox(strings.Join(inner.lines, " "))
+		}
+		return hcat(textBox(strings.TrimPrefix(open, ".")), inner, textBox(strings.TrimPrefix(closing, ".")))
+	}
+	h := inner.height()
+	l, r := delimiter(open, h), delimiter(closing, h)
+	l.base, r.base = inner.base, inner.base
+	return hcat(l, inner, r)
+}
+
+func delimiterSymbol(t string) string {
+	if s, ok := mathSymbols[t]; ok {
+		return s.s
+	}
+	return strings.TrimPrefix(t, "\\")
+}
+
+// environment renders a \begin{env}...\end{env} block.
+func (p *mathParser) environment(env string) (box, error) {
+	delims := map[string][2]string{
+		"matrix":      {"", ""},
+		"pmatrix":     {"(", ")"},
+		"bmatrix":     {"[", "]"},
+		"Bmatrix":     {"{", "}"},
+		"vmatrix":     {"|", "|"},
+		"Vmatrix":     {"‖", "‖"},
+		"cases":       {"{", "."},
+		"aligned":     {"", ""},
+		"align":       {"", ""},
+		"align*":      {"", ""},
+		"gathered":    {"", ""},
+		"array":       {"", ""},
+		"smallmatrix": {"", ""},
+	}
+	d, ok := delims[env]
+	if !ok {
+		return box{}, fmt.Errorf("%w: environment %s", errUnsupportedMath, env)
+	}
+	if env == "array" && p.peek() == "{" {
+		if _, err := p.parseRawGroup(); err != nil {
+			return box{}, err
+		}
+	}
+
+	var rows [][]box
+	row := []box{}
+	for {
+		items, stop, err := p.parseSeq("&", "\\\\", "\\end")
+		if err != nil {
+			return box{}, err
+		}
+		row = append(row, joinItems(items))
+		p.pos++
+		if stop == "&" {
+			continue
+		}
+		rows = append(rows, row)
+		row = []box{}
+		if stop == "\\end" {
+			break
+		}
+	}
+	if end, err := p.parseRawGroup(); err != nil || end != env {
+		return box{}, fmt.Errorf("%w: mismatched \\end{%s}", errUnsupportedMath, end)
+	}
+	// A trailing \\ leaves an empty last row.
+	if last := rows[len(rows)-1]; len(rows) > 1 && len(last) == 1 && last[0].width() == 0 {
+		rows = rows[:len(rows)-1]
+	}
+
+	if !p.display {
+		cells := make([]string, len(rows))
+		for i, r := range rows {
+			var c []string
+			for _, cell := range r {
+				c = append(c, strings.Join(cell.lines, " "))
+			}
+			sep := " "
+			if env == "cases" {
+				sep = ", "
+			}
+			cells[i] = strings.Join(c, sep)
+		}
+		open, closing := d[0], d[1]
+		if closing == "." {
+			closing = ""
+		}
+		if open == "" && closing == "" && len(rows) > 1 {
+			open, closing = "[", "]"
+		}
+		return textBox(open + strings.Join(cells, "; ") + closing), nil
+	}
+
+	// Lay out the grid.
+	var widths []int
+	for _, r := range rows {
+		for j, cell := range r {
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = max(widths[j], cell.width())
+		}
+	}
+	gap := "  "
+	if strings.HasPrefix(env, "align") {
+		gap = ""
+	}
+	var lines []box
+	for _, r := range rows {
+		var cells []box
+		for j, w := range widths {
+			cell := textBox("")
+			if j < len(r) {
+				cell = r[j]
+			}
+			cw := cell.width()
+			var padded box
+			switch {
+			case strings.HasPrefix(env, "align") && j%2 == 0:
+				padded = hcat(textBox(strings.Repeat(" ", w-cw)), cell)
+			case env == "cases" || strings.HasPrefix(env, "align"):
+				padded = hcat(cell, textBox(strings.Repeat(" ", w-cw)))
+			default:
+				left := (w - cw) / 2
+				padded = hcat(textBox(strings.Repeat(" ", left)), cell, textBox(strings.Repeat(" ", w-cw-left)))
+			}
+			if j > 0 {
+				padded = hcat(textBox(gap), padded)
+			}
+			cells = append(cells, padded)
+		}
+		lines = append(lines, hcat(cells...))
+	}
+	grid := vstack(lines, 0, false)
+	grid.base = grid.height() / 2
+	if d[0] == "" && d[1] == "" {
+		return grid, nil
+	}
+	h := grid.height()
+	l, r := delimiter(d[0], h), delimiter(d[1], h)
+	l.base, r.base = grid.base, grid.base
+	parts := []box{l}
+	if d[0] != "" && d[0] != "." {
+		parts = append(parts, textBox(" "))
+	}
+	parts = append(parts, grid)
+	if d[1] != "" && d[1] != "." {
+		parts = append(parts, textBox(" "), r)
+	}
+	return hcat(parts...), nil
+}
+
+// joinItems lays out a sequence of items on a common baseline, adding space
+// around binary operators and relations.
+func joinItems(items []mathItem) box {
+	if len(items) == 0 {
+		return textBox("")
+	}
+	var parts []box
+	prev := kindSpace
+	first := true
+	for _, it := range items {
+		kind := it.kind
+		if kind == kindBin && (first || prev == kindBin || prev == kindRel || prev == kindOpen || prev == kindPunct || prev == kindOp) {
+			// Unary minus or plus.
+			kind = kindOrd
+		}
+		space := false
+		if !first {
+			switch {
+			case kind == kindBin || kind == kindRel:
+				space = true
+			case prev == kindBin || prev == kindRel || prev == kindPunct:
+				space = kind != kindClose
+			case prev == kindOp && kind != kindClose && kind != kindPunct:
+				space = true
+			case prev == kindFunc && kind == kindOrd:
+				space = true
+			}
+		}
+		if prev == kindSpace || kind == kindSpace {
+			space = false
+		}
+		if space {
+			parts = append(parts, textBox(" "))
+		}
+		parts = append(parts, it.b)
+		prev = kind
+		first = false
+	}
+	return hcat(parts...)
+}
+
+func accent(s string, mark rune, every bool) string {
+	rs := []rune(s)
+	if len(rs) == 0 {
+		return string(mark)
+	}
+	var b strings.Builder
+	for i, r := range rs {
+		b.WriteRune(r)
+		if every || i == len(rs)-1 {
+			b.WriteRune(mark)
+		}
+	}
+	return b.String()
+}
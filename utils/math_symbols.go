@@ -0,0 +1,150 @@
+package utils
+
+type mathSymbol struct {
+	s    string
+	kind atomKind
+}
+
+// mathSymbols maps TeX commands to their Unicode rendering.
+var mathSymbols = map[string]mathSymbol{
+	// Greek letters
+	`\alpha`: {"α", kindOrd}, `\beta`: {"β", kindOrd}, `\gamma`: {"γ", kindOrd},
+	`\delta`: {"δ", kindOrd}, `\epsilon`: {"ϵ", kindOrd}, `\varepsilon`: {"ε", kindOrd},
+	`\zeta`: {"ζ", kindOrd}, `\eta`: {"η", kindOrd}, `\theta`: {"θ", kindOrd},
+	`\vartheta`: {"ϑ", kindOrd}, `\iota`: {"ι", kindOrd}, `\kappa`: {"κ", kindOrd},
+	`\lambda`: {"λ", kindOrd}, `\mu`: {"μ", kindOrd}, `\nu`: {"ν", kindOrd},
+	`\xi`: {"ξ", kindOrd}, `\pi`: {"π", kindOrd}, `\varpi`: {"ϖ", kindOrd},
+	`\rho`: {"ρ", kindOrd}, `\varrho`: {"ϱ", kindOrd}, `\sigma`: {"σ", kindOrd},
+	`\varsigma`: {"ς", kindOrd}, `\tau`: {"τ", kindOrd}, `\upsilon`: {"υ", kindOrd},
+	`\phi`: {"ϕ", kindOrd}, `\varphi`: {"φ", kindOrd}, `\chi`: {"χ", kindOrd},
+	`\psi`: {"ψ", kindOrd}, `\omega`: {"ω", kindOrd},
+	`\Gamma`: {"Γ", kindOrd}, `\Delta`: {"Δ", kindOrd}, `\Theta`: {"Θ", kindOrd},
+	`\Lambda`: {"Λ", kindOrd}, `\Xi`: {"Ξ", kindOrd}, `\Pi`: {"Π", kindOrd},
+	`\Sigma`: {"Σ", kindOrd}, `\Upsilon`: {"Υ", kindOrd}, `\Phi`: {"Φ", kindOrd},
+	`\Psi`: {"Ψ", kindOrd}, `\Omega`: {"Ω", kindOrd},
+
+	// Letter-like symbols
+	`\infty`: {"∞", kindOrd}, `\partial`: {"∂", kindOrd}, `\nabla`: {"∇", kindOrd},
+	`\hbar`: {"ℏ", kindOrd}, `\ell`: {"ℓ", kindOrd}, `\Re`: {"ℜ", kindOrd},
+	`\Im`: {"ℑ", kindOrd}, `\aleph`: {"ℵ", kindOrd}, `\emptyset`: {"∅", kindOrd},
+	`\varnothing`: {"∅", kindOrd}, `\forall`: {"∀", kindOrd}, `\exists`: {"∃", kindOrd},
+	`\nexists`: {"∄", kindOrd}, `\neg`: {"¬", kindOrd}, `\lnot`: {"¬", kindOrd},
+	`\angle`: {"∠", kindOrd}, `\triangle`: {"△", kindOrd}, `\prime`: {"′", kindOrd},
+	`\degree`: {"°", kindOrd}, `\ldots`: {"…", kindOrd}, `\dots`: {"…", kindOrd},
+	`\cdots`: {"⋯", kindOrd}, `\vdots`: {"⋮", kindOrd}, `\ddots`: {"⋱", kindOrd},
+	`\%`: {"%", kindOrd}, `\$`: {"$", kindOrd}, `\#`: {"#", kindOrd}, `\&`: {"&", kindOrd},
+	`\_`: {"_", kindOrd}, `\|`: {"‖", kindOrd}, `\Vert`: {"‖", kindOrd}, `\vert`: {"|", kindOrd},
+	`\mid`: {"∣", kindRel},
+
+	// Binary operators
+	`\times`: {"×", kindBin}, `\cdot`: {"·", kindBin}, `\pm`: {"±", kindBin},
+	`\mp`: {"∓", kindBin}, `\div`: {"÷", kindBin}, `\ast`: {"∗", kindBin},
+	`\star`: {"⋆", kindBin}, `\circ`: {"∘", kindBin}, `\bullet`: {"∙", kindBin},
+	`\cup`: {"∪", kindBin}, `\cap`: {"∩", kindBin}, `\setminus`: {"∖", kindBin},
+	`\wedge`: {"∧", kindBin}, `\land`: {"∧", kindBin}, `\vee`: {"∨", kindBin},
+	`\lor`: {"∨", kindBin}, `\oplus`: {"⊕", kindBin}, `\ominus`: {"⊖", kindBin},
+	`\otimes`: {"⊗", kindBin}, `\odot`: {"⊙", kindBin},
+
+	// Relations
+	`\leq`: {"≤", kindRel}, `\le`: {"≤", kindRel}, `\geq`: {"≥", kindRel},
+	`\ge`: {"≥", kindRel}, `\neq`: {"≠", kindRel}, `\ne`: {"≠", kindRel},
+	`\ll`: {"≪", kindRel}, `\gg`: {"≫", kindRel}, `\approx`: {"≈", kindRel},
+	`\equiv`: {"≡", kindRel}, `\sim`: {"∼", kindRel}, `\simeq`: {"≃", kindRel},
+	`\cong`: {"≅", kindRel}, `\propto`: {"∝", kindRel}, `\in`: {"∈", kindRel},
+	`\notin`: {"∉", kindRel}, `\ni`: {"∋", kindRel}, `\subset`: {"⊂", kindRel},
+	`\subseteq`: {"⊆", kindRel}, `\supset`: {"⊃", kindRel}, `\supseteq`: {"⊇", kindRel},
+	`\perp`: {"⊥", kindRel}, `\parallel`: {"∥", kindRel}, `\to`: {"→", kindRel},
+	`\rightarrow`: {"→", kindRel}, `\leftarrow`: {"←", kindRel}, `\gets`: {"←", kindRel},
+	`\leftrightarrow`: {"↔", kindRel}, `\Rightarrow`: {"⇒", kindRel}, `\Leftarrow`: {"⇐", kindRel},
+	`\Leftrightarrow`: {"⇔", kindRel}, `\iff`: {"⇔", kindRel}, `\implies`: {"⟹", kindRel},
+	`\mapsto`: {"↦", kindRel}, `\longrightarrow`: {"⟶", kindRel}, `\uparrow`: {"↑", kindRel},
+	`\downarrow`: {"↓", kindRel}, `\coloneqq`: {"≔", kindRel}, `\colon`: {":", kindPunct},
+
+	// Delimiters
+	`\langle`: {"⟨", kindOpen}, `\rangle`: {"⟩", kindClose}, `\lfloor`: {"⌊", kindOpen},
+	`\rfloor`: {"⌋", kindClose}, `\lceil`: {"⌈", kindOpen}, `\rceil`: {"⌉", kindClose},
+	`\{`: {"{", kindOpen}, `\}`: {"}", kindClose}, `\lbrace`: {"{", kindOpen},
+	`\rbrace`: {"}", kindClose},
+
+	// Big operators
+	`\sum`: {"∑", kindOp}, `\prod`: {"∏", kindOp}, `\coprod`: {"∐", kindOp},
+	`\int`: {"∫", kindOp}, `\iint`: {"∬", kindOp}, `\iiint`: {"∭", kindOp},
+	`\oint`: {"∮", kindOp}, `\bigcup`: {"⋃", kindOp}, `\bigcap`: {"⋂", kindOp},
+	`\bigoplus`: {"⨁", kindOp}, `\bigotimes`: {"⨂", kindOp}, `\bigvee`: {"⋁", kindOp},
+	`\bigwedge`: {"⋀", kindOp}, `\lim`: {"lim", kindOp}, `\limsup`: {"lim sup", kindOp},
+	`\liminf`: {"lim inf", kindOp}, `\max`: {"max", kindOp}, `\min`: {"min", kindOp},
+	`\sup`: {"sup", kindOp}, `\inf`: {"inf", kindOp}, `\argmax`: {"argmax", kindOp},
+	`\argmin`: {"argmin", kindOp},
+
+	// Functions
+	`\sin`: {"sin", kindFunc}, `\cos`: {"cos", kindFunc}, `\tan`: {"tan", kindFunc},
+	`\cot`: {"cot", kindFunc}, `\sec`: {"sec", kindFunc}, `\csc`: {"csc", kindFunc},
+	`\arcsin`: {"arcsin", kindFunc}, `\arccos`: {"arccos", kindFunc}, `\arctan`: {"arctan", kindFunc},
+	`\sinh`: {"sinh", kindFunc}, `\cosh`: {"cosh", kindFunc}, `\tanh`: {"tanh", kindFunc},
+	`\log`: {"log", kindFunc}, `\ln`: {"ln", kindFunc}, `\lg`: {"lg", kindFunc},
+	`\exp`: {"exp", kindFunc}, `\det`: {"det", kindFunc}, `\dim`: {"dim", kindFunc},
+	`\ker`: {"ker", kindFunc}, `\deg`: {"deg", kindFunc}, `\gcd`: {"gcd", kindFunc},
+	`\arg`: {"arg", kindFunc}, `\Pr`: {"Pr", kindFunc}, `\mod`: {"mod", kindFunc},
+	`\bmod`: {"mod", kindBin},
+
+	// Spacing
+	`\,`: {" ", kindSpace}, `\:`: {" ", kindSpace}, `\;`: {" ", kindSpace},
+	`\ `: {" ", kindSpace}, `\!`: {"", kindSpace}, `\quad`: {"  ", kindSpace},
+	`\qquad`: {"    ", kindSpace},
+
+	// Sizing commands are ignored; delimiters are sized automatically.
+	`\big`: {"", kindSpace}, `\Big`: {"", kindSpace}, `\bigg`: {"", kindSpace},
+	`\Bigg`: {"", kindSpace}, `\bigl`: {"", kindSpace}, `\bigr`: {"", kindSpace},
+	`\Bigl`: {"", kindSpace}, `\Bigr`: {"", kindSpace},
+}
+
+// Big operators whose limits go above and below in display math.
+var limitOps = map[string]bool{
+	"∑": true, "∏": true, "∐": true, "⋃": true, "⋂": true, "⨁": true, "⨂": true,
+	"⋁": true, "⋀": true, "lim": true, "lim sup": true, "lim inf": true, "max": true,
+	"min": true, "sup": true, "inf": true, "argmax": true, "argmin": true,
+}
+
+// Combining characters used for accents.
+var accents = map[string]rune{
+	`\hat`: '̂', `\widehat`: '̂', `\bar`: '̄', `\overline`: '̅',
+	`\underline`: '̲', `\vec`: '⃗', `\dot`: '̇', `\ddot`: '̈',
+	`\tilde`: '̃', `\widetilde`: '̃', `\acute`: '́', `\grave`: '̀',
+	`\breve`: '̆', `\check`: '̌',
+}
+
+var superscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷',
+	'8': '⁸', '9': '⁹', '+': '⁺', '−': '⁻', '-': '⁻', '=': '⁼', '(': '⁽', ')': '⁾',
+	'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ', 'd': 'ᵈ', 'e': 'ᵉ', 'f': 'ᶠ', 'g': 'ᵍ', 'h': 'ʰ',
+	'i': 'ⁱ', 'j': 'ʲ', 'k': 'ᵏ', 'l': 'ˡ', 'm': 'ᵐ', 'n': 'ⁿ', 'o': 'ᵒ', 'p': 'ᵖ',
+	'r': 'ʳ', 's': 'ˢ', 't': 'ᵗ', 'u': 'ᵘ', 'v': 'ᵛ', 'w': 'ʷ', 'x': 'ˣ', 'y': 'ʸ',
+	'z': 'ᶻ', 'A': 'ᴬ', 'B': 'ᴮ', 'D': 'ᴰ', 'E': 'ᴱ', 'G': 'ᴳ', 'H': 'ᴴ', 'I': 'ᴵ',
+	'J': 'ᴶ', 'K': 'ᴷ', 'L': 'ᴸ', 'M': 'ᴹ', 'N': 'ᴺ', 'O': 'ᴼ', 'P': 'ᴾ', 'R': 'ᴿ',
+	'T': 'ᵀ', 'U': 'ᵁ', 'V': 'ⱽ', 'W': 'ᵂ', 'β': 'ᵝ', 'γ': 'ᵞ', 'δ': 'ᵟ', 'θ': 'ᶿ',
+	'φ': 'ᵠ', 'χ': 'ᵡ', '′': '′',
+}
+
+var subscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇',
+	'8': '₈', '9': '₉', '+': '₊', '−': '₋', '-': '₋', '=': '₌', '(': '₍', ')': '₎',
+	'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ', 'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ', 'l': 'ₗ', 'm': 'ₘ',
+	'n': 'ₙ', 'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ', 's': 'ₛ', 't': 'ₜ', 'u': 'ᵤ', 'v': 'ᵥ',
+	'x': 'ₓ', 'β': 'ᵦ', 'γ': 'ᵧ', 'ρ': 'ᵨ', 'φ': 'ᵩ', 'χ': 'ᵪ',
+}
+
+var doubleStruck = map[rune]rune{
+	'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	'A': '𝔸', 'B': '𝔹', 'D': '𝔻', 'E': '𝔼', 'F': '𝔽', 'G': '𝔾', 'K': '𝕂',
+	'1': '𝟙',
+}
+
+var script = map[rune]rune{
+	'A': '𝒜', 'B': 'ℬ', 'C': '𝒞', 'D': '𝒟', 'E': 'ℰ', 'F': 'ℱ', 'G': '𝒢', 'H': 'ℋ',
+	'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ', 'N': '𝒩', 'O': '𝒪', 'P': '𝒫', 'R': 'ℛ', 'S': '𝒮',
+	'T': '𝒯',
+}
+
+var fraktur = map[rune]rune{
+	'g': '𝔤', 'h': '𝔥', 'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ',
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMathBlocks_Inline(t *testing.T) {
+	tt := []struct {
+		input    string
+		expected string
+	}{
+		{"Euler: $e^{i\\pi} + 1 = 0$.", "Euler: e^(iπ) + 1 = 0."},
+		{"Area $A = \\pi r^2$", "Area A = πr²"},
+		{"Index $x_i + y_{n-1}$", "Index xᵢ + yₙ₋₁"},
+		{"Half $\\frac{1}{2}$", "Half 1/2"},
+		{"Sum $\\sum_{i=1}^n i$", "Sum ∑ᵢ₌₁ⁿ i"},
+		{"Sets $\\mathbb{R} \\subseteq \\mathbb{C}$", "Sets ℝ ⊆ ℂ"},
+		{"Root $\\sqrt{x+1}$", "Root √(x + 1)"},
+	}
+
+	for _, v := range tt {
+		t.Run(v.input, func(t *testing.T) {
+			result := RenderMathBlocks(v.input)
+			if result != v.expected {
+				t.Errorf("got: %q\nwant: %q", result, v.expected)
+			}
+		})
+	}
+}
+
+func TestRenderMathBlocks_LeavesNonMathAlone(t *testing.T) {
+	for _, input := range []string{
+		"It costs $5 and $10.",
+		"Escaped \\$x$ dollars",
+		"Code `$x^2$` span",
+		"```sh\necho $HOME $PATH\n```",
+		"Space $ x$ before",
+	} {
+		if result := RenderMathBlocks(input); result != input {
+			t.Errorf("expected %q to be unchanged, got %q", input, result)
+		}
+	}
+}
+
+func TestRenderMathBlocks_SkipsCodeAndHTML(t *testing.T) {
+	for _, input := range []string{
+		"    code $x^2$",
+		"Text\n\n\tcode $x^2$\n\n    more $y^2$",
+		"> quote\n>\n>     code $x^2$",
+		"<div>\n$x^2$\n</div>",
+		"<!-- a\n$x^2$\n-->",
+		"<pre>\n$x^2$\n\n$y^2$\n</pre>",
+		"<span>\n$x^2$",
+	} {
+		if result := RenderMathBlocks(input); result != input {
+			t.Errorf("expected %q to be unchanged, got %q", input, result)
+		}
+	}
+
+	// Indented lines continue paragraphs and list items, and HTML blocks end.
+	for input, want := range map[string]string{
+		"Text\n    more $x^2$":        "Text\n    more x²",
+		"- item\n\n  text $x^2$":      "- item\n\n  text x²",
+		"1. item\n\n    text $x^2$":   "1. item\n\n    text x²",
+		"<div>\n</div>\n\nText $x^2$": "<div>\n</div>\n\nText x²",
+		"Text <span>\n$x^2$":          "Text <span>\nx²",
+	} {
+		if result := RenderMathBlocks(input); result != want {
+			t.Errorf("RenderMathBlocks(%q) = %q, want %q", input, result, want)
+		}
+	}
+}
+
+func TestRenderMathBlocks_EscapesMarkdown(t *testing.T) {
+	result := RenderMathBlocks("$a_{xy}$")
+	if result != "a\\_(xy)" {
+		t.Errorf("unexpected result: %q", result)
+	}
+}
+
+func TestRenderMathBlocks_DisplayFraction(t *testing.T) {
+	input := "Before\n\n$$\n\\frac{a+b}{2}\n$$\n\nAfter"
+	result := RenderMathBlocks(input)
+
+	expected := "Before\n\n```\n a + b\n───────\n   2\n```\n\nAfter"
+	if result != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", result, expected)
+	}
+}
+
+func TestRenderMathBlocks_DisplayLimits(t *testing.T) {
+	result := RenderMathBlocks("$$\\sum_{i=1}^{n} i$$")
+	lines := strings.Split(result, "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected limits above and below the operator, got:\n%s", result)
+	}
+	if !strings.Contains(lines[1], "n") || !strings.Contains(lines[2], "∑") || !strings.Contains(lines[3], "i = 1") {
+		t.Errorf("unexpected layout:\n%s", result)
+	}
+}
+
+func TestRenderMathBlocks_Matrix(t *testing.T) {
+	result := RenderMathBlocks("```math\n\\begin{pmatrix} 1 & 2 \\\\ 3 & 4 \\end{pmatrix}\n```")
+	expected := "```\n⎛ 1  2 ⎞\n⎝ 3  4 ⎠\n```"
+	if result != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", result, expected)
+	}
+}
+
+func TestRenderMathBlocks_UnsupportedFallsBack(t *testing.T) {
+	for _, input := range []string{
+		"Inline $\\unknowncommand{x}$ math",
+		"$$\n\\begin{tikzpicture}\\end{tikzpicture}\n$$",
+		"```math\n\\frac{1}\n```",
+	} {
+		if result := RenderMathBlocks(input); result != input {
+			t.Errorf("unsupported math should be left unchanged\ngot: %q\nwant: %q", result, input)
+		}
+	}
+}
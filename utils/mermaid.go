@@ -1,124 +1,418 @@
 package utils
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	mermaidcmd "github.com/AlexanderGrooff/mermaid-ascii/cmd"
+	"github.com/charmbracelet/x/ansi"
 )
 
 // RenderMermaidBlocks processes markdown content and renders mermaid code blocks.
 // Mode "raw" returns content unchanged; "ascii" and "unicode" render diagrams.
 func RenderMermaidBlocks(content string, mode string, maxWidth int) string {
+	return RenderMermaidBlocksContext(context.Background(), content, mode, maxWidth)
+}
+
+// RenderMermaidBlocksContext is like RenderMermaidBlocks, but stops when ctx
+// is done, like RenderBlocksContext.
+func RenderMermaidBlocksContext(ctx context.Context, content string, mode string, maxWidth int) string {
+	out, _ := RenderMermaidBlocksExcept(ctx, content, mode, maxWidth, nil)
+	return out
+}
+
+// RenderMermaidBlocksExcept is like RenderMermaidBlocksContext, but keeps the
+// diagrams whose source keep returns true for as they are. It also returns
+// how many of the other diagrams failed or timed out.
+func RenderMermaidBlocksExcept(ctx context.Context, content string, mode string, maxWidth int, keep func(source string) bool) (string, int) {
 	if content == "" {
-		return content
+		return content, 0
 	}
 
 	mode = strings.ToLower(mode)
 	if mode == "raw" {
-		return content
-	}
-
-	useAscii := mode == "ascii"
-	return processMermaidBlocks(content, maxWidth, useAscii)
-}
-
-// fencedBlock represents a parsed fenced code block.
-type fencedBlock struct {
-	startLine    int    // line index where block starts
-	endLine      int    // line index where block ends (inclusive)
-	fenceChar    rune   // '`' or '~'
-	fenceLen     int    // length of fence (>= 3)
-	indentPrefix string // leading whitespace (up to 3 spaces)
-	infoString   string // language/info after fence
-	content      string // content inside the block
-}
-
-// processMermaidBlocks uses a line-scanner to find and replace mermaid blocks.
-// This correctly handles nested fences, indentation, and CRLF line endings.
-func processMermaidBlocks(content string, maxWidth int, useAscii bool) string {
-	// First check if we have any mermaid blocks before normalizing
-	// Normalize CRLF to LF for consistent processing
-	normalized := strings.ReplaceAll(content, "\r\n", "\n")
-
-	lines := strings.Split(normalized, "\n")
-	blocks := findMermaidBlocks(lines)
-
-	// If no mermaid blocks found, return original content unchanged
-	if len(blocks) == 0 {
-		return content
-	}
-
-	// Process blocks in reverse order to preserve line indices
-	for i := len(blocks) - 1; i >= 0; i-- {
-		block := blocks[i]
-		rendered := renderMermaidBlock(block, maxWidth, useAscii)
-		lines = replaceLines(lines, block.startLine, block.endLine, rendered)
-	}
-
-	return strings.Join(lines, "\n")
-}
-
-// findMermaidBlocks scans lines and returns all top-level mermaid fenced blocks.
-// Blocks nested inside other fenced blocks are ignored.
-func findMermaidBlocks(lines []string) []fencedBlock {
-	var blocks []fencedBlock
-	var currentBlock *fencedBlock
-	inFence := false
-	var fenceChar rune
-	var fenceLen int
-
-	for i, line := range lines {
-		// Check if this line is a fence
-		indent, char, length, info := parseFenceLine(line)
-
-		if !inFence {
-			// Not currently in a fence - check for opening fence
-			if length >= 3 {
-				inFence = true
-				fenceChar = char
-				fenceLen = length
-
-				// Check if this is a mermaid block (case-insensitive)
-				infoToken := strings.Fields(info)
-				if len(infoToken) > 0 && strings.EqualFold(infoToken[0], "mermaid") {
-					currentBlock = &fencedBlock{
-						startLine:    i,
-						fenceChar:    char,
-						fenceLen:     length,
-						indentPrefix: indent,
-						infoString:   info,
-					}
-				}
+		return content, 0
+	}
+
+	r := mermaidRenderer{ascii: mode == "ascii", keep: keep, failed: new(atomic.Int64)}
+	out := RenderBlocksContext(ctx, content, BlockRenderers{"mermaid": r}, maxWidth)
+	return out, int(r.failed.Load())
+}
+
+// mermaidRenderer renders mermaid diagrams as ASCII or Unicode art.
+type mermaidRenderer struct {
+	ascii  bool
+	keep   func(source string) bool
+	failed *atomic.Int64 // diagrams that failed or timed out, if not nil
+}
+
+func (r mermaidRenderer) RenderBlock(content string, width int) (string, error) {
+	return r.RenderBlockContext(context.Background(), content, width)
+}
+
+func (r mermaidRenderer) RenderBlockContext(ctx context.Context, content string, width int) (string, error) {
+	if r.keep != nil && r.keep(content) {
+		return "", errKeepSource
+	}
+	out, err := renderMermaidContext(ctx, content, r.ascii, width)
+	if err != nil && ctx.Err() == nil {
+		if r.failed != nil {
+			r.failed.Add(1)
+		}
+		return "", mermaidError(content, err)
+	}
+	return out, err
+}
+
+// DiagramTimeout is how long a diagram may take to lay out. Some diagrams
+// make the layout engine spin for a long time.
+var DiagramTimeout = DefaultRenderTimeout
+
+// DiagramCache keeps the diagrams rendered by RenderMermaidBlocks and
+// RenderDotBlocks across runs, as laying them out is slow. Nil disables it.
+var DiagramCache *DiskCache
+
+// diagramCacheVersion is part of the keys of cached diagrams. Change it when
+// the rendering of diagrams changes, like when mermaid-ascii is upgraded.
+const diagramCacheVersion = "1"
+
+// renderMermaidContext renders a mermaid diagram like renderMermaid, giving up
+// when ctx is done or after the DiagramTimeout. The layout engine can't be
+// stopped, so it finishes in the background, and the diagram is cached for
+// the next time. Until then, rendering the diagram again waits for the same
+// layout, for what's left of its timeout.
+func renderMermaidContext(ctx context.Context, content string, ascii bool, width int) (string, error) {
+	l := layoutMermaid(content, ascii, width)
+	select {
+	case <-l.done:
+		return l.out, l.err
+	default:
+	}
+
+	timeout := fmt.Errorf("diagram timed out after %s", DiagramTimeout)
+	ctx, cancel := context.WithDeadlineCause(ctx, l.started.Add(DiagramTimeout), timeout)
+	defer cancel()
+	select {
+	case <-l.done:
+		return l.out, l.err
+	case <-ctx.Done():
+		return "", context.Cause(ctx) //nolint:wrapcheck
+	}
+}
+
+// mermaidLayout is a diagram being rendered in the background.
+type mermaidLayout struct {
+	started time.Time
+	done    chan struct{} // closed when out and err are set
+	out     string
+	err     error
+}
+
+var (
+	mermaidLayoutsMu sync.Mutex
+	mermaidLayouts   = map[string]*mermaidLayout{} // cache key -> layout
+)
+
+// layoutMermaid starts rendering a diagram with renderMermaid, or returns the
+// rendering of the same diagram in progress. Panics of the layout engine are
+// returned as errors.
+func layoutMermaid(content string, ascii bool, width int) *mermaidLayout {
+	key := mermaidKey(content, ascii, width)
+	mermaidLayoutsMu.Lock()
+	defer mermaidLayoutsMu.Unlock()
+	if l, ok := mermaidLayouts[key]; ok {
+		return l
+	}
+
+	l := &mermaidLayout{started: time.Now(), done: make(chan struct{})}
+	mermaidLayouts[key] = l
+	// The layout may outlive the render that started it, so it keeps the
+	// cache of that render.
+	cache := DiagramCache
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				l.out, l.err = "", fmt.Errorf("mermaid-ascii: %v", r)
+				mermaidResults.Store(key, renderResult{l.out, l.err})
 			}
-		} else {
-			// Currently in a fence - check for closing fence
-			// Closing fence must use same char and length >= opening length
-			if char == fenceChar && length >= fenceLen && strings.TrimSpace(info) == "" {
-				if currentBlock != nil {
-					// End of a mermaid block
-					currentBlock.endLine = i
-					// Extract content (lines between start and end)
-					var contentLines []string
-					for j := currentBlock.startLine + 1; j < i; j++ {
-						// Remove the indent prefix from content lines
-						contentLine := lines[j]
-						if strings.HasPrefix(contentLine, currentBlock.indentPrefix) {
-							contentLine = contentLine[len(currentBlock.indentPrefix):]
-						}
-						contentLines = append(contentLines, contentLine)
-					}
-					currentBlock.content = strings.Join(contentLines, "\n")
-					blocks = append(blocks, *currentBlock)
-					currentBlock = nil
-				}
-				inFence = false
-				fenceChar = 0
-				fenceLen = 0
+			mermaidLayoutsMu.Lock()
+			delete(mermaidLayouts, key)
+			mermaidLayoutsMu.Unlock()
+			close(l.done)
+		}()
+		l.out, l.err = renderMermaid(cache, content, ascii, width)
+	}()
+	return l
+}
+
+// renderDiagram lays out a mermaid diagram. It's replaced in tests.
+var renderDiagram = mermaidcmd.RenderDiagramWithOptions
+
+// renderMermaid renders a mermaid diagram to fit within width columns, or
+// returns it from cache.
+func renderMermaid(cache *DiskCache, content string, ascii bool, width int) (string, error) {
+	key := mermaidKey(content, ascii, width)
+	if res, ok := mermaidResults.Load(key); ok {
+		return res.out, res.err
+	}
+	if out, ok := cache.Get(key); ok {
+		mermaidResults.Store(key, renderResult{out, nil})
+		return out, nil
+	}
+
+	options := []mermaidcmd.RenderOption{mermaidcmd.WithMaxWidth(width)}
+	if ascii {
+		options = append(options, mermaidcmd.WithAscii())
+	}
+	out, err := renderDiagram(content, options...)
+	mermaidResults.Store(key, renderResult{out, err})
+	if err != nil {
+		return "", err //nolint:wrapcheck
+	}
+	// A diagram that can't be cached is still rendered.
+	_ = cache.Put(key, out)
+	return out, nil
+}
+
+func mermaidKey(content string, ascii bool, width int) string {
+	mode := "unicode"
+	if ascii {
+		mode = "ascii"
+	}
+	return CacheKey("mermaid", diagramCacheVersion, mode, strconv.Itoa(width), content)
+}
+
+// Diagrams rendered by this process, and the errors of those that failed,
+// so that finding them in a rendered document doesn't render them again.
+// Only the latest are kept, as each width of a diagram is another entry.
+var mermaidResults = resultCache{max: maxMermaidResults}
+
+const maxMermaidResults = 256
+
+// resultCache keeps up to max results in memory, forgetting the oldest.
+type resultCache struct {
+	mu      sync.Mutex
+	max     int
+	keys    []string // oldest first
+	results map[string]renderResult
+}
+
+func (c *resultCache) Load(key string) (renderResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, ok := c.results[key]
+	return res, ok
+}
+
+func (c *resultCache) Store(key string, res renderResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil {
+		c.results = map[string]renderResult{}
+	}
+	if _, ok := c.results[key]; !ok {
+		c.keys = append(c.keys, key)
+	}
+	c.results[key] = res
+	for len(c.keys) > c.max {
+		delete(c.results, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+}
+
+func (c *resultCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys, c.results = nil, nil
+}
+
+// DiagramError is an error in the source of a diagram, at a line and column
+// from 1. Errors at line 0 aren't about a line in particular.
+type DiagramError struct {
+	Line, Column int
+	Err          error
+}
+
+func (e *DiagramError) Error() string {
+	if e.Line == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *DiagramError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	// mermaidLineRe matches the line numbers of mermaid-ascii errors, which
+	// count the lines that aren't blank or comments.
+	mermaidLineRe = regexp.MustCompile(`\bline (\d+)(: )?`)
+	// mermaidQuoteRe matches the text errors quote, like the unsupported
+	// type in "unsupported graph type 'pie'".
+	mermaidQuoteRe = regexp.MustCompile(`'([^']+)'|("(?:[^"\\]|\\.)+")`)
+)
+
+// mermaidError locates err in the source of a mermaid diagram.
+func mermaidError(content string, err error) *DiagramError {
+	msg := err.Error()
+	lines := strings.Split(content, "\n")
+	line := 0
+
+	if m := mermaidLineRe.FindStringSubmatchIndex(msg); m != nil {
+		n, _ := strconv.Atoi(msg[m[2]:m[3]])
+		for i, l := range lines {
+			if l, _, _ = strings.Cut(l, "%%"); strings.TrimSpace(l) == "" {
+				continue
+			}
+			if n--; n == 0 {
+				line = i + 1
+				break
+			}
+		}
+		if line > 0 && m[4] >= 0 {
+			// The position replaces the line number in the message.
+			msg = msg[:m[0]] + msg[m[1]:]
+		}
+	}
+
+	// Point at the text the error quotes, or else the start of the line.
+	var quoted string
+	if m := mermaidQuoteRe.FindStringSubmatch(msg); m != nil {
+		quoted = m[1]
+		if m[2] != "" {
+			quoted, _ = strconv.Unquote(m[2])
+		}
+	}
+	column := 0
+	for i, l := range lines {
+		if line > 0 && i != line-1 {
+			continue
+		}
+		if quoted != "" {
+			if c := strings.Index(l, quoted); c >= 0 {
+				line, column = i+1, utf8.RuneCountInString(l[:c])+1
+				break
 			}
 		}
+		if line > 0 {
+			column = utf8.RuneCountInString(l) - utf8.RuneCountInString(strings.TrimLeft(l, " \t")) + 1
+			break
+		}
 	}
 
-	return blocks
+	return &DiagramError{Line: line, Column: column, Err: errors.New(msg)}
+}
+
+// Diagnostic is a problem in a document, at a line and column from 1.
+type Diagnostic struct {
+	Line, Column int
+	Message      string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+// LintMermaid renders the mermaid diagrams of a document like
+// RenderMermaidBlocks does, and returns the errors of those that fail, at
+// their position in the document. Errors that aren't about a line of a
+// diagram are at its opening fence.
+func LintMermaid(ctx context.Context, content string, mode string, maxWidth int) []Diagnostic {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	blocks := findFencedBlocks(lines, func(language string) bool {
+		return language == "mermaid"
+	})
+
+	r := mermaidRenderer{ascii: strings.EqualFold(mode, "ascii")}
+	var diagnostics []Diagnostic
+	for _, block := range blocks {
+		_, err := renderBlockContext(ctx, r, block.content, block.width(maxWidth))
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			continue
+		}
+
+		// The fence, and the lines of the diagram after it, from 1.
+		d := Diagnostic{Line: block.startLine + 1, Column: len(block.prefix) + 1, Message: err.Error()}
+		var de *DiagramError
+		if errors.As(err, &de) {
+			d.Message = de.Err.Error()
+			if de.Line > 0 {
+				d.Line += de.Line
+				d.Column = len(block.indentPrefix) + de.Column
+			}
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+// MermaidDiagram is a mermaid diagram of a document, and the line of its
+// rendering the diagram starts at, from 0, or -1 if it wasn't found.
+type MermaidDiagram struct {
+	Source string
+	Line   int
+}
+
+// LocateMermaid finds the mermaid diagrams of content in out, its rendering
+// after its diagrams were rendered by RenderMermaidBlocksExcept with the same
+// arguments, by the text each diagram was replaced with.
+func LocateMermaid(content, out, mode string, maxWidth int, keep func(source string) bool) []MermaidDiagram {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	blocks := findFencedBlocks(lines, func(language string) bool {
+		return language == "mermaid"
+	})
+	outLines := strings.Split(ansi.Strip(out), "\n")
+
+	diagrams := make([]MermaidDiagram, 0, len(blocks))
+	next := 0
+	for _, block := range blocks {
+		// Diagrams that failed, or are still rendering after timing out, are
+		// shown as source too.
+		text := block.content
+		if !strings.EqualFold(mode, "raw") && (keep == nil || !keep(block.content)) {
+			key := mermaidKey(block.content, strings.EqualFold(mode, "ascii"), block.width(maxWidth))
+			if res, ok := mermaidResults.Load(key); ok && res.err == nil {
+				text = res.out
+			}
+		}
+
+		d := MermaidDiagram{Source: block.content, Line: findLines(outLines, strings.Split(strings.Trim(text, "\n"), "\n"), next)}
+		if d.Line >= 0 {
+			next = d.Line + 1
+		}
+		diagrams = append(diagrams, d)
+	}
+	return diagrams
+}
+
+// findLines returns the first line of lines from which they contain the
+// text of each of want, trimmed, at or after line from, or -1.
+func findLines(lines, want []string, from int) int {
+	for i := from; i+len(want) <= len(lines); i++ {
+		found := true
+		for j, w := range want {
+			if !strings.Contains(lines[i+j], strings.TrimSpace(w)) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return i
+		}
+	}
+	return -1
 }
 
 // parseFenceLine checks if a line is a fence line.
@@ -171,54 +465,3 @@ func parseFenceLine(line string) (indent string, char rune, length int, info str
 
 	return indent, firstChar, fenceCount, info
 }
-
-// renderMermaidBlock renders a mermaid block to ASCII and returns replacement lines.
-func renderMermaidBlock(block fencedBlock, maxWidth int, useAscii bool) []string {
-	availableWidth := maxWidth
-	if availableWidth > 0 {
-		availableWidth -= len(block.indentPrefix)
-		const codeBlockMargin = 4
-		if availableWidth > codeBlockMargin {
-			availableWidth -= codeBlockMargin
-		} else {
-			availableWidth = 0
-		}
-	}
-	options := []mermaidcmd.RenderOption{mermaidcmd.WithMaxWidth(availableWidth)}
-	if useAscii {
-		options = append(options, mermaidcmd.WithAscii())
-	}
-	rendered, err := mermaidcmd.RenderDiagramWithOptions(block.content, options...)
-	if err != nil {
-		// On error, show visible error message and keep original block
-		var result []string
-		result = append(result, block.indentPrefix+"```")
-		result = append(result, block.indentPrefix+"mermaid render error: "+err.Error())
-		result = append(result, block.indentPrefix+"```")
-		result = append(result, block.indentPrefix+strings.Repeat(string(block.fenceChar), block.fenceLen)+block.infoString)
-		for _, line := range strings.Split(block.content, "\n") {
-			result = append(result, block.indentPrefix+line)
-		}
-		result = append(result, block.indentPrefix+strings.Repeat(string(block.fenceChar), block.fenceLen))
-		return result
-	}
-
-	// Wrap rendered output in a plain code block, preserving indentation
-	rendered = strings.TrimRight(rendered, "\n\r\t ")
-	var result []string
-	result = append(result, block.indentPrefix+"```")
-	for _, line := range strings.Split(rendered, "\n") {
-		result = append(result, block.indentPrefix+line)
-	}
-	result = append(result, block.indentPrefix+"```")
-	return result
-}
-
-// replaceLines replaces lines[start:end+1] with newLines.
-func replaceLines(lines []string, start, end int, newLines []string) []string {
-	result := make([]string, 0, len(lines)-end+start-1+len(newLines))
-	result = append(result, lines[:start]...)
-	result = append(result, newLines...)
-	result = append(result, lines[end+1:]...)
-	return result
-}
@@ -1,8 +1,17 @@
 package utils
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	mermaidcmd "github.com/AlexanderGrooff/mermaid-ascii/cmd"
+	"github.com/charmbracelet/x/ansi"
 )
 
 func TestRenderMermaidBlocks_RawMode(t *testing.T) {
@@ -247,3 +256,166 @@ func maxLineWidth(input string) int {
 	}
 	return maxWidth
 }
+
+func TestLintMermaid(t *testing.T) {
+	doc := "# Title\n\n```mermaid\ngraph LR\nA --> B\n```\n\n" +
+		"```mermaid\nsequenceDiagram\n%% A comment\nA->>B: hi\n\n  foo bar\n```\n\n" +
+		"- Item\n\n  ```mermaid\n  pie\n  \"a\": 1\n  ```\n\n" +
+		"```mermaid\n```\n\n" +
+		"> [!NOTE]\n> - ```mermaid\n>   sequenceDiagram\n>   A->>B: hi\n>    foo bar\n>   ```\n"
+	got := LintMermaid(context.Background(), doc, "unicode", 80)
+	want := []Diagnostic{
+		{13, 3, `failed to parse sequence diagram: invalid syntax: "foo bar"`},
+		{19, 3, "failed to parse graph diagram: unsupported graph type 'pie'. Supported types: graph TD, graph TB, graph LR, flowchart TD, flowchart TB, flowchart LR"},
+		{23, 1, "failed to parse graph diagram: missing graph definition"},
+		{30, 6, `failed to parse sequence diagram: invalid syntax: "foo bar"`},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("LintMermaid() =\n%v\nwant\n%v", got, want)
+	}
+
+	// Rendered errors have the position in the diagram.
+	if got := RenderMermaidBlocks(doc, "unicode", 80); !strings.Contains(got, `mermaid render error: line 5, column 3: failed to parse sequence diagram: invalid syntax: "foo bar"`) {
+		t.Errorf("RenderMermaidBlocks() =\n%s\nwant the position of the error", got)
+	}
+}
+
+func TestLocateMermaid(t *testing.T) {
+	doc := "# Title\n\n```mermaid\ngraph LR\nA --> B\n```\n\nText\n\n" +
+		"```mermaid\ngraph LR\nC --> D\n```\n\n```mermaid\ngraph LR\nA --> B\n```\n"
+	keep := func(source string) bool { return strings.Contains(source, "C --> D") }
+
+	md, _ := RenderMermaidBlocksExcept(context.Background(), doc, "ascii", 80, keep)
+	if !strings.Contains(md, "```mermaid\ngraph LR\nC --> D\n```") {
+		t.Fatalf("RenderMermaidBlocksExcept() didn't keep the source:\n%s", md)
+	}
+	if strings.Count(md, "```mermaid") != 1 {
+		t.Fatalf("RenderMermaidBlocksExcept() kept other sources:\n%s", md)
+	}
+
+	// The rendering is indented, like glamour's.
+	out := "\n  \x1b[1m# Title\x1b[0m\n\n" + strings.ReplaceAll("  "+md, "\n", "\n  ")
+	lines := strings.Split(ansi.Strip(out), "\n")
+	got := LocateMermaid(doc, out, "ascii", 80, keep)
+	if len(got) != 3 {
+		t.Fatalf("LocateMermaid() found %d diagrams, want 3", len(got))
+	}
+	for i, want := range []string{"+---+", "graph LR", "+---+"} {
+		if got[i].Line < 0 || !strings.Contains(lines[got[i].Line], want) {
+			t.Errorf("LocateMermaid()[%d] at line %d, want a line with %q:\n%s", i, got[i].Line, want, out)
+		}
+	}
+	if got[0].Line == got[2].Line {
+		t.Errorf("LocateMermaid() found the same diagram twice at line %d", got[0].Line)
+	}
+	if got[1].Source != "graph LR\nC --> D" {
+		t.Errorf("LocateMermaid()[1].Source = %q", got[1].Source)
+	}
+}
+
+// replaceRenderDiagram replaces the layout engine for a test, and forgets
+// the diagrams rendered before.
+func replaceRenderDiagram(t *testing.T, f func(string, ...mermaidcmd.RenderOption) (string, error)) {
+	t.Helper()
+	orig := renderDiagram
+	renderDiagram = f
+	mermaidResults.Clear()
+	t.Cleanup(func() {
+		renderDiagram = orig
+		mermaidResults.Clear()
+	})
+}
+
+func TestRenderMermaidBlocks_Panic(t *testing.T) {
+	replaceRenderDiagram(t, func(string, ...mermaidcmd.RenderOption) (string, error) {
+		var s []int
+		return fmt.Sprint(s[71]), nil
+	})
+
+	input := "```mermaid\ngraph LR\nA --> B\n```"
+	got := RenderMermaidBlocks(input, "unicode", 80)
+	for _, want := range []string{"mermaid render error: mermaid-ascii: runtime error: index out of range [71] with length 0", input} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderMermaidBlocks() =\n%s\nwant it to contain\n%s", got, want)
+		}
+	}
+}
+
+func TestRenderMermaidBlocks_Layouts(t *testing.T) {
+	timeout := DiagramTimeout
+	DiagramTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { DiagramTimeout = timeout })
+	var layouts atomic.Int32
+	release := make(chan struct{})
+	replaceRenderDiagram(t, func(string, ...mermaidcmd.RenderOption) (string, error) {
+		layouts.Add(1)
+		<-release
+		return "rendered", nil
+	})
+
+	// A diagram that times out is laid out once, and rendering it again
+	// doesn't wait for another timeout.
+	input := "```mermaid\ngraph LR\nA --> B\n```"
+	if got := RenderMermaidBlocks(input, "unicode", 80); !strings.Contains(got, "timed out") {
+		t.Fatalf("RenderMermaidBlocks() =\n%s\nwant a timeout", got)
+	}
+	start := time.Now()
+	if got := RenderMermaidBlocks(input, "unicode", 80); !strings.Contains(got, "timed out") {
+		t.Fatalf("RenderMermaidBlocks() =\n%s\nwant a timeout", got)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("rendering a diagram timed out again took %s", elapsed)
+	}
+	if n := layouts.Load(); n != 1 {
+		t.Errorf("the diagram was laid out %d times, want 1", n)
+	}
+
+	// Once the layout is done, the diagram is rendered.
+	close(release)
+	<-layoutMermaid("graph LR\nA --> B", false, 76).done
+	if got := RenderMermaidBlocks(input, "unicode", 80); !strings.Contains(got, "rendered") {
+		t.Errorf("RenderMermaidBlocks() =\n%s\nwant the diagram", got)
+	}
+}
+
+func TestRenderMermaidBlocksExcept_Errors(t *testing.T) {
+	timeout := DiagramTimeout
+	DiagramTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { DiagramTimeout = timeout })
+	release := make(chan struct{})
+	replaceRenderDiagram(t, func(content string, _ ...mermaidcmd.RenderOption) (string, error) {
+		switch {
+		case strings.Contains(content, "slow"):
+			<-release
+		case strings.Contains(content, "bad"):
+			return "", errors.New("invalid diagram")
+		}
+		return "rendered", nil
+	})
+
+	// Failed and timed out diagrams are counted, unless they're kept.
+	doc := "```mermaid\ngood\n```\n\n```mermaid\nbad\n```\n\n" +
+		"```mermaid\nslow\n```\n\n```mermaid\nbad kept\n```\n"
+	keep := func(source string) bool { return strings.Contains(source, "kept") }
+	if _, n := RenderMermaidBlocksExcept(context.Background(), doc, "unicode", 80, keep); n != 2 {
+		t.Errorf("RenderMermaidBlocksExcept() counted %d errors, want 2", n)
+	}
+	if _, n := RenderMermaidBlocksExcept(context.Background(), doc, "raw", 80, keep); n != 0 {
+		t.Errorf("RenderMermaidBlocksExcept() counted %d errors in raw mode, want 0", n)
+	}
+
+	close(release)
+	<-layoutMermaid("slow", false, 76).done
+}
+
+func TestMermaidResults_Bound(t *testing.T) {
+	c := resultCache{max: 2}
+	for _, key := range []string{"a", "b", "a", "c"} {
+		c.Store(key, renderResult{out: key})
+	}
+	for key, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		if _, ok := c.Load(key); ok != want {
+			t.Errorf("Load(%q) found = %v, want %v", key, ok, want)
+		}
+	}
+}
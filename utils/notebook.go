@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+// Image types of cell outputs that can be displayed, in order of preference.
+var notebookImageTypes = []string{"image/png", "image/jpeg", "image/gif"}
+
+// notebook is a Jupyter notebook in nbformat 4.
+type notebook struct {
+	Format   int `json:"nbformat"`
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		Type    string           `json:"cell_type"`
+		Source  multilineString  `json:"source"`
+		Outputs []notebookOutput `json:"outputs"`
+	} `json:"cells"`
+}
+
+type notebookOutput struct {
+	Type      string                     `json:"output_type"`
+	Text      multilineString            `json:"text"`
+	Data      map[string]json.RawMessage `json:"data"`
+	Name      string                     `json:"ename"`
+	Value     string                     `json:"evalue"`
+	Traceback []string                   `json:"traceback"`
+}
+
+// multilineString is a string that notebooks store either as is or split
+// into a list of lines.
+type multilineString string
+
+func (s *multilineString) UnmarshalJSON(b []byte) error {
+	var lines []string
+	if err := json.Unmarshal(b, &lines); err == nil {
+		*s = multilineString(strings.Join(lines, ""))
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(b, &str); err != nil {
+		return err //nolint:wrapcheck
+	}
+	*s = multilineString(str)
+	return nil
+}
+
+// IsNotebook returns whether the filename is a Jupyter notebook.
+func IsNotebook(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".ipynb")
+}
+
+// NotebookToMarkdown converts a Jupyter notebook to markdown. Markdown cells
+// are kept as they are and code cells become code blocks in the kernel's
+// language. Cell outputs follow their code unless outputs is false; image
+// outputs are inlined as data URIs if images is true and shown as a
+// placeholder otherwise.
+func NotebookToMarkdown(b []byte, outputs, images bool) (string, error) {
+	var nb notebook
+	if err := json.Unmarshal(b, &nb); err != nil {
+		return "", fmt.Errorf("unable to parse notebook: %w", err)
+	}
+	if nb.Format < 4 {
+		return "", errors.New("unsupported notebook format: must be nbformat 4")
+	}
+
+	language := cmp.Or(nb.Metadata.LanguageInfo.Name, nb.Metadata.Kernelspec.Language)
+	var blocks []string
+	for _, cell := range nb.Cells {
+		source := strings.TrimRight(string(cell.Source), "\n")
+		switch cell.Type {
+		case "markdown":
+			if source != "" {
+				blocks = append(blocks, source)
+			}
+		case "code":
+			if source != "" {
+				blocks = append(blocks, fenceCode(source, language))
+			}
+			if !outputs {
+				continue
+			}
+			for _, out := range cell.Outputs {
+				if block := out.markdown(images); block != "" {
+					blocks = append(blocks, block)
+				}
+			}
+		default:
+			// Raw cells are meant for other tools, such as nbconvert.
+			if source != "" {
+				blocks = append(blocks, fenceCode(source, "text"))
+			}
+		}
+	}
+	return strings.Join(blocks, "\n\n") + "\n", nil
+}
+
+// markdown returns a cell output as markdown.
+func (o notebookOutput) markdown(images bool) string {
+	switch o.Type {
+	case "stream":
+		return fenceCode(strings.TrimRight(string(o.Text), "\n"), "text")
+	case "error":
+		lines := make([]string, 0, len(o.Traceback))
+		for _, l := range o.Traceback {
+			lines = append(lines, xansi.Strip(l))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, o.Name+": "+o.Value)
+		}
+		return fenceCode(strings.TrimRight(strings.Join(lines, "\n"), "\n"), "text")
+	case "execute_result", "display_data":
+		for _, typ := range notebookImageTypes {
+			data, ok := o.text(typ)
+			if !ok {
+				continue
+			}
+			if !images {
+				return outputPlaceholder(typ)
+			}
+			return "![" + typ + " output](data:" + typ + ";base64," + strings.Join(strings.Fields(data), "") + ")"
+		}
+		if md, ok := o.text("text/markdown"); ok {
+			return strings.TrimRight(md, "\n")
+		}
+		if text, ok := o.text("text/plain"); ok {
+			return fenceCode(strings.TrimRight(text, "\n"), "text")
+		}
+		if types := slices.Sorted(maps.Keys(o.Data)); len(types) > 0 {
+			return outputPlaceholder(types[0])
+		}
+	}
+	return ""
+}
+
+// text returns the output data of the given mime type, if it is text.
+func (o notebookOutput) text(typ string) (string, bool) {
+	raw, ok := o.Data[typ]
+	if !ok {
+		return "", false
+	}
+	var s multilineString
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", false
+	}
+	return string(s), true
+}
+
+// outputPlaceholder stands in for an output that can't be displayed.
+func outputPlaceholder(typ string) string {
+	return `*\[` + typ + ` output\]*`
+}
+
+// fenceCode wraps code in a fenced code block that is longer than any run
+// of backticks in the code.
+func fenceCode(code, language string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + language + "\n" + code + "\n" + fence
+}
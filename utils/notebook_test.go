@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+ "cells": [
+  {"cell_type": "markdown", "metadata": {}, "source": ["# Analysis\n", "\n", "Some notes."]},
+  {"cell_type": "code", "metadata": {}, "source": ["print(1)\n", "2"], "outputs": [
+   {"output_type": "stream", "name": "stdout", "text": ["1\n"]},
+   {"output_type": "execute_result", "metadata": {}, "data": {"text/plain": ["2"], "application/json": {"a": 1}}}
+  ]},
+  {"cell_type": "code", "metadata": {}, "source": "1/0", "outputs": [
+   {"output_type": "error", "ename": "ZeroDivisionError", "evalue": "division by zero", "traceback": ["\u001b[31mZeroDivisionError\u001b[0m: division by zero"]}
+  ]},
+  {"cell_type": "code", "metadata": {}, "source": "plot()", "outputs": [
+   {"output_type": "display_data", "metadata": {}, "data": {"image/png": "iVBORw0K\nGgo=\n", "text/plain": ["<Figure>"]}}
+  ]}
+ ],
+ "metadata": {"kernelspec": {"language": "python", "name": "python3"}},
+ "nbformat": 4,
+ "nbformat_minor": 5
+}`
+
+func TestNotebookToMarkdown(t *testing.T) {
+	got, err := NotebookToMarkdown([]byte(testNotebook), true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Analysis\n\nSome notes.\n\n" +
+		"```python\nprint(1)\n2\n```\n\n" +
+		"```text\n1\n```\n\n" +
+		"```text\n2\n```\n\n" +
+		"```python\n1/0\n```\n\n" +
+		"```text\nZeroDivisionError: division by zero\n```\n\n" +
+		"```python\nplot()\n```\n\n" +
+		"![image/png output](data:image/png;base64,iVBORw0KGgo=)\n"
+	if got != want {
+		t.Errorf("NotebookToMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNotebookToMarkdown_Options(t *testing.T) {
+	got, err := NotebookToMarkdown([]byte(testNotebook), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(got, "*\\[image/png output\\]*\n") {
+		t.Errorf("expected an image placeholder, got:\n%s", got)
+	}
+
+	got, err = NotebookToMarkdown([]byte(testNotebook), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "```text") || strings.Contains(got, "image/png") {
+		t.Errorf("expected outputs to be hidden, got:\n%s", got)
+	}
+}
+
+func TestNotebookToMarkdown_Invalid(t *testing.T) {
+	if _, err := NotebookToMarkdown([]byte(`{"nbformat": 3, "worksheets": []}`), true, true); err == nil {
+		t.Error("expected an error for nbformat 3")
+	}
+	if _, err := NotebookToMarkdown([]byte(`not json`), true, true); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
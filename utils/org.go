@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	orgHeading   = regexp.MustCompile(`^(\*+)\s+(.*?)(?:\s+(:[\w@#%:]+:))?\s*$`)
+	orgKeyword   = regexp.MustCompile(`^\s*#\+(\w+):\s*(.*)$`)
+	orgBegin     = regexp.MustCompile(`(?i)^(\s*)#\+begin_(\w+)\s*(.*)$`)
+	orgList      = regexp.MustCompile(`^(\s*)(?:[-+]|\*|\d+[.)])\s+`)
+	orgTableRule = regexp.MustCompile(`^\s*\|[-+]+\|?\s*$`)
+	orgLink      = regexp.MustCompile(`\[\[([^\]]+)\](?:\[([^\]]+)\])?\]`)
+	orgImageExt  = regexp.MustCompile(`(?i)\.(?:png|jpe?g|gif|svg|webp)$`)
+)
+
+// Emphasis markers and their markdown equivalents, in the order they are
+// converted. Code comes first so that its contents are left alone.
+var orgMarkers = []struct {
+	pattern *regexp.Regexp
+	md      string
+}{
+	{orgEmphasis("="), "`"},
+	{orgEmphasis("~"), "`"},
+	{orgEmphasis("*"), "**"},
+	{orgEmphasis("/"), "*"},
+	{orgEmphasis("_"), "*"},
+	{orgEmphasis("+"), "~~"},
+}
+
+// orgEmphasis returns a pattern matching text between two markers.
+func orgEmphasis(marker string) *regexp.Regexp {
+	q := regexp.QuoteMeta(marker)
+	return regexp.MustCompile(`(^|[\s({'"-])` + q + `([^\s` + q + `](?:[^` + q + `]*?[^\s])?)` + q + `([\s)}'".,:;!?-]|$)`)
+}
+
+// Special blocks shown as callouts, like #+begin_note.
+var orgCallouts = []string{"note", "tip", "important", "warning", "caution", "danger", "info"}
+
+// orgToMarkdown converts Org to markdown.
+func orgToMarkdown(content string) string {
+	lines := strings.Split(content, "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case orgBegin.MatchString(line):
+			m := orgBegin.FindStringSubmatch(line)
+			indent, kind, args := m[1], strings.ToLower(m[2]), m[3]
+			end := orgBlockEnd(lines, i, kind)
+			body := lines[i+1 : end]
+			switch {
+			case kind == "src":
+				language, _, _ := strings.Cut(args, " ")
+				out = append(out, codeLines(orgUnescape(dedent(body)), language, indent)...)
+			case kind == "example":
+				out = append(out, codeLines(orgUnescape(dedent(body)), "", indent)...)
+			case kind == "quote":
+				for _, l := range orgBody(body) {
+					out = append(out, strings.TrimRight(indent+"> "+l, " "))
+				}
+			case kind == "comment":
+			case slices.Contains(orgCallouts, kind):
+				out = append(out, calloutLines(kind, "", orgBody(body), indent)...)
+			default:
+				out = append(out, orgBody(body)...)
+			}
+			i = end
+
+		case strings.HasPrefix(trimmed, ":PROPERTIES:") || trimmed == ":LOGBOOK:":
+			// Drawers hold metadata.
+			for i+1 < len(lines) && strings.TrimSpace(lines[i]) != ":END:" {
+				i++
+			}
+
+		case orgKeyword.MatchString(line):
+			m := orgKeyword.FindStringSubmatch(line)
+			if strings.EqualFold(m[1], "title") {
+				out = append(out, "# "+orgInline(m[2]))
+			}
+
+		case trimmed == "#" || strings.HasPrefix(trimmed, "# "):
+			// Comment.
+
+		case strings.HasPrefix(trimmed, "|"):
+			end := i
+			for end+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[end+1]), "|") {
+				end++
+			}
+			out = append(out, pipeTable(orgTableRows(lines[i:end+1]), line[:leadingSpaces(line)])...)
+			i = end
+
+		case trimmed == ":" || strings.HasPrefix(trimmed, ": "):
+			// Fixed-width lines.
+			var code []string
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if t != ":" && !strings.HasPrefix(t, ": ") {
+					break
+				}
+				code = append(code, strings.TrimPrefix(strings.TrimPrefix(t, ":"), " "))
+			}
+			i--
+			out = append(out, codeLines(code, "", line[:leadingSpaces(line)])...)
+
+		case orgHeading.MatchString(line):
+			m := orgHeading.FindStringSubmatch(line)
+			out = append(out, strings.Repeat("#", min(len(m[1]), 6))+" "+orgInline(m[2]))
+
+		case len(trimmed) >= 5 && strings.Trim(trimmed, "-") == "":
+			out = append(out, "---")
+
+		default:
+			if m := orgList.FindStringSubmatch(line); m != nil {
+				marker := "- "
+				if c := strings.TrimSpace(line[len(m[1]):len(m[0])]); c[0] >= '0' && c[0] <= '9' {
+					marker = "1. "
+				}
+				line = m[1] + marker + line[len(m[0]):]
+			}
+			out = append(out, orgInline(line))
+		}
+	}
+	return strings.Join(trimBlankEdges(out), "\n") + "\n"
+}
+
+// orgBlockEnd returns the line that closes the block of the given kind opened
+// at line i, or the last line if it isn't closed.
+func orgBlockEnd(lines []string, i int, kind string) int {
+	for j := i + 1; j < len(lines); j++ {
+		if strings.EqualFold(strings.TrimSpace(lines[j]), "#+end_"+kind) {
+			return j
+		}
+	}
+	return len(lines) - 1
+}
+
+// orgBody converts the contents of a block.
+func orgBody(lines []string) []string {
+	md := orgToMarkdown(strings.Join(dedent(lines), "\n"))
+	return strings.Split(strings.TrimSuffix(md, "\n"), "\n")
+}
+
+// orgUnescape removes the commas that escape lines starting with * or #+ in
+// code blocks.
+func orgUnescape(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		t := strings.TrimLeft(l, " ")
+		if strings.HasPrefix(t, ",*") || strings.HasPrefix(t, ",#+") {
+			l = l[:len(l)-len(t)] + t[1:]
+		}
+		out[i] = l
+	}
+	return out
+}
+
+// orgTableRows parses the rows of a table, leaving out horizontal rules.
+func orgTableRows(lines []string) [][]string {
+	var rows [][]string
+	for _, line := range lines {
+		if orgTableRule.MatchString(line) {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+		cells := strings.Split(line, "|")
+		for i, cell := range cells {
+			cells[i] = orgInline(strings.TrimSpace(cell))
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+// orgInline converts inline markup: links and emphasis.
+func orgInline(s string) string {
+	s = orgLink.ReplaceAllStringFunc(s, func(match string) string {
+		m := orgLink.FindStringSubmatch(match)
+		target, desc := strings.TrimPrefix(m[1], "file:"), m[2]
+		if desc == "" && orgImageExt.MatchString(target) {
+			return "![](" + target + ")"
+		}
+		if desc == "" {
+			desc = target
+		}
+		return "[" + desc + "](" + target + ")"
+	})
+
+	for _, m := range orgMarkers {
+		// Twice, since adjacent matches share the whitespace between them.
+		for range 2 {
+			s = m.pattern.ReplaceAllString(s, "${1}"+m.md+"${2}"+m.md+"${3}")
+		}
+	}
+	return s
+}
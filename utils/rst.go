@@ -0,0 +1,450 @@
+package utils
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	rstTarget      = regexp.MustCompile(`^\s*\.\. _([^:]+):\s*(\S*)\s*$`)
+	rstDirective   = regexp.MustCompile(`^(\s*)\.\.\s+([\w:-]+)::\s*(.*)$`)
+	rstOption      = regexp.MustCompile(`^:([\w-]+):\s*(.*)$`)
+	rstGridBorder  = regexp.MustCompile(`^\s*\+[-=+]+\+\s*$`)
+	rstSimpleTable = regexp.MustCompile(`^\s*=+(?: +=+)+\s*$`)
+	rstBullet      = regexp.MustCompile(`^(\s*)[*+•-]\s+`)
+	rstEnumerated  = regexp.MustCompile(`^(\s*)(?:\(?(?:\d+|#)\)|(?:\d+|#)\.)\s+`)
+	rstInline      = regexp.MustCompile("``(.+?)``|(?::([\\w:-]+):)?`([^`]+)`(__?)?")
+	rstSimpleRef   = regexp.MustCompile(`(^|[\s(])([A-Za-z][\w-]*)__?([\s.,;:!?)]|$)`)
+	rstLabel       = regexp.MustCompile(`^(.*?)\s*<([^<>]+)>$`)
+)
+
+// Roles whose content is code.
+var rstCodeRoles = []string{"code", "literal", "file", "command", "program", "kbd", "samp", "envvar", "option", "mimetype"}
+
+// Directives without content worth showing.
+var rstHiddenDirectives = []string{
+	"toctree", "contents", "sectnum", "meta", "raw", "include", "highlight",
+	"default-role", "role", "index", "target-notes", "header", "footer", "title",
+}
+
+// Adornment characters of section titles.
+const rstAdornments = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+type rstConverter struct {
+	targets map[string]string // link targets by lowercase name
+	levels  []string          // section title styles, in order of appearance
+}
+
+// rstToMarkdown converts reStructuredText to markdown.
+func rstToMarkdown(content string) string {
+	c := &rstConverter{targets: map[string]string{}}
+	var (
+		lines   []string
+		removed bool
+	)
+	for _, l := range strings.Split(strings.ReplaceAll(content, "\t", "        "), "\n") {
+		l = strings.TrimRight(l, " ")
+		if m := rstTarget.FindStringSubmatch(l); m != nil {
+			c.targets[strings.ToLower(m[1])] = m[2]
+			removed = true
+			continue
+		}
+		// Don't leave a double blank line where a target was.
+		if removed && l == "" && len(lines) > 0 && lines[len(lines)-1] == "" {
+			continue
+		}
+		removed = false
+		lines = append(lines, l)
+	}
+	return strings.Join(trimBlankEdges(c.convert(lines)), "\n") + "\n"
+}
+
+func (c *rstConverter) convert(lines []string) []string {
+	var (
+		out        []string
+		listIndent = -1 // content indentation of the current list item
+	)
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		indent := leadingSpaces(line)
+		prefix := line[:indent]
+
+		switch {
+		case trimmed == "":
+			out = append(out, "")
+
+		case rstGridBorder.MatchString(line):
+			rows, end := rstGridTable(lines, i)
+			out = append(out, c.table(rows, prefix)...)
+			i = end
+
+		case rstSimpleTable.MatchString(line):
+			rows, end := rstSimpleTableRows(lines, i)
+			out = append(out, c.table(rows, prefix)...)
+			i = end
+
+		case strings.HasPrefix(trimmed, ".."):
+			end, md := c.directive(lines, i)
+			out = append(out, md...)
+			i = end
+
+		case c.isTransition(lines, i):
+			out = append(out, "---")
+
+		case indent == 0 && i+2 < len(lines) && rstAdornment(line) != 0 &&
+			rstAdornment(lines[i+2]) == rstAdornment(line) && strings.TrimSpace(lines[i+1]) != "":
+			// Section title with overline.
+			out = append(out, c.heading(string(rstAdornment(line))+"^", strings.TrimSpace(lines[i+1])))
+			i += 2
+
+		case indent == 0 && i+1 < len(lines) && rstAdornment(lines[i+1]) != 0 &&
+			len(strings.TrimSpace(lines[i+1])) >= len(trimmed):
+			out = append(out, c.heading(string(rstAdornment(lines[i+1])), trimmed))
+			i++
+
+		case strings.HasSuffix(trimmed, "::"):
+			// A paragraph ending in :: introduces a literal block.
+			text := strings.TrimSuffix(strings.TrimSuffix(line, "::"), " ")
+			if strings.HasSuffix(line, " ::") || trimmed == "::" {
+				text = strings.TrimRight(text, " ")
+			} else {
+				text += ":"
+			}
+			if strings.TrimSpace(text) != "" {
+				out = append(out, c.inline(rstListItem(text)))
+			}
+			end, body := indentedBlock(lines, i+1, indent)
+			if len(body) > 0 {
+				out = append(out, "")
+				out = append(out, codeLines(dedent(body), "", prefix)...)
+			}
+			i = end
+
+		case indent > 0 && (listIndent < 0 || indent < listIndent) && (i == 0 || strings.TrimSpace(lines[i-1]) == ""):
+			// An indented paragraph outside of lists is a block quote.
+			end, body := indentedBlock(lines, i, indent-1)
+			for _, l := range c.convert(dedent(body)) {
+				out = append(out, strings.TrimRight("> "+l, " "))
+			}
+			i = end
+
+		case indent == 0 && i+1 < len(lines) && leadingSpaces(lines[i+1]) > 0 &&
+			strings.TrimSpace(lines[i+1]) != "" && !rstBullet.MatchString(line) && !rstEnumerated.MatchString(line):
+			// Definition list item.
+			end, body := indentedBlock(lines, i+1, 0)
+			out = append(out, "**"+c.inline(trimmed)+"**", "")
+			out = append(out, c.convert(dedent(body))...)
+			i = end
+
+		default:
+			if m := rstBullet.FindString(line); m != "" {
+				listIndent = len(m)
+			} else if m := rstEnumerated.FindString(line); m != "" {
+				listIndent = len(m)
+			} else if indent == 0 {
+				listIndent = -1
+			}
+			out = append(out, c.inline(rstListItem(line)))
+		}
+	}
+	return out
+}
+
+// isTransition returns whether line i is a transition between sections: an
+// adornment line on its own.
+func (c *rstConverter) isTransition(lines []string, i int) bool {
+	line := lines[i]
+	return rstAdornment(line) != 0 && len(line) >= 4 &&
+		(i == 0 || strings.TrimSpace(lines[i-1]) == "") &&
+		(i+1 == len(lines) || strings.TrimSpace(lines[i+1]) == "")
+}
+
+// heading returns a markdown heading for a section title. Levels follow the
+// order in which title styles first appear.
+func (c *rstConverter) heading(style, title string) string {
+	level := slices.Index(c.levels, style)
+	if level < 0 {
+		c.levels = append(c.levels, style)
+		level = len(c.levels) - 1
+	}
+	return strings.Repeat("#", min(level+1, 6)) + " " + c.inline(title)
+}
+
+func (c *rstConverter) table(rows [][]string, indent string) []string {
+	for _, row := range rows {
+		for i := range row {
+			row[i] = c.inline(row[i])
+		}
+	}
+	return pipeTable(rows, indent)
+}
+
+// directive converts an explicit markup block starting at line i: a
+// directive or a comment. It returns the last line of the block.
+func (c *rstConverter) directive(lines []string, i int) (int, []string) {
+	indent := leadingSpaces(lines[i])
+	end, body := indentedBlock(lines, i+1, indent)
+	m := rstDirective.FindStringSubmatch(lines[i])
+	if m == nil {
+		// Comments and substitution definitions aren't shown.
+		return end, nil
+	}
+	prefix, name, arg := m[1], strings.ToLower(m[2]), m[3]
+
+	// Options come first in the body.
+	body = dedent(body)
+	options := map[string]string{}
+	for len(body) > 0 {
+		o := rstOption.FindStringSubmatch(body[0])
+		if o == nil {
+			break
+		}
+		options[o[1]] = o[2]
+		body = body[1:]
+	}
+
+	var md []string
+	switch name {
+	case "code-block", "code", "sourcecode":
+		md = codeLines(body, arg, prefix)
+	case "image":
+		md = []string{prefix + "![" + options["alt"] + "](" + arg + ")"}
+	case "figure":
+		md = []string{prefix + "![" + options["alt"] + "](" + arg + ")", ""}
+		for _, l := range c.convert(body) {
+			md = append(md, prefix+l)
+		}
+	case "math":
+		md = append(md, prefix+"$$")
+		for _, l := range trimBlankEdges(append([]string{arg}, body...)) {
+			md = append(md, prefix+l)
+		}
+		md = append(md, prefix+"$$")
+	case "admonition":
+		md = calloutLines("note", c.inline(arg), c.convert(body), prefix)
+	case "seealso":
+		md = calloutLines("note", "See also", c.convert(append([]string{arg}, body...)), prefix)
+	case "note", "warning", "tip", "important", "caution", "danger", "attention", "hint", "error", "todo":
+		md = calloutLines(name, "", c.convert(append([]string{arg}, body...)), prefix)
+	case "rubric", "topic", "sidebar":
+		md = []string{prefix + "**" + c.inline(arg) + "**", ""}
+		for _, l := range c.convert(body) {
+			md = append(md, prefix+l)
+		}
+	default:
+		if slices.Contains(rstHiddenDirectives, name) {
+			return end, nil
+		}
+		for _, l := range c.convert(body) {
+			md = append(md, prefix+l)
+		}
+	}
+	return end, md
+}
+
+// inline converts inline markup: literals, links, references and roles.
+// Strong and emphasized text are written the same way in markdown.
+func (c *rstConverter) inline(s string) string {
+	s = rstInline.ReplaceAllStringFunc(s, func(match string) string {
+		m := rstInline.FindStringSubmatch(match)
+		literal, role, text, ref := m[1], m[2], m[3], m[4]
+		switch {
+		case literal != "":
+			return "`" + literal + "`"
+		case ref != "":
+			label, target := text, ""
+			if l := rstLabel.FindStringSubmatch(text); l != nil {
+				label, target = l[1], l[2]
+				if t, ok := strings.CutSuffix(target, "_"); ok {
+					target = c.targets[strings.ToLower(t)]
+				}
+			} else {
+				target = c.targets[strings.ToLower(text)]
+			}
+			if target == "" {
+				return label
+			}
+			return "[" + cmp.Or(label, target) + "](" + target + ")"
+		case role == "math":
+			return "$" + text + "$"
+		case slices.Contains(rstCodeRoles, role):
+			return "`" + text + "`"
+		case role != "":
+			if l := rstLabel.FindStringSubmatch(text); l != nil && l[1] != "" {
+				return l[1]
+			}
+			return strings.Trim(text, "<>~")
+		default:
+			return "*" + text + "*"
+		}
+	})
+	if len(c.targets) == 0 {
+		return s
+	}
+	return rstSimpleRef.ReplaceAllStringFunc(s, func(match string) string {
+		m := rstSimpleRef.FindStringSubmatch(match)
+		target, ok := c.targets[strings.ToLower(m[2])]
+		if !ok {
+			return match
+		}
+		return m[1] + "[" + m[2] + "](" + target + ")" + m[3]
+	})
+}
+
+// rstListItem turns reStructuredText list markers into markdown ones.
+func rstListItem(line string) string {
+	if m := rstBullet.FindStringSubmatch(line); m != nil {
+		return m[1] + "- " + line[len(m[0]):]
+	}
+	if m := rstEnumerated.FindStringSubmatch(line); m != nil {
+		return m[1] + "1. " + line[len(m[0]):]
+	}
+	return line
+}
+
+// rstAdornment returns the character a line of section title adornment
+// consists of, or 0 if it isn't one.
+func rstAdornment(line string) rune {
+	line = strings.TrimRight(line, " ")
+	if len(line) < 2 || !strings.ContainsRune(rstAdornments, rune(line[0])) {
+		return 0
+	}
+	if strings.Count(line, line[:1]) != len(line) {
+		return 0
+	}
+	return rune(line[0])
+}
+
+// indentedBlock collects the lines from line i on that are indented more
+// than indent, along with blank lines between them. It returns the last line
+// of the block.
+func indentedBlock(lines []string, i, indent int) (int, []string) {
+	end := i - 1
+	for j := i; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) == "" {
+			continue
+		}
+		if leadingSpaces(lines[j]) <= indent {
+			break
+		}
+		end = j
+	}
+	if end < i {
+		return i - 1, nil
+	}
+	return end, lines[i : end+1]
+}
+
+// rstGridTable parses a grid table starting at line i. It returns the rows,
+// with the header first, and the last line of the table.
+func rstGridTable(lines []string, i int) ([][]string, int) {
+	indent := leadingSpaces(lines[i])
+	border := lines[i][indent:]
+	var bounds []int
+	for j, ch := range border {
+		if ch == '+' {
+			bounds = append(bounds, j)
+		}
+	}
+
+	var (
+		rows [][]string
+		row  []string
+		end  = i
+	)
+	for j := i + 1; j < len(lines); j++ {
+		line := lines[j]
+		if len(line) < indent || strings.TrimSpace(line) == "" {
+			break
+		}
+		line = line[indent:]
+		if rstGridBorder.MatchString(line) {
+			if row != nil {
+				rows = append(rows, row)
+				row = nil
+			}
+			end = j
+			continue
+		}
+		if !strings.HasPrefix(line, "|") {
+			break
+		}
+		if row == nil {
+			row = make([]string, len(bounds)-1)
+		}
+		for k := 0; k+1 < len(bounds); k++ {
+			from, to := bounds[k]+1, min(bounds[k+1], len(line))
+			if from >= to {
+				continue
+			}
+			if cell := strings.TrimSpace(line[from:to]); cell != "" {
+				row[k] = strings.TrimSpace(row[k] + " " + cell)
+			}
+		}
+	}
+	return rows, end
+}
+
+// rstSimpleTableRows parses a simple table starting at line i. It returns
+// the rows, with the header first, and the last line of the table.
+func rstSimpleTableRows(lines []string, i int) ([][]string, int) {
+	indent := leadingSpaces(lines[i])
+	border := lines[i][indent:]
+	var starts []int
+	for j := range border {
+		if border[j] == '=' && (j == 0 || border[j-1] == ' ') {
+			starts = append(starts, j)
+		}
+	}
+
+	var (
+		rows    [][]string
+		borders = 1
+		end     = i
+	)
+	for j := i + 1; j < len(lines); j++ {
+		line := lines[j]
+		if rstSimpleTable.MatchString(line) {
+			borders++
+			end = j
+			// The table ends with the third border, or with the second if
+			// it has no header.
+			if borders == 3 || j+1 == len(lines) || strings.TrimSpace(lines[j+1]) == "" {
+				break
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(line) > indent {
+			line = line[indent:]
+		}
+		cells := make([]string, len(starts))
+		for k, start := range starts {
+			if start >= len(line) {
+				break
+			}
+			to := len(line)
+			if k+1 < len(starts) {
+				to = min(starts[k+1], len(line))
+			}
+			cells[k] = strings.TrimSpace(line[start:to])
+		}
+		// Rows without a first cell continue the previous row.
+		if cells[0] == "" && len(rows) > 0 {
+			prev := rows[len(rows)-1]
+			for k, cell := range cells {
+				if cell != "" {
+					prev[k] = strings.TrimSpace(prev[k] + " " + cell)
+				}
+			}
+			continue
+		}
+		rows = append(rows, cells)
+	}
+	return rows, end
+}
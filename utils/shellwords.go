@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// ShellWords splits a command line into words the way a POSIX shell does:
+// words are separated by unquoted whitespace, single quotes keep everything
+// until the closing quote, double quotes keep everything but backslash
+// escapes of ", \, $ and `, and a backslash outside quotes escapes the next
+// character. Variables and globs aren't expanded.
+func ShellWords(s string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		// inWord is whether a word was started, so that "" is a word.
+		inWord bool
+	)
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			inWord = true
+			if i+1 < len(runes) {
+				i++
+				// A backslash before a newline continues the line.
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+				}
+			}
+		case r == '\'':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '\''; i++ {
+				word.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, errors.New("unterminated single quote")
+			}
+		case r == '"':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, errors.New("unterminated double quote")
+			}
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestShellWords(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"less -r", []string{"less", "-r"}},
+		{"  less   -R\t--mouse ", []string{"less", "-R", "--mouse"}},
+		{`"/opt/my pager/bin/less" -R`, []string{"/opt/my pager/bin/less", "-R"}},
+		{`less '--prompt=a b' -R`, []string{"less", "--prompt=a b", "-R"}},
+		{`/opt/my\ pager -R`, []string{"/opt/my pager", "-R"}},
+		{`a"b c"'d e'f`, []string{"ab cd ef"}},
+		{`'\"' "" ''`, []string{`\"`, "", ""}},
+		{"a\\\nb", []string{"ab"}},
+	}
+	for _, tt := range tests {
+		got, err := ShellWords(tt.in)
+		if err != nil {
+			t.Errorf("ShellWords(%q): %v", tt.in, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ShellWords(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	got, err := ShellWords(`"a \"b\" \$c \x"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`a "b" $c \x`}; !slices.Equal(got, want) {
+		t.Errorf("ShellWords() = %q, want %q", got, want)
+	}
+}
+
+func TestShellWords_Unterminated(t *testing.T) {
+	for _, in := range []string{`less "-R`, `less '-R`, `'it\'s'`} {
+		if _, err := ShellWords(in); err == nil {
+			t.Errorf("ShellWords(%q): want an error", in)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// WordsPerMinute is the reading speed reading times are estimated with.
+const WordsPerMinute = 200
+
+// DocumentStats are statistics of a markdown document.
+type DocumentStats struct {
+	Words      int `json:"words"`
+	Headings   int `json:"headings"`
+	CodeBlocks int `json:"code_blocks"`
+	Mermaid    int `json:"mermaid_diagrams"`
+	Links      int `json:"links"`
+}
+
+// Stats counts the words, headings, code blocks, mermaid diagrams and links
+// of a markdown document. Words in code blocks aren't counted.
+func Stats(markdown string) DocumentStats {
+	var (
+		s     DocumentStats
+		words strings.Builder
+	)
+	source := []byte(markdown)
+	doc := goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser().Parse(text.NewReader(source))
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n.Type() == ast.TypeBlock {
+			// Words don't run on from one block to the next.
+			words.WriteByte(' ')
+		}
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			s.Headings++
+		case *ast.FencedCodeBlock:
+			s.CodeBlocks++
+			if strings.EqualFold(string(n.Language(source)), "mermaid") {
+				s.Mermaid++
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeBlock:
+			s.CodeBlocks++
+			return ast.WalkSkipChildren, nil
+		case *ast.Link:
+			s.Links++
+		case *ast.AutoLink:
+			s.Links++
+			words.Write(n.URL(source))
+		case *ast.Text:
+			// Text is split up by inline markup, so words can span segments.
+			words.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				words.WriteByte(' ')
+			}
+		case *ast.String:
+			words.Write(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	s.Words = len(strings.Fields(words.String()))
+	return s
+}
+
+// ReadingTime estimates how long the document takes to read, in whole
+// minutes.
+func (s DocumentStats) ReadingTime() time.Duration {
+	return time.Duration(s.ReadingMinutes()) * time.Minute
+}
+
+// ReadingMinutes is the ReadingTime in minutes, rounded up, so that a
+// document with any words takes at least a minute.
+func (s DocumentStats) ReadingMinutes() int {
+	return int(math.Ceil(float64(s.Words) / WordsPerMinute))
+}
+
+// Add returns the sum of two statistics.
+func (s DocumentStats) Add(o DocumentStats) DocumentStats {
+	return DocumentStats{
+		Words:      s.Words + o.Words,
+		Headings:   s.Headings + o.Headings,
+		CodeBlocks: s.CodeBlocks + o.CodeBlocks,
+		Mermaid:    s.Mermaid + o.Mermaid,
+		Links:      s.Links + o.Links,
+	}
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	md := "# Title\n\n" +
+		"Some **bold**text and a [link](https://example.com), see\n" +
+		"<https://charm.sh>.\n\n" +
+		"## Code\n\n" +
+		"```go\nfunc main() {}\n```\n\n" +
+		"```mermaid\ngraph TD\nA --> B\n```\n\n" +
+		"    indented code\n\n" +
+		"| a | b |\n| --- | --- |\n| one | two |\n"
+	want := DocumentStats{
+		Words:      13,
+		Headings:   2,
+		CodeBlocks: 3,
+		Mermaid:    1,
+		Links:      2,
+	}
+	if got := Stats(md); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	for words, want := range map[int]time.Duration{
+		0:   0,
+		1:   time.Minute,
+		150: time.Minute,
+		200: time.Minute,
+		201: 2 * time.Minute,
+	} {
+		s := Stats(strings.Repeat("word ", words))
+		if s.Words != words {
+			t.Errorf("expected %d words, got %d", words, s.Words)
+		}
+		if got := s.ReadingTime(); got != want {
+			t.Errorf("ReadingTime() of %d words = %s, want %s", words, got, want)
+		}
+		if got := s.ReadingMinutes(); got != int(want/time.Minute) {
+			t.Errorf("ReadingMinutes() of %d words = %d, want %d", words, got, want/time.Minute)
+		}
+	}
+}
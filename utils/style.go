@@ -0,0 +1,418 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// LoadStyle returns a built-in style, or a JSON style file along with the
+// style it extends, with overrides deep-merged on top. A JSON style can
+// declare "extends" with a built-in style or another file, relative to its
+// own, and only contain what it changes.
+func LoadStyle(style string, overrides map[string]any) (ansi.StyleConfig, error) {
+	var config ansi.StyleConfig
+	values, _, err := loadStyleValues(style, overrides)
+	if err != nil {
+		return config, err
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return config, fmt.Errorf("unable to encode style: %w", err)
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return config, fmt.Errorf("invalid style %s: %w", style, err)
+	}
+	return config, nil
+}
+
+// loadStyleValues returns the JSON values of a style, overrides included,
+// and the built-in style it's based on, or "" if it isn't.
+func loadStyleValues(style string, overrides map[string]any) (map[string]any, string, error) {
+	values, base, err := loadStyleFile(style, map[string]bool{})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(overrides) > 0 {
+		// Decode the overrides like a style file, whatever the config
+		// format made of them.
+		b, err := json.Marshal(overrides)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid style overrides: %w", err)
+		}
+		var o map[string]any
+		if err := json.Unmarshal(b, &o); err != nil {
+			return nil, "", fmt.Errorf("invalid style overrides: %w", err)
+		}
+		mergeStyles(values, o)
+	}
+	return values, base, nil
+}
+
+func loadStyleFile(style string, seen map[string]bool) (map[string]any, string, error) {
+	if style == styles.AutoStyle {
+		switch {
+		case !term.IsTerminal(int(os.Stdout.Fd())):
+			style = styles.NoTTYStyle
+		case lipgloss.HasDarkBackground():
+			style = styles.DarkStyle
+		default:
+			style = styles.LightStyle
+		}
+	}
+	if builtin := styles.DefaultStyles[style]; builtin != nil {
+		values, err := styleValues(builtin)
+		return values, style, err
+	}
+
+	path, err := filepath.Abs(ExpandPath(style))
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to get absolute path: %w", err)
+	}
+	if seen[path] {
+		return nil, "", fmt.Errorf("style %s extends itself", style)
+	}
+	seen[path] = true
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read style: %w", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, "", fmt.Errorf("invalid style %s: %w", style, err)
+	}
+
+	parent, ok := values[styleExtendsKey]
+	if !ok {
+		return values, "", nil
+	}
+	delete(values, styleExtendsKey)
+	name, ok := parent.(string)
+	if !ok || name == "" {
+		return nil, "", fmt.Errorf("invalid style %s: %s must be a style name or path", style, styleExtendsKey)
+	}
+	parentValues, base, err := loadStyleFile(resolveExtends(path, name), seen)
+	if err != nil {
+		return nil, "", err
+	}
+	mergeStyles(parentValues, values)
+	return parentValues, base, nil
+}
+
+// resolveExtends returns the style a style file extends: a built-in style,
+// or a path relative to the file, where the .json extension is optional.
+func resolveExtends(path, name string) string {
+	if name == styles.AutoStyle || styles.DefaultStyles[name] != nil {
+		return name
+	}
+	name = ExpandPath(name)
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(filepath.Dir(path), name)
+	}
+	if _, err := os.Stat(name); err != nil && filepath.Ext(name) == "" {
+		if _, err := os.Stat(name + ".json"); err == nil {
+			return name + ".json"
+		}
+	}
+	return name
+}
+
+// styleValues returns the JSON values of a style config.
+func styleValues(config *ansi.StyleConfig) (map[string]any, error) {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode style: %w", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(b, &values); err != nil {
+		return nil, fmt.Errorf("unable to decode style: %w", err)
+	}
+	return values, nil
+}
+
+// mergeStyles deep-merges the values of src into dst.
+func mergeStyles(dst, src map[string]any) {
+	for k, v := range src {
+		if sv, ok := v.(map[string]any); ok {
+			if dv, ok := dst[k].(map[string]any); ok {
+				mergeStyles(dv, sv)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// StyleIssue is a problem found in a JSON style.
+type StyleIssue struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (i StyleIssue) String() string {
+	return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, i.Message)
+}
+
+// styleExtendsKey is the key of the style a JSON style extends.
+const styleExtendsKey = "extends"
+
+// Keys of a JSON style besides glamour's own.
+var styleExtensions = map[string]reflect.Type{
+	"callouts":      reflect.TypeFor[map[string]CalloutStyle](),
+	styleExtendsKey: reflect.TypeFor[string](),
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// ValidateStyle checks a JSON style for keys that glamour doesn't know,
+// values of the wrong type, invalid colors and unknown code block themes.
+// An error is returned if the style isn't valid JSON.
+func ValidateStyle(b []byte) ([]StyleIssue, error) {
+	v := &styleValidator{src: b, dec: json.NewDecoder(bytes.NewReader(b))}
+	v.dec.UseNumber()
+	if err := v.value(reflect.TypeFor[ansi.StyleConfig](), "", ""); err != nil {
+		return v.issues, v.syntaxError(err)
+	}
+	if end := v.offset(); end < len(b) {
+		line, col := v.position(end)
+		return v.issues, fmt.Errorf("%d:%d: unexpected data after the style", line, col)
+	}
+	return v.issues, nil
+}
+
+type styleValidator struct {
+	src    []byte
+	dec    *json.Decoder
+	issues []StyleIssue
+}
+
+// value validates the next value against t. The path of the value is used
+// in messages, and its key decides which strings are checked.
+func (v *styleValidator) value(t reflect.Type, path, key string) error {
+	start := v.offset()
+	tok, err := v.dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil // null leaves the setting unset
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	report := func(format string, args ...any) {
+		line, col := v.position(start)
+		v.issues = append(v.issues, StyleIssue{line, col, fmt.Sprintf(format, args...)})
+	}
+	mismatch := func(want string) error {
+		report("%s: expected %s, got %s", path, want, describeToken(tok))
+		return v.skip(tok)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		if tok != json.Delim('{') {
+			return mismatch("an object")
+		}
+		var fields map[string]reflect.Type
+		if t.Kind() == reflect.Struct {
+			fields = styleFields(t)
+			if path == "" {
+				for k, ft := range styleExtensions {
+					fields[k] = ft
+				}
+			}
+		}
+		for v.dec.More() {
+			keyStart := v.offset()
+			tok, err := v.dec.Token()
+			if err != nil {
+				return err
+			}
+			k, _ := tok.(string)
+			p := strings.TrimPrefix(path+"."+k, ".")
+
+			var ft reflect.Type
+			if fields != nil {
+				ft = fields[k]
+			} else {
+				ft = t.Elem()
+			}
+			if ft == nil || path == "callouts" && !isCalloutKind(k) {
+				line, col := v.position(keyStart)
+				v.issues = append(v.issues, StyleIssue{line, col, p + ": unknown key"})
+				if err := v.skipValue(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := v.value(ft, p, k); err != nil {
+				return err
+			}
+		}
+		_, err := v.dec.Token() // }
+		return err
+
+	case reflect.String:
+		s, ok := tok.(string)
+		if !ok {
+			return mismatch("a string")
+		}
+		switch key {
+		case "color", "background_color":
+			if !IsValidColor(s) {
+				report("%s: invalid color %q (must be #rgb, #rrggbb, or 0-255)", path, s)
+			}
+		case "theme":
+			if _, ok := chromastyles.Registry[strings.ToLower(s)]; s != "" && !ok {
+				report("%s: unknown code block theme %q", path, s)
+			}
+		}
+
+	case reflect.Bool:
+		if _, ok := tok.(bool); !ok {
+			return mismatch("true or false")
+		}
+
+	case reflect.Uint:
+		n, ok := tok.(json.Number)
+		if !ok {
+			return mismatch("a number")
+		}
+		if _, err := strconv.ParseUint(n.String(), 10, 0); err != nil {
+			report("%s: expected a whole number of at least 0, got %s", path, n)
+		}
+	}
+	return nil
+}
+
+// styleFields returns the types of the fields of a style struct by JSON key,
+// including those of embedded structs.
+func styleFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && name == "" {
+			for k, ft := range styleFields(f.Type) {
+				fields[k] = ft
+			}
+			continue
+		}
+		if name != "" && name != "-" {
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
+
+// skipValue skips the next value.
+func (v *styleValidator) skipValue() error {
+	tok, err := v.dec.Token()
+	if err != nil {
+		return err
+	}
+	return v.skip(tok)
+}
+
+// skip skips the rest of the value that starts with tok.
+func (v *styleValidator) skip(tok json.Token) error {
+	if tok != json.Delim('{') && tok != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := v.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// offset returns the offset of the next token in the source.
+func (v *styleValidator) offset() int {
+	off := int(v.dec.InputOffset())
+	for off < len(v.src) && strings.IndexByte(" \t\r\n,:", v.src[off]) >= 0 {
+		off++
+	}
+	return off
+}
+
+// position returns the line and column of an offset, counting from 1.
+func (v *styleValidator) position(off int) (line, col int) {
+	before := v.src[:min(off, len(v.src))]
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return bytes.Count(before, []byte("\n")) + 1, utf8.RuneCount(before[lineStart:]) + 1
+}
+
+func (v *styleValidator) syntaxError(err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// The offset is just after the invalid character, if there is one.
+		off := int(syntaxErr.Offset)
+		if !strings.HasPrefix(syntaxErr.Error(), "unexpected end") {
+			off--
+		}
+		line, col := v.position(off)
+		return fmt.Errorf("%d:%d: %w", line, col, err)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		line, col := v.position(len(v.src))
+		return fmt.Errorf("%d:%d: unexpected end of JSON input", line, col)
+	}
+	return err //nolint:wrapcheck
+}
+
+// IsValidColor returns whether s is a color lipgloss understands: a hex color
+// or an ANSI color number.
+func IsValidColor(s string) bool {
+	if hexColor.MatchString(s) {
+		return true
+	}
+	n, err := strconv.Atoi(s)
+	return err == nil && n >= 0 && n <= 255
+}
+
+func isCalloutKind(s string) bool {
+	_, ok := calloutKindOf(s)
+	return ok
+}
+
+func describeToken(tok json.Token) string {
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '{' {
+			return "an object"
+		}
+		return "an array"
+	case string:
+		return strconv.Quote(tok)
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(tok)
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/glamour/styles"
+)
+
+func TestValidateStyle(t *testing.T) {
+	style := `{
+  "document": { "colour": "#fff", "color": "#ggg" },
+  "h1": { "bold": "yes", "margin": -1, "prefix": null },
+  "code_block": { "theme": "nope", "chroma": { "text": { "color": "300" } } },
+  "callouts": { "nit": {}, "note": { "background_color": "12" } },
+  "table": { "column_separator": "|", "extra": [1, {"a": 2}] }
+}`
+	want := []string{
+		`2:17: document.colour: unknown key`,
+		`2:44: document.color: invalid color "#ggg" (must be #rgb, #rrggbb, or 0-255)`,
+		`3:19: h1.bold: expected true or false, got "yes"`,
+		`3:36: h1.margin: expected a whole number of at least 0, got -1`,
+		`4:28: code_block.theme: unknown code block theme "nope"`,
+		`4:67: code_block.chroma.text.color: invalid color "300" (must be #rgb, #rrggbb, or 0-255)`,
+		`5:17: callouts.nit: unknown key`,
+		`6:39: table.extra: unknown key`,
+	}
+	issues, err := ValidateStyle([]byte(style))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ValidateStyle() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidateStyle_Syntax(t *testing.T) {
+	for style, want := range map[string]string{
+		"{\n  \"h1\": {,}}": "2:10: invalid character ',' looking for beginning of value",
+		`{"h1": {`:          "1:9: unexpected end of JSON input",
+		`{} {}`:             "1:4: unexpected data after the style",
+	} {
+		if _, err := ValidateStyle([]byte(style)); err == nil || err.Error() != want {
+			t.Errorf("ValidateStyle(%q) error = %v, want %s", style, err, want)
+		}
+	}
+}
+
+func TestValidateStyle_Builtin(t *testing.T) {
+	for name, style := range styles.DefaultStyles {
+		b, err := json.Marshal(style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		issues, err := ValidateStyle(b)
+		if err != nil || len(issues) > 0 {
+			t.Errorf("built-in style %s: %v %v", name, issues, err)
+		}
+	}
+}
+
+func TestLoadStyle(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("base.json", `{"extends": "dracula", "h1": {"color": "#ff0000", "prefix": "» "}}`)
+	style := write("style.json", `{"extends": "base", "h2": {"color": "1"}}`)
+
+	config, err := LoadStyle(style, map[string]any{
+		"h1": map[string]any{"prefix": "> "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dracula := styles.DraculaStyleConfig
+	if got := config.H1; got.Color == nil || *got.Color != "#ff0000" || got.Prefix != "> " {
+		t.Errorf("h1 should be merged from base.json and the overrides: %+v", got.StylePrimitive)
+	}
+	if got := config.H2; got.Color == nil || *got.Color != "1" || got.Prefix != dracula.H2.Prefix {
+		t.Errorf("h2 should be merged from style.json and dracula: %+v", got.StylePrimitive)
+	}
+	if got := config.Document.Color; got == nil || *got != *dracula.Document.Color {
+		t.Errorf("document should be dracula's: %v", got)
+	}
+	if config.CodeBlock.Theme != dracula.CodeBlock.Theme {
+		t.Errorf("code block theme = %q, want %q", config.CodeBlock.Theme, dracula.CodeBlock.Theme)
+	}
+
+	// Built-in styles are themselves, not each other.
+	config, err = LoadStyle(styles.TokyoNightStyle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *config.Document.Color != *styles.TokyoNightStyleConfig.Document.Color {
+		t.Error("tokyo-night should not be loaded as another style")
+	}
+
+	loop := write("loop.json", `{"extends": "loop2.json"}`)
+	write("loop2.json", `{"extends": "loop"}`)
+	if _, err := LoadStyle(loop, nil); err == nil || !strings.Contains(err.Error(), "extends itself") {
+		t.Errorf("LoadStyle() should reject cycles, got %v", err)
+	}
+	if _, err := LoadStyle(write("missing.json", `{"extends": "nope"}`), nil); err == nil {
+		t.Error("LoadStyle() should fail when the extended style doesn't exist")
+	}
+}
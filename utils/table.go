@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	xansi "github.com/charmbracelet/x/ansi"
+)
+
+// Header detection modes of TableOptions.
+const (
+	TableHeaderAuto   = "auto"
+	TableHeaderAlways = "always"
+	TableHeaderNever  = "never"
+)
+
+// TableOptions control how CSV and TSV data is shown as a table.
+type TableOptions struct {
+	Delimiter rune   // field delimiter, or 0 to detect it
+	Header    string // whether the first row is a header: auto, always or never
+	MaxRows   int    // rows to show, or 0 for all of them
+	Width     int    // width to fit the table into, or 0 for no limit
+}
+
+// Delimiters tried, in order of preference, when none is set.
+var tableDelimiters = []rune{',', '\t', ';', '|'}
+
+// IsTableFile returns whether the filename is a CSV or TSV file, which is
+// shown as a table.
+func IsTableFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// TableToMarkdown converts CSV or TSV data to a markdown table. Columns of
+// numbers are aligned to the right. Cells are truncated when the table is
+// wider than opts.Width, and the last columns are left out when there are
+// too many to fit.
+func TableToMarkdown(filename, content string, opts TableOptions) (string, error) {
+	if opts.Delimiter == 0 && strings.EqualFold(filepath.Ext(filename), ".tsv") {
+		opts.Delimiter = '\t'
+	}
+	rows, err := parseTable(content, opts.Delimiter)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+
+	var header []string
+	switch opts.Header {
+	case TableHeaderAlways:
+		header, rows = rows[0], rows[1:]
+	case TableHeaderNever:
+	default:
+		if hasHeader(rows) {
+			header, rows = rows[0], rows[1:]
+		}
+	}
+
+	cols := len(header)
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	header = padRow(header, cols)
+	for i := range rows {
+		rows[i] = padRow(rows[i], cols)
+	}
+
+	var more int
+	if opts.MaxRows > 0 && len(rows) > opts.MaxRows {
+		rows, more = rows[:opts.MaxRows], len(rows)-opts.MaxRows
+	}
+
+	widths := columnWidths(append([][]string{header}, rows...), cols, opts.Width)
+	// The columns that don't fit are left out, after a column of "…".
+	shown := len(widths)
+	format := func(row []string) string {
+		cells := make([]string, shown, cols)
+		for i, cell := range row[:shown] {
+			cells[i] = escapeTableCell(escapeData(xansi.Truncate(cell, widths[i], "…")))
+		}
+		if shown < cols {
+			cells = append(cells, "…")
+		}
+		return "| " + strings.Join(cells, " | ") + " |"
+	}
+
+	lines := []string{format(header)}
+	align := make([]string, shown, cols)
+	for i := range align {
+		align[i] = "---"
+		if numericColumn(rows, i) {
+			align[i] = "---:"
+		}
+	}
+	if shown < cols {
+		align = append(align, "---")
+	}
+	lines = append(lines, "| "+strings.Join(align, " | ")+" |")
+	for _, row := range rows {
+		lines = append(lines, format(row))
+	}
+	if more > 0 {
+		lines = append(lines, "", fmt.Sprintf("*… %d more rows*", more))
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// RenderTableBlocks replaces csv and tsv code blocks with tables.
+func RenderTableBlocks(content string, opts TableOptions) string {
+	if content == "" {
+		return content
+	}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	blocks := findFencedBlocks(lines, func(language string) bool {
+		return language == "csv" || language == "tsv"
+	})
+	if len(blocks) == 0 {
+		return content
+	}
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block := blocks[i]
+		o := opts
+		o.Width = max(0, o.Width-len(block.indentPrefix))
+		table, err := TableToMarkdown("block."+block.language, block.content, o)
+		if err != nil || table == "" {
+			continue
+		}
+		rendered := block.indent(strings.Split(strings.TrimSuffix(table, "\n"), "\n"))
+		for i, l := range rendered {
+			rendered[i] = strings.TrimRight(l, " ")
+		}
+		lines = replaceLines(lines, block.startLine, block.endLine, rendered)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseTable parses delimited data, detecting the delimiter if it's 0.
+func parseTable(content string, delimiter rune) ([][]string, error) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	if delimiter == 0 {
+		delimiter = detectDelimiter(content)
+	}
+	r := csv.NewReader(strings.NewReader(content))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var rows [][]string
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse table: %w", err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// detectDelimiter returns the delimiter that splits the first lines into the
+// most columns consistently.
+func detectDelimiter(content string) rune {
+	lines := strings.SplitN(content, "\n", 6)
+	if len(lines) > 5 {
+		lines = lines[:5]
+	}
+	best, bestCount := tableDelimiters[0], 0
+	for _, d := range tableDelimiters {
+		count := -1
+		for _, l := range lines {
+			if strings.TrimSpace(l) == "" {
+				continue
+			}
+			n := strings.Count(l, string(d))
+			if count < 0 || n < count {
+				count = n
+			}
+		}
+		if count > bestCount {
+			best, bestCount = d, count
+		}
+	}
+	return best
+}
+
+// hasHeader guesses whether the first row is a header: it is unless it has
+// numbers, which column names rarely are.
+func hasHeader(rows [][]string) bool {
+	if len(rows) < 2 {
+		return false
+	}
+	for _, cell := range rows[0] {
+		if isNumber(cell) {
+			return false
+		}
+	}
+	return true
+}
+
+// numericColumn returns whether the non-empty cells of a column are numbers.
+func numericColumn(rows [][]string, col int) bool {
+	numbers := 0
+	for _, row := range rows {
+		cell := strings.TrimSpace(row[col])
+		if cell == "" {
+			continue
+		}
+		if !isNumber(cell) {
+			return false
+		}
+		numbers++
+	}
+	return numbers > 0
+}
+
+// isNumber returns whether s is a number, allowing for signs, thousands
+// separators, currency symbols and percentages.
+func isNumber(s string) bool {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(s, "%")
+	s = strings.TrimLeft(s, "$€£¥")
+	s = strings.ReplaceAll(s, ",", "")
+	s = strings.ReplaceAll(s, "_", "")
+	_, err := strconv.ParseFloat(s, 64)
+	return s != "" && err == nil
+}
+
+// columnWidths returns the widths the cells of each column are truncated to,
+// so that the table fits in width. Narrow columns keep their width; the
+// space left is shared evenly by the wide ones. When there are too many
+// columns to fit, only the leading ones that do have a width, and the
+// table ends with a column of "…".
+func columnWidths(rows [][]string, cols, width int) []int {
+	widths := make([]int, cols)
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], xansi.StringWidth(cell))
+		}
+	}
+	if width <= 0 {
+		return widths
+	}
+
+	// Borders and padding take 3 columns for each cell, and glamour indents
+	// tables by 2.
+	const minWidth = 6
+	const moreWidth = 1 + 3
+	needed := func(n int) int {
+		total := 2
+		for _, w := range widths[:n] {
+			total += min(w, minWidth) + 3
+		}
+		return total
+	}
+	if needed(cols) > width {
+		n := 1
+		for n < cols && needed(n+1)+moreWidth <= width {
+			n++
+		}
+		widths, cols = widths[:n], n
+		width -= moreWidth
+	}
+	available := max(width-3*cols-2, 0)
+
+	order := make([]int, cols)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return widths[a] - widths[b] })
+	for n, i := range order {
+		share := max(available/(cols-n), min(widths[i], minWidth))
+		widths[i] = min(widths[i], share)
+		available -= widths[i]
+	}
+	return widths
+}
+
+func padRow(row []string, cols int) []string {
+	for len(row) < cols {
+		row = append(row, "")
+	}
+	return row
+}
+
+// escapeData escapes data that has a meaning in markdown, so that it's shown
+// as is.
+var escapeData = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"<", `\<`,
+).Replace
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableToMarkdown(t *testing.T) {
+	csv := "name,price,note\n" +
+		"apple,\"1,200.50\",*fresh*\n" +
+		"pear,3,\"a | b\"\n" +
+		"plum,,\n"
+	want := "| name | price | note |\n" +
+		"| --- | ---: | --- |\n" +
+		"| apple | 1,200.50 | \\*fresh\\* |\n" +
+		"| pear | 3 | a \\| b |\n" +
+		"| plum |  |  |\n"
+	got, err := TableToMarkdown("data.csv", csv, TableOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("TableToMarkdown() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTableToMarkdown_Options(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		opts     TableOptions
+		want     string
+	}{
+		{
+			name:     "tsv",
+			filename: "data.tsv",
+			content:  "a,b\tc\n1\t2\n",
+			want:     "| a,b | c |\n| ---: | ---: |\n| 1 | 2 |\n",
+		},
+		{
+			name:    "detected delimiter",
+			content: "a;b\n1;2\n",
+			want:    "| a | b |\n| ---: | ---: |\n| 1 | 2 |\n",
+		},
+		{
+			name:    "delimiter",
+			content: "a;b|c\n",
+			opts:    TableOptions{Delimiter: '|', Header: TableHeaderAlways},
+			want:    "| a;b | c |\n| --- | --- |\n",
+		},
+		{
+			name:    "numeric first row",
+			content: "1,2\n3,4\n",
+			want:    "|  |  |\n| ---: | ---: |\n| 1 | 2 |\n| 3 | 4 |\n",
+		},
+		{
+			name:    "no header",
+			content: "a,b\nc,d\n",
+			opts:    TableOptions{Header: TableHeaderNever},
+			want:    "|  |  |\n| --- | --- |\n| a | b |\n| c | d |\n",
+		},
+		{
+			name:    "max rows",
+			content: "n\n1\n2\n3\n",
+			opts:    TableOptions{MaxRows: 1},
+			want:    "| n |\n| ---: |\n| 1 |\n\n*… 2 more rows*\n",
+		},
+		{
+			name:    "width",
+			content: "id,text\n1,a long line of text that does not fit\n",
+			opts:    TableOptions{Width: 30},
+			want:    "| id | text |\n| ---: | --- |\n| 1 | a long line of text… |\n",
+		},
+		{
+			name:    "too many columns",
+			content: "first,second,third,fourth,fifth\n1,2,3,4,5\n",
+			opts:    TableOptions{Width: 30},
+			want:    "| first | second | … |\n| ---: | ---: | --- |\n| 1 | 2 | … |\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TableToMarkdown(tt.filename, tt.content, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("TableToMarkdown() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTableBlocks(t *testing.T) {
+	input := "# Prices\n\n```csv\nname,price\napple,3\n```\n\n```go\na,b\n```"
+	want := "# Prices\n\n| name | price |\n| --- | ---: |\n| apple | 3 |\n\n```go\na,b\n```"
+	if got := RenderTableBlocks(input, TableOptions{}); got != want {
+		t.Errorf("RenderTableBlocks() =\n%s\nwant\n%s", got, want)
+	}
+	if !IsTableFile("DATA.TSV") || IsTableFile("data.md") {
+		t.Error("IsTableFile() should match by extension, ignoring case")
+	}
+	if IsCodeFile("data.csv", []byte("a,b"), nil) {
+		t.Error("CSV files should not be rendered as code")
+	}
+	if strings.Contains(RenderTableBlocks("```csv\n```", TableOptions{}), "|") {
+		t.Error("empty blocks should be kept")
+	}
+}
@@ -4,31 +4,16 @@ package utils
 import (
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
 	"github.com/charmbracelet/glamour"
-	"github.com/charmbracelet/glamour/ansi"
-	"github.com/charmbracelet/glamour/styles"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/mitchellh/go-homedir"
 )
 
 // RemoveFrontmatter removes the front matter header of a markdown file.
 func RemoveFrontmatter(content []byte) []byte {
-	if frontmatterBoundaries := detectFrontmatter(content); frontmatterBoundaries[0] == 0 {
-		return content[frontmatterBoundaries[1]:]
-	}
-	return content
-}
-
-var yamlPattern = regexp.MustCompile(`(?m)^---\r?\n(\s*\r?\n)?`)
-
-func detectFrontmatter(c []byte) []int {
-	if matches := yamlPattern.FindAllIndex(c, 2); len(matches) > 1 {
-		return []int{matches[0][0], matches[1][1]}
-	}
-	return []int{-1, -1}
+	_, body := ExtractFrontmatter(content)
+	return body
 }
 
 // ExpandPath expands tilde and all environment variables from the given path.
@@ -69,45 +54,20 @@ func IsMarkdownFile(filename string) bool {
 	return false
 }
 
-// GlamourStyle returns a glamour.TermRendererOption based on the given style.
-func GlamourStyle(style string, isCode bool) glamour.TermRendererOption {
-	if !isCode {
-		if style == styles.AutoStyle {
-			return glamour.WithAutoStyle()
-		}
-		return glamour.WithStylePath(style)
+// GlamourStyle returns a glamour.TermRendererOption based on the given style,
+// with the overrides deep-merged on top of it.
+func GlamourStyle(style string, overrides map[string]any, isCode bool) glamour.TermRendererOption {
+	styleConfig, err := LoadStyle(style, overrides)
+	if err != nil {
+		return func(*glamour.TermRenderer) error { return err }
 	}
 
 	// If we are rendering a pure code block, we need to modify the style to
 	// remove the indentation.
-
-	var styleConfig ansi.StyleConfig
-
-	switch style {
-	case styles.AutoStyle:
-		if lipgloss.HasDarkBackground() {
-			styleConfig = styles.DarkStyleConfig
-		} else {
-			styleConfig = styles.LightStyleConfig
-		}
-	case styles.DarkStyle:
-		styleConfig = styles.DarkStyleConfig
-	case styles.LightStyle:
-		styleConfig = styles.LightStyleConfig
-	case styles.PinkStyle:
-		styleConfig = styles.PinkStyleConfig
-	case styles.NoTTYStyle:
-		styleConfig = styles.NoTTYStyleConfig
-	case styles.DraculaStyle:
-		styleConfig = styles.DraculaStyleConfig
-	case styles.TokyoNightStyle:
-		styleConfig = styles.DraculaStyleConfig
-	default:
-		return glamour.WithStylesFromJSONFile(style)
+	if isCode {
+		var margin uint
+		styleConfig.CodeBlock.Margin = &margin
 	}
 
-	var margin uint
-	styleConfig.CodeBlock.Margin = &margin
-
 	return glamour.WithStyles(styleConfig)
 }